@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"os"
+	"sort"
+
+	"github.com/gofiber/fiber/v2"
+	fiberLogger "github.com/gofiber/fiber/v2/middleware/logger"
+	config "github.com/mrpurushotam/mini_db/internal"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/proxy"
+	"github.com/mrpurushotam/mini_db/internal/raft"
+	"github.com/mrpurushotam/mini_db/internal/slots"
+)
+
+func main() {
+	cfg := config.LoadConfig()
+	logger.Init(os.Stdout, "mini_db proxy: ", cfg.LogLevel)
+
+	if cfg.ShardNodes == "" {
+		logger.Error("SHARD_NODES is required, e.g. n1=http://10.0.0.1:3000,n2=http://10.0.0.2:3000")
+		os.Exit(1)
+	}
+	// same id=url list as RAFT_PEERS
+	nodes, err := raft.ParsePeers(cfg.ShardNodes)
+	if err != nil {
+		logger.Error("Invalid SHARD_NODES", "error", err)
+		os.Exit(1)
+	}
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	table, err := slots.LoadTable(cfg.SlotsFile, ids)
+	if err != nil {
+		logger.Error("Failed to load slot table", "error", err)
+		os.Exit(1)
+	}
+	for id, ranges := range table.Ranges() {
+		logger.Info("Shard", "node", id, "url", nodes[id], "slots", ranges)
+	}
+
+	app := fiber.New()
+	app.Use(fiberLogger.New())
+	proxy.New(nodes, table).Register(app.Group("/api/v0"))
+
+	logger.Info("starting proxy", "port", cfg.ProxyPort, "nodes", len(nodes))
+	log.Fatal(app.Listen(":" + cfg.ProxyPort))
+}
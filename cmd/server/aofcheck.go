@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mrpurushotam/mini_db/internal/aof"
+)
+
+// runAOFCheck implements `aof-check [--fix] [file]`, reporting the state of an AOF
+// offline and with --fix cutting it back to its last good record. It returns the exit code.
+func runAOFCheck(args []string, defaultPath string) int {
+	fs := flag.NewFlagSet("aof-check", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "truncate the file to its last good record")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: aof-check [--fix] [file]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	path := defaultPath
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+
+	check := aof.Check
+	if *fix {
+		check = aof.Repair
+	}
+	r, err := check(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "aof-check: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("file:          %s (%d bytes)\n", path, r.Size)
+	if r.Header.Format != "" {
+		fmt.Printf("header:        version %s, encoding %s\n", r.Header.Version, r.Header.Encoding)
+	}
+	if snap := r.Header.SnapshotPath(path); snap != "" {
+		status := "ok"
+		if r.SnapshotErr != nil {
+			status = r.SnapshotErr.Error()
+		}
+		fmt.Printf("snapshot:      %s (%s)\n", snap, status)
+	}
+	fmt.Printf("records:       %d, transactions: %d\n", r.Records, r.Transactions)
+	fmt.Printf("valid prefix:  %d bytes\n", r.Valid)
+
+	if c := r.Corruption; c != nil {
+		kind := "corrupt record with valid records after it"
+		if c.Tail {
+			kind = "torn tail"
+		}
+		fmt.Printf("problem:       %s at line %d, offset %d: %s\n", kind, c.Line, c.Offset, c.Reason)
+	}
+	if r.Pending > 0 {
+		fmt.Printf("problem:       unterminated transaction with %d operations at the end\n", r.Pending)
+	}
+	if r.MissingNewline {
+		fmt.Println("problem:       last record is not terminated by a newline")
+	}
+
+	switch {
+	case r.OK():
+		fmt.Println("AOF is valid")
+		return 0
+	case *fix && r.Valid < r.Size:
+		fmt.Printf("fixed: truncated %d bytes\n", r.Size-r.Valid)
+	case *fix && r.MissingNewline:
+		fmt.Println("fixed: terminated the last record")
+	case !*fix:
+		fmt.Println("run with --fix to truncate the file to its last good record")
+		return 1
+	}
+	if r.SnapshotErr != nil {
+		// the records cannot be replayed without the state they follow, nothing to fix here
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	config "github.com/mrpurushotam/mini_db/internal"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/raft"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+// startRaft makes db a member of the cluster described by RAFT_ID and RAFT_PEERS
+func startRaft(cfg *config.Config, db *store.Store) (*raft.Node, error) {
+	if cfg.ReplicaOf != "" {
+		return nil, fmt.Errorf("REPLICAOF cannot be combined with cluster mode")
+	}
+	peers, err := raft.ParsePeers(cfg.RaftPeers)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := peers[cfg.RaftID]; !ok {
+		return nil, fmt.Errorf("RAFT_ID %q is not one of RAFT_PEERS", cfg.RaftID)
+	}
+
+	node, err := raft.NewNode(raft.Config{
+		ID:                cfg.RaftID,
+		Peers:             peers,
+		Dir:               cfg.RaftDir,
+		ElectionTimeout:   time.Duration(cfg.RaftElectionTimeoutMs) * time.Millisecond,
+		SnapshotThreshold: uint64(cfg.RaftSnapshotThreshold),
+	}, db, raft.NewHTTPTransport(peers))
+	if err != nil {
+		return nil, err
+	}
+	db.SetConsensus(node)
+	node.Start()
+	logger.Info("Cluster mode started", "id", cfg.RaftID, "peers", len(peers))
+	return node, nil
+}
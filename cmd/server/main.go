@@ -14,20 +14,42 @@ import (
 	"github.com/mrpurushotam/mini_db/internal/aof"
 	"github.com/mrpurushotam/mini_db/internal/handler"
 	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/pubsub"
+	"github.com/mrpurushotam/mini_db/internal/replication"
+	"github.com/mrpurushotam/mini_db/internal/resp"
 	"github.com/mrpurushotam/mini_db/internal/routes"
 	"github.com/mrpurushotam/mini_db/internal/store"
 )
 
 func main() {
 	cfg := config.LoadConfig()
+	if len(os.Args) > 1 && os.Args[1] == "aof-check" {
+		os.Exit(runAOFCheck(os.Args[2:], cfg.AOF_FILENAME))
+	}
+
+	clustered := cfg.RaftPeers != ""
 	app := fiber.New()
 	app.Use(fiberLogger.New())
 
 	logger.Init(os.Stdout, "mini_db: ", cfg.LogLevel)
 
-	aofFile, err := aof.NewAOF(cfg.AOF_FILENAME)
+	fsyncPolicy, err := aof.ParseFsyncPolicy(cfg.AppendFsync)
+	if err != nil {
+		logger.Warn("Invalid appendfsync policy, falling back to always", "error", err)
+	}
+
+	tailPolicy, err := aof.ParseTailPolicy(cfg.AOFLoadTruncated)
 	if err != nil {
+		logger.Warn("Invalid AOF tail policy, falling back to truncate", "error", err)
+	}
+
+	var aofFile *aof.AOF
+	if clustered {
+		logger.Info("Cluster mode: the raft log takes the place of the AOF")
+	} else if aofFile, err = aof.NewAOF(cfg.AOF_FILENAME, fsyncPolicy); err != nil {
 		logger.Error("Failed to create AOF", "error", err)
+	} else {
+		aofFile.SetTailPolicy(tailPolicy)
 	}
 	defer func() {
 		if aofFile != nil {
@@ -35,21 +57,72 @@ func main() {
 		}
 	}()
 
-	store := store.NewStore()
+	db := store.NewStore()
 	logger.Info("Store initialized")
-	store.EnableAOF(aofFile)
+	db.EnableAOF(aofFile)
 
-	if err := store.LoadFromAOF(cfg.AOF_FILENAME); err != nil {
-		logger.Error("Failed to load AOF", "error", err)
+	policy, err := store.ParseEvictionPolicy(cfg.MaxMemoryPolicy)
+	if err != nil {
+		logger.Warn("Invalid eviction policy, falling back to noeviction", "error", err)
+	}
+	db.SetMaxMemory(cfg.MaxMemory, policy)
+
+	// in cluster mode the store is rebuilt from the raft snapshot and log instead
+	if !clustered {
+		if err := db.LoadFromAOF(cfg.AOF_FILENAME); err != nil {
+			// starting empty would let the startup snapshot overwrite the data on disk
+			logger.Error("Failed to load AOF", "error", err)
+			aofFile.Close()
+			os.Exit(1)
+		}
 	}
 
-	handler := handler.NewHandler(store)
+	broker := pubsub.NewBroker(cfg.PubSubBuffer)
+	// after loading, so replaying the AOF does not produce events
+	db.SetNotifier(broker)
+
+	handler := handler.NewHandler(db, broker)
+	switch {
+	case clustered:
+		node, err := startRaft(cfg, db)
+		if err != nil {
+			logger.Error("Failed to start cluster mode", "error", err)
+			os.Exit(1)
+		}
+		defer node.Close()
+		handler.Raft = node
+	case cfg.ReplicaOf != "":
+		// followers only change through the operations streamed from the leader
+		db.SetReadOnly(true)
+		handler.Follower = replication.NewFollower(cfg.ReplicaOf, db)
+		handler.Follower.Start()
+		defer handler.Follower.Close()
+		logger.Info("Replicating from leader", "leader", cfg.ReplicaOf)
+	default:
+		handler.Leader = replication.NewLeader(db, cfg.ReplBacklog)
+	}
 	api := app.Group("/api/v0")
 	routes.Register(api, handler)
 	logger.Info("Routes registered")
 
 	var wg sync.WaitGroup
 
+	// active expiry: sample keys with a ttl ten times a second. Followers
+	// receive the DELETEs of their leader's sweeper instead.
+	if cfg.ReplicaOf == "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ticker := time.NewTicker(100 * time.Millisecond)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				db.SweepExpired()
+			}
+		}()
+	}
+
 	if aofFile == nil {
 		logger.Info("AOF not initialized; auto-snapshot disabled")
 	} else {
@@ -58,7 +131,7 @@ func main() {
 			defer wg.Done()
 
 			// initial snapshot at startup
-			if err := aofFile.Snapshot(store); err != nil {
+			if err := aofFile.Snapshot(db); err != nil {
 				logger.Error("Initial snapshot failed", "error", err)
 			} else {
 				logger.Info("Initial snapshot completed")
@@ -68,7 +141,7 @@ func main() {
 			defer ticker.Stop()
 
 			for range ticker.C {
-				if err := aofFile.Snapshot(store); err != nil {
+				if err := aofFile.Snapshot(db); err != nil {
 					logger.Error("Auto-snapshot failed", "error", err)
 				} else {
 					logger.Info("Auto-snapshot completed")
@@ -84,5 +157,15 @@ func main() {
 		return
 	}
 
+	if cfg.RespPort != "0" {
+		respServer := resp.NewServer(db, broker)
+		defer respServer.Close()
+		go func() {
+			if err := respServer.ListenAndServe(":" + cfg.RespPort); err != nil {
+				logger.Error("RESP listener failed", "error", err)
+			}
+		}()
+	}
+
 	log.Fatal(app.Listen(":" + cfg.Port))
 }
@@ -2,27 +2,96 @@ package aof
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/mrpurushotam/mini_db/internal/domain"
 	"github.com/mrpurushotam/mini_db/internal/logger"
-	valuepkg "github.com/mrpurushotam/mini_db/internal/value"
+	"github.com/mrpurushotam/mini_db/internal/rdb"
 )
 
 // StoreReader defines what AOF needs from the store (no import!)
 type StoreReader interface {
-	GetAll() map[string]domain.Value
+	// Fork returns a deep copy of the live keys and their deadlines. mark is called
+	// while the copy still matches the AOF, before any later write is logged.
+	Fork(mark func()) (map[string]domain.Value, map[string]time.Time)
+}
+
+var ErrRewriteInProgress = errors.New("background AOF rewrite already in progress")
+
+// FsyncPolicy controls when appended records are forced to disk, like redis' appendfsync
+type FsyncPolicy string
+
+const (
+	// FsyncAlways makes every write durable before it is acknowledged; concurrent
+	// writers are batched into a single fsync (group commit)
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncEverySec fsyncs once a second in the background, losing at most a second on power loss
+	FsyncEverySec FsyncPolicy = "everysec"
+	// FsyncNo leaves flushing to the operating system
+	FsyncNo FsyncPolicy = "no"
+)
+
+func ParseFsyncPolicy(v string) (FsyncPolicy, error) {
+	switch p := FsyncPolicy(strings.ToLower(v)); p {
+	case FsyncAlways, FsyncEverySec, FsyncNo:
+		return p, nil
+	}
+	return FsyncAlways, fmt.Errorf("unknown appendfsync policy %q", v)
+}
+
+// TailPolicy decides what loading does when the last AOF record is torn or corrupt,
+// like redis' aof-load-truncated
+type TailPolicy string
+
+const (
+	// TailTruncate cuts the file back to the last good record and loads the rest
+	TailTruncate TailPolicy = "truncate"
+	// TailRefuse fails the load so the file can be inspected first
+	TailRefuse TailPolicy = "refuse"
+)
+
+func ParseTailPolicy(v string) (TailPolicy, error) {
+	switch p := TailPolicy(strings.ToLower(v)); p {
+	case TailTruncate, TailRefuse:
+		return p, nil
+	}
+	return TailTruncate, fmt.Errorf("unknown AOF tail policy %q", v)
 }
 
 type AOF struct {
 	file   *os.File
 	writer *bufio.Writer
 	mu     sync.Mutex
+	policy FsyncPolicy
+	// seq numbers every appended record (guarded by mu)
+	seq uint64
+
+	// group commit state: synced is the last seq known to be on disk and
+	// syncing is set while one caller performs an fsync for everyone
+	syncMu   sync.Mutex
+	syncCond *sync.Cond
+	synced   uint64
+	syncing  bool
+	// err is sticky: after a failed fsync, writes are refused as their durability cannot be promised
+	err error
+
+	stop chan struct{}
+	done chan struct{}
+
+	// rewriting is set while a snapshot is being built; records appended in the
+	// meantime are copied to rewriteBuf and added to the new file before the swap
+	rewriting  bool
+	rewriteBuf *bytes.Buffer
+
+	tailPolicy TailPolicy
 }
 
 type Operation struct {
@@ -36,10 +105,20 @@ type AOFHeader struct {
 	Format   string `json:"format"`
 	Version  string `json:"version"`
 	Encoding string `json:"encoding"`
+	// Snapshot names the binary snapshot, in the AOF's directory, that the records in this file follow
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// SnapshotPath returns the path of the snapshot to load before replaying the AOF at aofPath, if any
+func (h AOFHeader) SnapshotPath(aofPath string) string {
+	if h.Snapshot == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(aofPath), filepath.Base(h.Snapshot))
 }
 
 // NewAOF Creates / open filepath
-func NewAOF(filepath string) (*AOF, error) {
+func NewAOF(filepath string, policy FsyncPolicy) (*AOF, error) {
 	file, err := os.OpenFile(filepath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		logger.Error("failed to open AOF file", "filepath", filepath, "error", err)
@@ -49,21 +128,180 @@ func NewAOF(filepath string) (*AOF, error) {
 	aof := &AOF{
 		file:   file,
 		writer: bufio.NewWriter(file),
+		policy: policy,
+	}
+	aof.syncCond = sync.NewCond(&aof.syncMu)
+
+	if policy == FsyncEverySec {
+		aof.stop = make(chan struct{})
+		aof.done = make(chan struct{})
+		go aof.syncEverySecond()
 	}
 
-	logger.Info("AOF initialized", "filepath", filepath)
+	logger.Info("AOF initialized", "filepath", filepath, "appendfsync", policy)
 	return aof, nil
 }
 
-// Snapshot create a NewAof file with current state
+// SetTailPolicy sets how Read handles a torn last record; the default is TailTruncate
+func (a *AOF) SetTailPolicy(p TailPolicy) {
+	a.tailPolicy = p
+}
+
+func (a *AOF) syncEverySecond() {
+	defer close(a.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := a.Sync(a.lastSeq()); err != nil {
+				logger.Error("background AOF fsync failed", "error", err)
+			}
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+func (a *AOF) lastSeq() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.seq
+}
+
+// Durable blocks until the record numbered seq is on disk when the policy is
+// always; with everysec and no it returns immediately. It must be called
+// without holding locks that writers need, that is what makes batching possible.
+func (a *AOF) Durable(seq uint64) error {
+	if a.policy != FsyncAlways {
+		return nil
+	}
+	return a.Sync(seq)
+}
+
+// Sync makes every record up to seq durable. Concurrent callers share fsyncs:
+// the first one to arrive flushes and syncs everything appended so far while
+// the others wait, and all of them whose records were covered return together.
+func (a *AOF) Sync(seq uint64) error {
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+
+	for a.synced < seq {
+		if a.err != nil {
+			return a.err
+		}
+		if a.syncing {
+			a.syncCond.Wait()
+			continue
+		}
+
+		a.syncing = true
+		a.syncMu.Unlock()
+		target, err := a.flushAndSync()
+		a.syncMu.Lock()
+		a.syncing = false
+
+		switch {
+		case errors.Is(err, os.ErrClosed):
+			// a snapshot swapped the file under us; it already fsynced the full state, retry on the new file
+		case err != nil:
+			logger.Error("failed to sync AOF", "error", err)
+			a.err = fmt.Errorf("failed to sync AOF: %w", err)
+		case target > a.synced:
+			a.synced = target
+		}
+		a.syncCond.Broadcast()
+	}
+	return a.err
+}
+
+// flushAndSync writes out the buffer and fsyncs. The fsync runs without a.mu
+// so writers keep appending (and queueing up for the next batch) meanwhile.
+func (a *AOF) flushAndSync() (uint64, error) {
+	a.mu.Lock()
+	target := a.seq
+	if err := a.writer.Flush(); err != nil {
+		a.mu.Unlock()
+		return 0, err
+	}
+	file := a.file
+	a.mu.Unlock()
+
+	return target, file.Sync()
+}
+
+// Snapshot rewrites the AOF from the current state and returns once the new file is in place.
+// Writers are only blocked while the store is cloned and while the final tail is appended.
 func (a *AOF) Snapshot(store StoreReader) error {
+	originalPath, err := a.beginRewrite()
+	if err != nil {
+		return err
+	}
+	return a.rewrite(store, originalPath)
+}
+
+// BackgroundRewrite starts a Snapshot in its own goroutine, like BGREWRITEAOF
+func (a *AOF) BackgroundRewrite(store StoreReader) error {
+	originalPath, err := a.beginRewrite()
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := a.rewrite(store, originalPath); err != nil {
+			logger.Error("Background AOF rewrite failed", "error", err)
+		}
+	}()
+	return nil
+}
+
+func (a *AOF) beginRewrite() (string, error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
+	if a.rewriting {
+		return "", ErrRewriteInProgress
+	}
+	a.rewriting = true
+	return a.file.Name(), nil
+}
+
+func (a *AOF) rewrite(store StoreReader, originalPath string) error {
+	done := false
+	// set once the new AOF references the snapshot, from then on it must stay on disk
+	keepSnapshot := false
+	var snapPath string
+	defer func() {
+		if !done {
+			a.mu.Lock()
+			a.rewriting = false
+			a.rewriteBuf = nil
+			a.mu.Unlock()
+		}
+		if snapPath != "" && !keepSnapshot {
+			os.Remove(snapPath)
+		}
+	}()
 
 	logger.Info("Building AOF Snapshot...")
+	start := time.Now()
+
+	// point-in-time copy; from here on appended records are also collected in rewriteBuf
+	snapshot, expiries := store.Fork(func() {
+		a.mu.Lock()
+		a.rewriteBuf = new(bytes.Buffer)
+		a.mu.Unlock()
+	})
+	logger.Debug("AOF rewrite cloned store", "keys", len(snapshot), "elapsed", time.Since(start))
+
+	// every snapshot gets its own name, so whichever step a crash interrupts
+	// the AOF on disk still points at the snapshot its records follow
+	snapName := fmt.Sprintf("%s.%d.rdb", filepath.Base(originalPath), start.UnixNano())
+	snapPath = filepath.Join(filepath.Dir(originalPath), snapName)
+	if err := rdb.WriteFile(snapPath, snapshot, expiries); err != nil {
+		return err
+	}
 
 	// Create a new temp file
-	originalPath := a.file.Name()
 	tempPath := originalPath + ".tmp"
 	tempFile, err := os.Create(tempPath)
 	if err != nil {
@@ -71,13 +309,12 @@ func (a *AOF) Snapshot(store StoreReader) error {
 	}
 	defer tempFile.Close()
 
-	tempWriter := bufio.NewWriter(tempFile)
-
 	// Version control of aof snapshot
 	hdr := AOFHeader{
 		Format:   "aof",
 		Version:  time.Now().UTC().Format(time.RFC3339Nano),
-		Encoding: "json-lines",
+		Encoding: "json-lines+crc32c",
+		Snapshot: snapName,
 	}
 	hd, err := json.Marshal(hdr)
 	if err != nil {
@@ -85,193 +322,20 @@ func (a *AOF) Snapshot(store StoreReader) error {
 		os.Remove(tempPath)
 		return fmt.Errorf("failed to marshal AOF header: %w", err)
 	}
-	if _, err := tempWriter.Write(append(hd, '\n')); err != nil {
+	if _, err := tempFile.Write(append(hd, '\n')); err != nil {
 		tempFile.Close()
 		os.Remove(tempPath)
 		return fmt.Errorf("failed to write AOF header: %w", err)
 	}
 
-	snapshot := store.GetAll()
-	for key, value := range snapshot {
-		// Emit operations according to the value type so replay reconstructs the correct data structures
-		switch value.Type() {
-		case domain.String:
-			op := Operation{Type: "SET", Key: key, ValueType: string(domain.String), Value: string(value.Serialize())}
-			b, err := json.Marshal(op)
-			if err != nil {
-				tempFile.Close()
-				os.Remove(tempPath)
-				return fmt.Errorf("failed to marshal snapshot op: %w", err)
-			}
-			if _, err := tempWriter.Write(append(b, '\n')); err != nil {
-				tempFile.Close()
-				os.Remove(tempPath)
-				return fmt.Errorf("failed to write snapshot: %w", err)
-			}
-
-		case domain.Set:
-			var sv valuepkg.SetValue
-			if err := sv.Deserialize(value.Serialize()); err != nil {
-				// fallback: write as SET with serialized value
-				op := Operation{Type: "SET", Key: key, ValueType: string(domain.Set), Value: string(value.Serialize())}
-				b, _ := json.Marshal(op)
-				if _, err := tempWriter.Write(append(b, '\n')); err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to write snapshot: %w", err)
-				}
-				break
-			}
-			for member := range sv.Data {
-				op := Operation{Type: "SADD", Key: key, ValueType: string(domain.Set), Value: member}
-				b, err := json.Marshal(op)
-				if err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to marshal snapshot op: %w", err)
-				}
-				if _, err := tempWriter.Write(append(b, '\n')); err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to write snapshot: %w", err)
-				}
-			}
-
-		case domain.List:
-			var lv valuepkg.ListValue
-			if err := lv.Deserialize(value.Serialize()); err != nil {
-				op := Operation{Type: "SET", Key: key, ValueType: string(domain.List), Value: string(value.Serialize())}
-				b, _ := json.Marshal(op)
-				if _, err := tempWriter.Write(append(b, '\n')); err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to write snapshot: %w", err)
-				}
-				break
-			}
-			// use RPUSH to preserve order
-			for _, item := range lv.Data {
-				op := Operation{Type: "RPUSH", Key: key, ValueType: string(domain.List), Value: item}
-				b, err := json.Marshal(op)
-				if err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to marshal snapshot op: %w", err)
-				}
-				if _, err := tempWriter.Write(append(b, '\n')); err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to write snapshot: %w", err)
-				}
-			}
-
-		case domain.Queue:
-			var qv valuepkg.QueueValue
-			if err := qv.Deserialize(value.Serialize()); err != nil {
-				op := Operation{Type: "SET", Key: key, ValueType: string(domain.Queue), Value: string(value.Serialize())}
-				b, _ := json.Marshal(op)
-				if _, err := tempWriter.Write(append(b, '\n')); err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to write snapshot: %w", err)
-				}
-				break
-			}
-			for _, item := range qv.Data {
-				op := Operation{Type: "ENQUEUE", Key: key, ValueType: string(domain.Queue), Value: item}
-				b, err := json.Marshal(op)
-				if err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to marshal snapshot op: %w", err)
-				}
-				if _, err := tempWriter.Write(append(b, '\n')); err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to write snapshot: %w", err)
-				}
-			}
-
-		case domain.Stack:
-			var sv valuepkg.StackValue
-			if err := sv.Deserialize(value.Serialize()); err != nil {
-				op := Operation{Type: "SET", Key: key, ValueType: string(domain.Stack), Value: string(value.Serialize())}
-				b, _ := json.Marshal(op)
-				if _, err := tempWriter.Write(append(b, '\n')); err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to write snapshot: %w", err)
-				}
-				break
-			}
-			for _, item := range sv.Data {
-				op := Operation{Type: "PUSH", Key: key, ValueType: string(domain.Stack), Value: item}
-				b, err := json.Marshal(op)
-				if err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to marshal snapshot op: %w", err)
-				}
-				if _, err := tempWriter.Write(append(b, '\n')); err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to write snapshot: %w", err)
-				}
-			}
-
-		case domain.Hashmap:
-			var hv valuepkg.HashmapValue
-			if err := hv.Deserialize(value.Serialize()); err != nil {
-				op := Operation{Type: "SET", Key: key, ValueType: string(domain.Hashmap), Value: string(value.Serialize())}
-				b, _ := json.Marshal(op)
-				if _, err := tempWriter.Write(append(b, '\n')); err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to write snapshot: %w", err)
-				}
-				break
-			}
-			for field, val := range hv.Data {
-				payload := struct {
-					F string `json:"f"`
-					V string `json:"v"`
-				}{F: field, V: val}
-				pdata, _ := json.Marshal(payload)
-				op := Operation{Type: "HSET", Key: key, ValueType: string(domain.Hashmap), Value: string(pdata)}
-				b, err := json.Marshal(op)
-				if err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to marshal snapshot op: %w", err)
-				}
-				if _, err := tempWriter.Write(append(b, '\n')); err != nil {
-					tempFile.Close()
-					os.Remove(tempPath)
-					return fmt.Errorf("failed to write snapshot: %w", err)
-				}
-			}
-
-		default:
-			// fallback to SET if unknown type
-			op := Operation{Type: "SET", Key: key, ValueType: string(value.Type()), Value: string(value.Serialize())}
-			b, err := json.Marshal(op)
-			if err != nil {
-				tempFile.Close()
-				os.Remove(tempPath)
-				return fmt.Errorf("failed to marshal snapshot op: %w", err)
-			}
-			if _, err := tempWriter.Write(append(b, '\n')); err != nil {
-				tempFile.Close()
-				os.Remove(tempPath)
-				return fmt.Errorf("failed to write snapshot: %w", err)
-			}
-		}
-	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	if err := tempWriter.Flush(); err != nil {
+	tail := a.rewriteBuf.Len()
+	if _, err := tempFile.Write(a.rewriteBuf.Bytes()); err != nil {
 		tempFile.Close()
 		os.Remove(tempPath)
-		return err
+		return fmt.Errorf("failed to append rewrite buffer: %w", err)
 	}
 
 	if err := tempFile.Sync(); err != nil {
@@ -300,18 +364,64 @@ func (a *AOF) Snapshot(store StoreReader) error {
 		os.Remove(tempPath)
 		return fmt.Errorf("failed to rename temp AOF: %w", err)
 	}
+	keepSnapshot = true
 
 	a.file, err = os.OpenFile(originalPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to reopen AOF: %w", err)
 	}
 	a.writer = bufio.NewWriter(a.file)
+	syncDir(originalPath)
+	removeStaleSnapshots(originalPath, snapName)
+
+	a.rewriting = false
+	a.rewriteBuf = nil
+	done = true
+
+	// everything appended so far is in the new, fsynced file
+	a.syncMu.Lock()
+	if a.seq > a.synced {
+		a.synced = a.seq
+	}
+	a.syncCond.Broadcast()
+	a.syncMu.Unlock()
 
-	logger.Info("AOF snapshot completed successfully")
+	logger.Info("AOF snapshot completed successfully", "keys", len(snapshot), "snapshot", snapName, "tailBytes", tail, "elapsed", time.Since(start))
 	return nil
 }
 
-func (a *AOF) Write(operation, key, valueType, value string) error {
+// removeStaleSnapshots deletes the snapshots of aofPath other than keep,
+// left behind by earlier rewrites or by one that crashed half way
+func removeStaleSnapshots(aofPath, keep string) {
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(aofPath), filepath.Base(aofPath)+".*.rdb"))
+	if err != nil {
+		return
+	}
+	for _, path := range matches {
+		if filepath.Base(path) == keep {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			logger.Warn("failed to remove stale snapshot", "path", path, "error", err)
+		}
+	}
+}
+
+// syncDir fsyncs the directory holding path so a rename survives power loss
+func syncDir(path string) {
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		logger.Warn("failed to sync AOF directory", "error", err)
+	}
+}
+
+// Write appends an operation and returns its sequence number. The record is not
+// necessarily durable yet, pass the sequence number to Durable for that.
+func (a *AOF) Write(operation, key, valueType, value string) (uint64, error) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
@@ -324,108 +434,156 @@ func (a *AOF) Write(operation, key, valueType, value string) error {
 	b, err := json.Marshal(op)
 	if err != nil {
 		logger.Error("failed to marshal AOF operation", "error", err)
-		return fmt.Errorf("failed to marshal AOF operation: %w", err)
+		return 0, fmt.Errorf("failed to marshal AOF operation: %w", err)
 	}
 
 	logger.Debug("writing to AOF", "operation", operation, "key", key, "valueType", valueType)
+	return a.append(b)
+}
 
-	if _, err := a.writer.Write(append(b, '\n')); err != nil {
-		logger.Error("failed to write to AOF", "error", err)
-		return fmt.Errorf("failed to write to AOF: %w", err)
+// append writes encoded records framed with their checksum; with always the buffer is left for the
+// group commit to flush, otherwise it goes to the OS right away. Requires a.mu.
+func (a *AOF) append(records ...[]byte) (uint64, error) {
+	if err := a.failed(); err != nil {
+		return 0, err
 	}
+	for _, b := range records {
+		rec := FrameRecord(b)
+		if _, err := a.writer.Write(rec); err != nil {
+			logger.Error("failed to write to AOF", "error", err)
+			return 0, fmt.Errorf("failed to write to AOF: %w", err)
+		}
+		if a.rewriteBuf != nil {
+			a.rewriteBuf.Write(rec)
+		}
+	}
+	a.seq++
 
-	if err := a.writer.Flush(); err != nil {
-		logger.Error("failed to flush to AOF", "error", err)
-		return fmt.Errorf("failed to flush to AOF: %w", err)
+	if a.policy != FsyncAlways {
+		if err := a.writer.Flush(); err != nil {
+			logger.Error("failed to flush to AOF", "error", err)
+			return 0, fmt.Errorf("failed to flush to AOF: %w", err)
+		}
 	}
+	return a.seq, nil
+}
 
-	if err := a.file.Sync(); err != nil {
-		logger.Error("failed to sync AOF", "error", err)
-		return fmt.Errorf("failed to sync AOF: %w", err)
+func (a *AOF) failed() error {
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+	return a.err
+}
+
+// WriteBatch appends ops wrapped in MULTI/EXEC markers under one sequence number.
+// Read only returns a group once its EXEC marker is present, so a batch is replayed fully or not at all.
+func (a *AOF) WriteBatch(ops []Operation) (uint64, error) {
+	if len(ops) == 0 {
+		return 0, nil
 	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	return nil
+	group := make([]Operation, 0, len(ops)+2)
+	group = append(group, Operation{Type: "MULTI"})
+	group = append(group, ops...)
+	group = append(group, Operation{Type: "EXEC"})
+
+	records := make([][]byte, 0, len(group))
+	for _, op := range group {
+		b, err := json.Marshal(op)
+		if err != nil {
+			logger.Error("failed to marshal AOF operation", "error", err)
+			return 0, fmt.Errorf("failed to marshal AOF operation: %w", err)
+		}
+		records = append(records, b)
+	}
+	logger.Debug("writing transaction to AOF", "operations", len(ops))
+	return a.append(records...)
 }
 
 func (a *AOF) Close() error {
+	if a.stop != nil {
+		close(a.stop)
+		<-a.done
+	}
+
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
 	if err := a.writer.Flush(); err != nil {
 		return err
 	}
-
+	if a.policy != FsyncNo {
+		if err := a.file.Sync(); err != nil {
+			return err
+		}
+	}
 	return a.file.Close()
 }
 
-func (a *AOF) Read(filepath string) ([]Operation, error) {
-	file, err := os.Open(filepath)
+// Read returns the header and the operations of the AOF at filepath. When the
+// header names a snapshot, the operations are only those written after it.
+// A torn or corrupt last record is cut off or refused according to the tail policy;
+// corruption with readable records after it is always an error.
+func (a *AOF) Read(filepath string) (AOFHeader, []Operation, error) {
+	var operations []Operation
+	res, err := scan(filepath, func(op Operation) {
+		operations = append(operations, op)
+	})
 	if err != nil {
 		if os.IsNotExist(err) {
 			logger.Info("AOF file not found, starting fresh", "filepath", filepath)
-			return []Operation{}, nil
+			return AOFHeader{}, []Operation{}, nil
 		}
-		logger.Info("AOF file not found, starting fresh", "filepath", filepath)
-		return nil, fmt.Errorf("failed to open AOF for reading: %w", err)
+		logger.Error("error reading AOF", "error", err)
+		return AOFHeader{}, nil, fmt.Errorf("error reading AOF: %w", err)
+	}
+	if res.Header.Format != "" {
+		logger.Info("AOF header detected", "version", res.Header.Version, "snapshot", res.Header.Snapshot)
 	}
-	defer file.Close()
-
-	var operations []Operation
-	scanner := bufio.NewScanner(file)
-
-	lineNum := 0
-	firstLine := true
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
-
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
 
-		if firstLine {
-			firstLine = false
-			trim := strings.TrimSpace(line)
-			if strings.HasPrefix(trim, "{") {
-				var hdr AOFHeader
-				if err := json.Unmarshal([]byte(trim), &hdr); err == nil && hdr.Format == "aof" {
-					logger.Info("AOF header detected", "version", hdr.Version)
-					continue
-				}
-			}
+	if c := res.Corruption; c != nil {
+		logger.Error("corrupt AOF record", "line", c.Line, "offset", c.Offset, "error", c.Reason, "tail", c.Tail)
+		if !c.Tail {
+			return res.Header, nil, fmt.Errorf("corrupt AOF record at line %d: %s; valid records follow, run aof-check", c.Line, c.Reason)
 		}
-
-		op, err := parseOperation(line)
-		if err != nil {
-			logger.Error("failed to parse AOF line", "line", lineNum, "error", err, "content", line)
-			return nil, fmt.Errorf("failed to parse line %d: %w", lineNum, err)
+		if a.tailPolicy == TailRefuse {
+			return res.Header, nil, fmt.Errorf("truncated AOF tail at line %d: %s; refusing to load (AOF_LOAD_TRUNCATED=refuse), run aof-check --fix", c.Line, c.Reason)
 		}
-		operations = append(operations, op)
+		logger.Warn("truncating AOF to the last good record", "offset", res.Valid, "discardedBytes", res.Size-res.Valid)
 	}
-
-	if err := scanner.Err(); err != nil {
-		logger.Error("error reading AOF", "error", err)
-		return nil, fmt.Errorf("error reading AOF: %w", err)
+	if res.Pending > 0 {
+		// cut the torn group off so records appended from now on are not swallowed into it
+		logger.Warn("discarding incomplete transaction at end of AOF", "operations", res.Pending)
 	}
+	if err := res.repair(filepath); err != nil {
+		return res.Header, nil, err
+	}
+
 	logger.Info("AOF loaded successfully", "operationsCount", len(operations))
-	return operations, nil
+	return res.Header, operations, nil
 }
 
 func parseOperation(line string) (Operation, error) {
 	trimmed := strings.TrimSpace(line)
 	logger.Debug("parsing operation", "line", line)
 
-	// Try JSON (new format) first
+	// JSON records, optionally followed by their checksum
 	if strings.HasPrefix(trimmed, "{") {
+		body, err := VerifyRecord(trimmed)
+		if err != nil {
+			return Operation{}, err
+		}
 		var op Operation
-		if err := json.Unmarshal([]byte(trimmed), &op); err == nil {
-			if op.Type == "" || op.Key == "" {
-				return Operation{}, fmt.Errorf("invalid JSON operation: %s", line)
-			}
-			logger.Debug("operation parsed (json)", "type", op.Type, "key", op.Key, "valueType", op.ValueType, "valueLength", len(op.Value))
-			return op, nil
+		// a JSON line that does not decode is a damaged record, not a legacy one
+		if err := json.Unmarshal([]byte(body), &op); err != nil {
+			return Operation{}, fmt.Errorf("invalid JSON operation: %w", err)
+		}
+		if op.Type == "" || (op.Key == "" && op.Type != "MULTI" && op.Type != "EXEC") {
+			return Operation{}, fmt.Errorf("invalid JSON operation: %s", line)
 		}
-		logger.Debug("failed to unmarshal JSON AOF op, falling back to legacy parser")
+		logger.Debug("operation parsed (json)", "type", op.Type, "key", op.Key, "valueType", op.ValueType, "valueLength", len(op.Value))
+		return op, nil
 	}
 
 	// legacy format: split by spaces into max 4 parts
@@ -452,7 +610,7 @@ func parseOperation(line string) (Operation, error) {
 	}
 
 	if op.Type == "SET" {
-		if parts[2] == "" || parts[3] == "" {
+		if valueType == "" || value == "" {
 			return Operation{}, fmt.Errorf("SET operation missing value type or value: %s", line)
 		}
 	}
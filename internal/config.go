@@ -2,26 +2,88 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 )
 
 type Config struct {
 	Port                     string
+	RespPort                 string
 	LogLevel                 string
 	AOF_FILENAME             string
+	AppendFsync              string
+	AOFLoadTruncated         string
 	AWS_LAMBDA_FUNCTION_NAME string
+	MaxMemory                int64
+	MaxMemoryPolicy          string
+	PubSubBuffer             int
+	ReplicaOf                string
+	ReplBacklog              int
+	RaftID                   string
+	RaftPeers                string
+	RaftDir                  string
+	RaftElectionTimeoutMs    int
+	RaftSnapshotThreshold    int
+	ProxyPort                string
+	ShardNodes               string
+	SlotsFile                string
 }
 
 func LoadConfig() *Config {
 	port := getEnv("PORT", "3000")
+	respPort := getEnv("RESP_PORT", "6379")
 	logLevel := getEnv("LOG_LEVEL", "info")
 	filename := getEnv("AOF_FILENAME", "database.aof")
+	appendFsync := getEnv("APPENDFSYNC", "always")
+	aofLoadTruncated := getEnv("AOF_LOAD_TRUNCATED", "truncate")
 	aws_lambda_name := getEnv("AWS_LAMBDA_FUNCTION_NAME", "")
+	maxMemory := parseBytes(getEnv("MAXMEMORY", "0"))
+	maxMemoryPolicy := getEnv("MAXMEMORY_POLICY", "noeviction")
+	pubSubBuffer, err := strconv.Atoi(getEnv("PUBSUB_BUFFER", "256"))
+	if err != nil || pubSubBuffer <= 0 {
+		pubSubBuffer = 256
+	}
+	replicaOf := getEnv("REPLICAOF", "")
+	replBacklog, err := strconv.Atoi(getEnv("REPL_BACKLOG", "10000"))
+	if err != nil || replBacklog <= 0 {
+		replBacklog = 10000
+	}
+	raftID := getEnv("RAFT_ID", "")
+	raftPeers := getEnv("RAFT_PEERS", "")
+	raftDir := getEnv("RAFT_DIR", "raft")
+	raftElectionTimeout, err := strconv.Atoi(getEnv("RAFT_ELECTION_TIMEOUT", "1000"))
+	if err != nil || raftElectionTimeout <= 0 {
+		raftElectionTimeout = 1000
+	}
+	raftSnapshotThreshold, err := strconv.Atoi(getEnv("RAFT_SNAPSHOT_THRESHOLD", "10000"))
+	if err != nil || raftSnapshotThreshold <= 0 {
+		raftSnapshotThreshold = 10000
+	}
+	proxyPort := getEnv("PROXY_PORT", "4000")
+	shardNodes := getEnv("SHARD_NODES", "")
+	slotsFile := getEnv("SLOTS_FILE", "slots.json")
 
 	return &Config{
 		Port:                     port,
+		RespPort:                 respPort,
 		LogLevel:                 logLevel,
 		AOF_FILENAME:             filename,
+		AppendFsync:              appendFsync,
+		AOFLoadTruncated:         aofLoadTruncated,
 		AWS_LAMBDA_FUNCTION_NAME: aws_lambda_name,
+		MaxMemory:                maxMemory,
+		MaxMemoryPolicy:          maxMemoryPolicy,
+		PubSubBuffer:             pubSubBuffer,
+		ReplicaOf:                replicaOf,
+		ReplBacklog:              replBacklog,
+		RaftID:                   raftID,
+		RaftPeers:                raftPeers,
+		RaftDir:                  raftDir,
+		RaftElectionTimeoutMs:    raftElectionTimeout,
+		RaftSnapshotThreshold:    raftSnapshotThreshold,
+		ProxyPort:                proxyPort,
+		ShardNodes:               shardNodes,
+		SlotsFile:                slotsFile,
 	}
 }
 
@@ -31,3 +93,25 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// parseBytes accepts plain byte counts or kb/mb/gb suffixes (e.g. "256mb").
+// Invalid values disable the limit.
+func parseBytes(v string) int64 {
+	v = strings.ToLower(strings.TrimSpace(v))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		mult   int64
+	}{{"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10}, {"b", 1}} {
+		if strings.HasSuffix(v, unit.suffix) {
+			v = strings.TrimSuffix(v, unit.suffix)
+			multiplier = unit.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n * multiplier
+}
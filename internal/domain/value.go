@@ -3,16 +3,22 @@ package domain
 type DataType string
 
 const (
-	String  DataType = "string"
-	Set     DataType = "set"
-	List    DataType = "list"
-	Queue   DataType = "queue"
-	Stack   DataType = "stack"
-	Hashmap DataType = "hashmap"
+	String        DataType = "string"
+	Set           DataType = "set"
+	List          DataType = "list"
+	Queue         DataType = "queue"
+	Stack         DataType = "stack"
+	Hashmap       DataType = "hashmap"
+	SortedSet     DataType = "sortedset"
+	PriorityQueue DataType = "priorityqueue"
 )
 
 type Value interface {
 	Type() DataType
 	Serialize() []byte
 	Deserialize([]byte) error
+	// Size returns an approximate in-memory footprint in bytes
+	Size() int64
+	// Clone returns a deep copy, used to take point-in-time views of the store
+	Clone() Value
 }
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+type BlockingPopRequest struct {
+	// Keys are tried in order; Key is a shorthand for a single key
+	Keys []string `json:"keys"`
+	Key  string   `json:"key"`
+	// Timeout is in seconds, 0 waits until an element arrives
+	Timeout float64 `json:"timeout"`
+}
+
+func (h *Handler) BLPop(c *fiber.Ctx) error {
+	return h.blockingPop(c, "BLPOP", store.PopListLeft)
+}
+
+func (h *Handler) BRPop(c *fiber.Ctx) error {
+	return h.blockingPop(c, "BRPOP", store.PopListRight)
+}
+
+func (h *Handler) BDequeue(c *fiber.Ctx) error {
+	return h.blockingPop(c, "BDEQUEUE", store.PopQueue)
+}
+
+func (h *Handler) BPop(c *fiber.Ctx) error {
+	return h.blockingPop(c, "BPOP", store.PopStack)
+}
+
+// blockingPop long-polls until one of the keys has an element or the timeout
+// elapses, in which case key and value are null
+func (h *Handler) blockingPop(c *fiber.Ctx, name string, kind store.PopKind) error {
+	var req BlockingPopRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse "+name+" request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body."})
+	}
+	keys := req.Keys
+	if req.Key != "" {
+		keys = append([]string{req.Key}, keys...)
+	}
+	if len(keys) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key or keys are required"})
+	}
+	if req.Timeout < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "timeout is negative"})
+	}
+
+	timeout := time.Duration(req.Timeout * float64(time.Second))
+	// the request context of fasthttp only ends when the server shuts down, so the
+	// pop waits on the hijacked connection instead, where a client that goes away
+	// is noticed and the element it would have been handed is put back
+	c.Context().HijackSetNoResponse(true)
+	c.Context().Hijack(func(conn net.Conn) {
+		h.serveBlockingPop(conn, name, kind, keys, timeout)
+	})
+	return nil
+}
+
+func (h *Handler) serveBlockingPop(conn net.Conn, name string, kind store.PopKind, keys []string, timeout time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		// a waiting client sends nothing, reads only end once it is gone or the
+		// connection is closed after the reply
+		buf := make([]byte, 512)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	key, value, ok, err := h.Store.BlockingPop(ctx, kind, keys, timeout)
+	popped := ok && err == nil
+	if ctx.Err() != nil {
+		if popped {
+			h.putBack(name, kind, key, value, ctx.Err())
+		}
+		return
+	}
+
+	status, reply := fiber.StatusOK, fiber.Map{"status": "success", "message": "timeout", "key": nil, "value": nil}
+	switch {
+	case errors.Is(err, store.ErrMoved):
+		// a proxy retries the request against the slot's new owner
+		status, reply = fiber.StatusMisdirectedRequest, fiber.Map{"status": "error", "message": err.Error()}
+	case err != nil:
+		logger.Warn(name+" failed", "keys", keys, "error", err)
+		status, reply = fiber.StatusBadRequest, fiber.Map{"status": "error", "message": err.Error()}
+	case ok:
+		reply = fiber.Map{"status": "success", "message": "ok", "key": key, "value": value}
+	}
+	if err := writeReply(conn, status, reply); err != nil {
+		if popped {
+			h.putBack(name, kind, key, value, err)
+		}
+		return
+	}
+	if popped {
+		logger.Info(name+" success", "key", key, "value", value)
+	}
+}
+
+// putBack returns a popped element whose client could not be given it
+func (h *Handler) putBack(name string, kind store.PopKind, key, value string, cause error) {
+	logger.Warn(name+" client went away, putting the element back", "key", key, "error", cause)
+	if err := h.Store.Unpop(kind, key, value); err != nil {
+		logger.Error("Failed to put back "+name+" element", "key", key, "error", err)
+	}
+}
+
+// writeReply writes a JSON response to a hijacked connection, which is closed afterwards
+func writeReply(conn net.Conn, status int, reply fiber.Map) error {
+	body, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\nContent-Type: application/json\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s",
+		status, utils.StatusMessage(status), len(body), body)
+	return err
+}
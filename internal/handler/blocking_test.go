@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/pubsub"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+// serve runs the BLPOP route of a fresh store on a loopback listener
+func serve(t *testing.T) (*store.Store, string) {
+	t.Helper()
+	db := store.NewStore()
+	h := NewHandler(db, pubsub.NewBroker(16))
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Post("/BLPOP", h.BLPop)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+	return db, ln.Addr().String()
+}
+
+// blPop sends a BLPOP with body on a new connection without reading the reply
+func blPop(t *testing.T, addr, body string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	req := "POST /BLPOP HTTP/1.1\r\nHost: test\r\nContent-Type: application/json\r\nContent-Length: " +
+		strconv.Itoa(len(body)) + "\r\n\r\n" + body
+	if _, err := io.WriteString(conn, req); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return conn
+}
+
+func TestBlockingPopRepliesOnceAnElementArrives(t *testing.T) {
+	db, addr := serve(t)
+	conn := blPop(t, addr, `{"key":"q","timeout":0}`)
+	defer conn.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	if _, err := db.RPush("q", "job"); err != nil {
+		t.Fatalf("RPush: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("ReadResponse: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != `{"key":"q","message":"ok","status":"success","value":"job"}` {
+		t.Fatalf("reply = %d %s", resp.StatusCode, body)
+	}
+}
+
+// A client that hangs up stops waiting, and an element popped for it is not lost
+func TestBlockingPopStopsWhenClientGoesAway(t *testing.T) {
+	db, addr := serve(t)
+	conn := blPop(t, addr, `{"key":"q","timeout":0}`)
+	time.Sleep(50 * time.Millisecond)
+	conn.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	if _, err := db.RPush("q", "job"); err != nil {
+		t.Fatalf("RPush: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		got, _ := db.LRange("q", 0, -1)
+		if len(got) == 1 && got[0] == "job" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("list = %v, want [job]", got)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"math"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+)
+
+// --- Counter Operations ---
+
+type IncrRequest struct {
+	Key   string `json:"key"`
+	Field string `json:"field,omitempty"` // only used by HINCRBY
+	// Increment is used by INCRBY and HINCRBY, Decrement by DECRBY
+	Increment int64 `json:"increment"`
+	Decrement int64 `json:"decrement"`
+}
+
+type IncrByFloatRequest struct {
+	Key       string  `json:"key"`
+	Field     string  `json:"field,omitempty"` // only used by HINCRBYFLOAT
+	Increment float64 `json:"increment"`
+}
+
+func (h *Handler) Incr(c *fiber.Ctx) error {
+	return h.incrBy(c, "INCR", func(IncrRequest) (int64, bool) { return 1, true })
+}
+
+func (h *Handler) Decr(c *fiber.Ctx) error {
+	return h.incrBy(c, "DECR", func(IncrRequest) (int64, bool) { return -1, true })
+}
+
+func (h *Handler) IncrBy(c *fiber.Ctx) error {
+	return h.incrBy(c, "INCRBY", func(req IncrRequest) (int64, bool) { return req.Increment, true })
+}
+
+func (h *Handler) DecrBy(c *fiber.Ctx) error {
+	return h.incrBy(c, "DECRBY", func(req IncrRequest) (int64, bool) {
+		return -req.Decrement, req.Decrement != math.MinInt64
+	})
+}
+
+// incrBy adds the delta picked from the request to a string key; ok=false means the delta overflows
+func (h *Handler) incrBy(c *fiber.Ctx, name string, delta func(req IncrRequest) (int64, bool)) error {
+	var req IncrRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse "+name+" request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+	d, ok := delta(req)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "decrement would overflow"})
+	}
+
+	n, err := h.Store.IncrBy(req.Key, d)
+	if err != nil {
+		logger.Warn(name+" failed", "key", req.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info(name+" success", "key", req.Key, "value", n)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "value": n})
+}
+
+func (h *Handler) IncrByFloat(c *fiber.Ctx) error {
+	var req IncrByFloatRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse INCRBYFLOAT request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	f, err := h.Store.IncrByFloat(req.Key, req.Increment)
+	if err != nil {
+		logger.Warn("INCRBYFLOAT failed", "key", req.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("INCRBYFLOAT success", "key", req.Key, "value", f)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "value": f})
+}
+
+func (h *Handler) HIncrBy(c *fiber.Ctx) error {
+	var req IncrRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse HINCRBY request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" || req.Field == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and field are required"})
+	}
+
+	n, err := h.Store.HIncrBy(req.Key, req.Field, req.Increment)
+	if err != nil {
+		logger.Warn("HINCRBY failed", "key", req.Key, "field", req.Field, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("HINCRBY success", "key", req.Key, "field", req.Field, "value", n)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "value": n})
+}
+
+func (h *Handler) HIncrByFloat(c *fiber.Ctx) error {
+	var req IncrByFloatRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse HINCRBYFLOAT request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" || req.Field == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and field are required"})
+	}
+
+	f, err := h.Store.HIncrByFloat(req.Key, req.Field, req.Increment)
+	if err != nil {
+		logger.Warn("HINCRBYFLOAT failed", "key", req.Key, "field", req.Field, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("HINCRBYFLOAT success", "key", req.Key, "field", req.Field, "value", f)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "value": f})
+}
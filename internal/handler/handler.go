@@ -1,20 +1,42 @@
 package handler
 
 import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/mrpurushotam/mini_db/internal/domain"
 	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/pubsub"
+	"github.com/mrpurushotam/mini_db/internal/raft"
+	"github.com/mrpurushotam/mini_db/internal/replication"
 	"github.com/mrpurushotam/mini_db/internal/store"
 	valuepkg "github.com/mrpurushotam/mini_db/internal/value"
 )
 
 type Handler struct {
-	Store *store.Store
+	Store  *store.Store
+	Broker *pubsub.Broker
+	// at most one of Leader and Follower is set, depending on the replication role
+	Leader   *replication.Leader
+	Follower *replication.Follower
+	// Raft is set in cluster mode
+	Raft *raft.Node
+
+	pubSubSocket fiber.Handler
 }
 
 type KeyValue struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
+	TTL   int64  `json:"ttl,omitempty"` // seconds, only used by SET
+	PX    int64  `json:"px,omitempty"`  // milliseconds, only used by SET
+	// Encoding "base64" marks a base64 encoded value, only used by SET
+	Encoding string `json:"encoding,omitempty"`
+	// Delay (milliseconds) or RunAt (unix time in milliseconds) hold an ENQUEUE back until then
+	Delay int64 `json:"delay,omitempty"`
+	RunAt int64 `json:"runAt,omitempty"`
 }
 
 type SetKeyValue struct {
@@ -22,8 +44,10 @@ type SetKeyValue struct {
 	Members []string `json:"value"`
 }
 
-func NewHandler(store *store.Store) *Handler {
-	return &Handler{Store: store}
+func NewHandler(store *store.Store, broker *pubsub.Broker) *Handler {
+	h := &Handler{Store: store, Broker: broker}
+	h.pubSubSocket = websocket.New(h.servePubSub)
+	return h
 }
 
 func (h *Handler) Set(c *fiber.Ctx) error {
@@ -34,24 +58,49 @@ func (h *Handler) Set(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid body."})
 	}
 
-	h.Store.Set(kv.Key, kv.Value)
-	logger.Info("Key set successfully", "key", kv.Key, "value", kv.Value)
+	if kv.TTL < 0 || kv.PX < 0 {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "ttl must be positive"})
+	}
+	ttl := time.Duration(kv.TTL) * time.Second
+	if kv.PX > 0 {
+		ttl = time.Duration(kv.PX) * time.Millisecond
+	}
+	value, err := decodeValue(kv.Value, kv.Encoding)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	if err := h.Store.SetWithTTL(kv.Key, value, ttl); err != nil {
+		logger.Warn("SET failed", "key", kv.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	logger.Info("Key set successfully", "key", kv.Key, "value", kv.Value, "ttl", ttl)
 	return c.Status(200).JSON(fiber.Map{"status": "success", "message": "ok"})
 }
 
+// Get responds with the value at ?key=k, base64 encoded with &encoding=base64
 func (h *Handler) Get(c *fiber.Ctx) error {
 	key := c.Query("key")
+	encoding := c.Query("encoding")
+	if err := checkEncoding(encoding); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
 	value, exists := h.Store.Get(key)
 	if !exists {
 		logger.Warn("Key not found during Get operation", "key", key)
 		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Not found"})
 	}
 	logger.Info("Key retrieved successfully", "key", key)
-	return c.Status(200).JSON(fiber.Map{"status": "success", "value": value})
+	return c.Status(200).JSON(fiber.Map{"status": "success", "value": encodeValue(value, encoding)})
 }
 
+// GetAll returns one page of keys with their values, see Scan for the paging parameters
 func (h *Handler) GetAll(c *fiber.Ctx) error {
-	raw := h.Store.GetAll()
+	cursor, opts, err := parseScanOptions(c, allPageSize)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	raw, next := h.Store.ScanValues(cursor, opts)
 	values := make(map[string]interface{}, len(raw))
 	for k, v := range raw {
 		switch v.Type() {
@@ -96,22 +145,48 @@ func (h *Handler) GetAll(c *fiber.Ctx) error {
 			} else {
 				values[k] = string(v.Serialize())
 			}
+		case domain.SortedSet:
+			var zv valuepkg.SortedSetValue
+			if err := zv.Deserialize(v.Serialize()); err == nil {
+				values[k] = zv.Members()
+			} else {
+				values[k] = string(v.Serialize())
+			}
+		case domain.PriorityQueue:
+			var pv valuepkg.PriorityQueueValue
+			if err := pv.Deserialize(v.Serialize()); err == nil {
+				values[k] = pv.Items()
+			} else {
+				values[k] = string(v.Serialize())
+			}
 		default:
 			values[k] = string(v.Serialize())
 		}
 	}
 	logger.Info("Retrieved all values", "count", len(values))
-	return c.Status(200).JSON(fiber.Map{"status": "success", "message": "Value fetched.", "values": values})
+	return c.Status(200).JSON(fiber.Map{"status": "success", "message": "Value fetched.", "cursor": formatCursor(next), "values": values})
 }
 
 func (h *Handler) GetAllKeys(c *fiber.Ctx) error {
-	keys := h.Store.GetAllKeys()
+	cursor, opts, err := parseScanOptions(c, allPageSize)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	keys, next := h.Store.Scan(cursor, opts)
 	logger.Info("Retrieved all keys", "count", len(keys))
-	return c.Status(200).JSON(fiber.Map{"status": "success", "keys": keys})
+	return c.Status(200).JSON(fiber.Map{"status": "success", "cursor": formatCursor(next), "keys": keys})
 }
 
 func (h *Handler) GetAllValues(c *fiber.Ctx) error {
-	raw := h.Store.GetAllValues()
+	cursor, opts, err := parseScanOptions(c, allPageSize)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	page, next := h.Store.ScanValues(cursor, opts)
+	raw := make([]domain.Value, 0, len(page))
+	for _, v := range page {
+		raw = append(raw, v)
+	}
 	values := make([]interface{}, 0, len(raw))
 	for _, v := range raw {
 		switch v.Type() {
@@ -156,12 +231,26 @@ func (h *Handler) GetAllValues(c *fiber.Ctx) error {
 			} else {
 				values = append(values, string(v.Serialize()))
 			}
+		case domain.SortedSet:
+			var zv valuepkg.SortedSetValue
+			if err := zv.Deserialize(v.Serialize()); err == nil {
+				values = append(values, zv.Members())
+			} else {
+				values = append(values, string(v.Serialize()))
+			}
+		case domain.PriorityQueue:
+			var pv valuepkg.PriorityQueueValue
+			if err := pv.Deserialize(v.Serialize()); err == nil {
+				values = append(values, pv.Items())
+			} else {
+				values = append(values, string(v.Serialize()))
+			}
 		default:
 			values = append(values, string(v.Serialize()))
 		}
 	}
 	logger.Info("Retrieved all values (only values)", "count", len(values))
-	return c.Status(200).JSON(fiber.Map{"status": "success", "values": values})
+	return c.Status(200).JSON(fiber.Map{"status": "success", "cursor": formatCursor(next), "values": values})
 }
 
 func (h *Handler) Delete(c *fiber.Ctx) error {
@@ -190,7 +279,7 @@ func (h *Handler) SAdd(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Key and value are required"})
 	}
 
-	if err := h.Store.SAdd(req.Key, req.Members...); err != nil {
+	if _, err := h.Store.SAdd(req.Key, req.Members...); err != nil {
 		logger.Warn("SADD failed", "key", req.Key, "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
 	}
@@ -250,13 +339,14 @@ func (h *Handler) LPush(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Key and value are required"})
 	}
 
-	if err := h.Store.LPush(req.Key, req.Value...); err != nil {
+	length, err := h.Store.LPush(req.Key, req.Value...)
+	if err != nil {
 		logger.Warn("LPUSH failed", "key", req.Key, "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
 	}
 
 	logger.Info("LPUSH success", "key", req.Key, "count", len(req.Value))
-	return c.Status(200).JSON(fiber.Map{"status": "success", "message": "ok"})
+	return c.Status(200).JSON(fiber.Map{"status": "success", "message": "ok", "length": length})
 }
 
 func (h *Handler) RPush(c *fiber.Ctx) error {
@@ -270,13 +360,14 @@ func (h *Handler) RPush(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Key and value are required"})
 	}
 
-	if err := h.Store.RPush(req.Key, req.Value...); err != nil {
+	length, err := h.Store.RPush(req.Key, req.Value...)
+	if err != nil {
 		logger.Warn("RPUSH failed", "key", req.Key, "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
 	}
 
 	logger.Info("RPUSH success", "key", req.Key, "count", len(req.Value))
-	return c.Status(200).JSON(fiber.Map{"status": "success", "message": "ok"})
+	return c.Status(200).JSON(fiber.Map{"status": "success", "message": "ok", "length": length})
 }
 
 func (h *Handler) LRange(c *fiber.Ctx) error {
@@ -311,7 +402,20 @@ func (h *Handler) Enqueue(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Key and value are required"})
 	}
 
-	if err := h.Store.Enqueue(req.Key, req.Value); err != nil {
+	if req.Delay < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "delay is negative"})
+	}
+
+	var err error
+	switch {
+	case req.RunAt > 0:
+		err = h.Store.EnqueueAt(req.Key, req.Value, time.UnixMilli(req.RunAt))
+	case req.Delay > 0:
+		err = h.Store.EnqueueAt(req.Key, req.Value, time.Now().Add(time.Duration(req.Delay)*time.Millisecond))
+	default:
+		err = h.Store.Enqueue(req.Key, req.Value)
+	}
+	if err != nil {
 		logger.Warn("ENQUEUE failed", "key", req.Key, "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
 	}
@@ -390,7 +494,7 @@ func (h *Handler) HSet(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and field are required"})
 	}
 
-	if err := h.Store.HSet(req.Key, req.Field, req.Value); err != nil {
+	if _, err := h.Store.HSet(req.Key, req.Field, req.Value); err != nil {
 		logger.Warn("HSET failed", "key", req.Key, "field", req.Field, "error", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
 	}
@@ -432,6 +536,139 @@ func (h *Handler) HGetAll(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "map": m})
 }
 
+// --- Expiry Operations ---
+
+type ExpireRequest struct {
+	Key string `json:"key"`
+	TTL int64  `json:"ttl"` // seconds
+	PX  int64  `json:"px"`  // milliseconds, takes precedence over ttl
+}
+
+func (h *Handler) Expire(c *fiber.Ctx) error {
+	var req ExpireRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse EXPIRE request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	ttl := time.Duration(req.TTL) * time.Second
+	if req.PX != 0 {
+		ttl = time.Duration(req.PX) * time.Millisecond
+	}
+
+	ok, err := h.Store.Expire(req.Key, ttl)
+	if err != nil {
+		logger.Warn("EXPIRE failed", "key", req.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key not found"})
+	}
+
+	logger.Info("EXPIRE success", "key", req.Key, "ttl", ttl)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok"})
+}
+
+// TTL follows redis conventions: -2 when the key does not exist, -1 when it has no expiry
+func (h *Handler) TTL(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	remaining, hasExpiry, exists := h.Store.TTL(key)
+	ttl, pttl := int64(-2), int64(-2)
+	if exists {
+		ttl, pttl = -1, -1
+		if hasExpiry {
+			pttl = remaining.Milliseconds()
+			// round up like redis so a key with 500ms left does not report 0
+			ttl = (pttl + 999) / 1000
+		}
+	}
+
+	logger.Info("TTL success", "key", key, "ttl", ttl)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "ttl": ttl, "pttl": pttl})
+}
+
+func (h *Handler) Persist(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	ok, err := h.Store.Persist(key)
+	if err != nil {
+		logger.Warn("PERSIST failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("PERSIST success", "key", key, "persisted", ok)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "persisted": ok})
+}
+
+// --- Transactions ---
+
+type TxRequest struct {
+	// Watch maps keys to the versions read from /version; the transaction aborts if any changed
+	Watch    map[string]uint64 `json:"watch"`
+	Commands []store.Command   `json:"commands"`
+}
+
+func (h *Handler) Tx(c *fiber.Ctx) error {
+	var req TxRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse TX request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if len(req.Commands) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "commands are required"})
+	}
+
+	results, err := h.Store.Exec(req.Watch, req.Commands)
+	if errors.Is(err, store.ErrTxAborted) {
+		logger.Info("TX aborted, watched key changed")
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	if err != nil && results == nil {
+		logger.Warn("TX rejected", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	out := make([]fiber.Map, len(results))
+	for i, r := range results {
+		if r.Err != nil {
+			out[i] = fiber.Map{"error": r.Err.Error()}
+		} else {
+			out[i] = fiber.Map{"value": r.Value}
+		}
+	}
+	if err != nil {
+		logger.Error("TX executed but AOF write failed", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": err.Error(), "results": out})
+	}
+
+	logger.Info("TX success", "commands", len(req.Commands))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "results": out})
+}
+
+func (h *Handler) Version(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "version": h.Store.Version(key)})
+}
+
+func (h *Handler) Stats(c *fiber.Ctx) error {
+	stats := h.Store.Stats()
+	logger.Info("Stats retrieved", "keys", stats.Keys, "usedMemory", stats.UsedMemory)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "stats": stats})
+}
+
 func (h *Handler) Snapshot(c *fiber.Ctx) error {
 	if err := h.Store.Snapshot(); err != nil {
 		logger.Error("Failed to create AOF snapshot", "error", err)
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+// --- Hashmap Fields ---
+
+type HMSetRequest struct {
+	Key    string            `json:"key"`
+	Fields map[string]string `json:"fields"`
+}
+
+type HDelRequest struct {
+	Key    string   `json:"key"`
+	Fields []string `json:"fields"`
+}
+
+type HExpireRequest struct {
+	Key    string   `json:"key"`
+	Fields []string `json:"fields"`
+	TTL    int64    `json:"ttl"` // seconds
+	PX     int64    `json:"px"`  // milliseconds, takes precedence over ttl
+}
+
+// HMSet sets several fields in one write and responds with how many were added
+func (h *Handler) HMSet(c *fiber.Ctx) error {
+	var req HMSetRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse HMSET request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" || len(req.Fields) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and fields are required"})
+	}
+
+	added, err := h.Store.HMSet(req.Key, req.Fields)
+	if err != nil {
+		logger.Warn("HMSET failed", "key", req.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("HMSET success", "key", req.Key, "added", added)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "added": added})
+}
+
+// HSetNX responds with set=false when the field already existed
+func (h *Handler) HSetNX(c *fiber.Ctx) error {
+	var req HashmapKeyValue
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse HSETNX request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" || req.Field == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and field are required"})
+	}
+
+	set, err := h.Store.HSetNX(req.Key, req.Field, req.Value)
+	if err != nil {
+		logger.Warn("HSETNX failed", "key", req.Key, "field", req.Field, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("HSETNX success", "key", req.Key, "field", req.Field, "set", set)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "set": set})
+}
+
+// HDel removes fields and responds with how many existed; removing the last field deletes the key
+func (h *Handler) HDel(c *fiber.Ctx) error {
+	var req HDelRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse HDEL request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" || len(req.Fields) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and fields are required"})
+	}
+
+	removed, err := h.Store.HDel(req.Key, req.Fields...)
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		logger.Warn("HDEL failed", "key", req.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("HDEL success", "key", req.Key, "removed", removed)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "removed": removed})
+}
+
+func (h *Handler) HExists(c *fiber.Ctx) error {
+	key := c.Query("key")
+	field := c.Query("field")
+	if key == "" || field == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and field are required"})
+	}
+
+	exists, err := h.Store.HExists(key, field)
+	if err != nil {
+		logger.Warn("HEXISTS failed", "key", key, "field", field, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("HEXISTS success", "key", key, "field", field)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "exists": exists})
+}
+
+func (h *Handler) HLen(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	length, err := h.Store.HLen(key)
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		logger.Warn("HLEN failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("HLEN success", "key", key, "length", length)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "length": length})
+}
+
+func (h *Handler) HKeys(c *fiber.Ctx) error {
+	return h.hashList(c, "HKEYS", "fields", h.Store.HKeys)
+}
+
+func (h *Handler) HVals(c *fiber.Ctx) error {
+	return h.hashList(c, "HVALS", "values", h.Store.HVals)
+}
+
+func (h *Handler) hashList(c *fiber.Ctx, name, field string, list func(key string) ([]string, error)) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	items, err := list(key)
+	if errors.Is(err, store.ErrKeyNotFound) {
+		items, err = []string{}, nil
+	}
+	if err != nil {
+		logger.Warn(name+" failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info(name+" success", "key", key, "count", len(items))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", field: items})
+}
+
+// HMGet reads ?key=k&fields=a,b and responds with one value per field, null for the ones not set
+func (h *Handler) HMGet(c *fiber.Ctx) error {
+	key := c.Query("key")
+	fields := splitList(c.Query("fields"))
+	if key == "" || len(fields) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and fields are required"})
+	}
+
+	values, err := h.Store.HMGet(key, fields...)
+	if err != nil {
+		logger.Warn("HMGET failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("HMGET success", "key", key, "count", len(fields))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "values": values})
+}
+
+// --- Hashmap Field Expiry ---
+
+// HExpire responds with a code per field: 1 when the ttl was set, 2 when a
+// non-positive ttl deleted the field and -2 when the field does not exist
+func (h *Handler) HExpire(c *fiber.Ctx) error {
+	var req HExpireRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse HEXPIRE request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" || len(req.Fields) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and fields are required"})
+	}
+
+	ttl := time.Duration(req.TTL) * time.Second
+	if req.PX != 0 {
+		ttl = time.Duration(req.PX) * time.Millisecond
+	}
+
+	codes, err := h.Store.HExpire(req.Key, ttl, req.Fields...)
+	if err != nil {
+		logger.Warn("HEXPIRE failed", "key", req.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("HEXPIRE success", "key", req.Key, "ttl", ttl)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "results": codes})
+}
+
+// HTTL reads ?key=k&fields=a,b and responds with the seconds left per field,
+// -1 for fields without a ttl and -2 for missing ones
+func (h *Handler) HTTL(c *fiber.Ctx) error {
+	key := c.Query("key")
+	fields := splitList(c.Query("fields"))
+	if key == "" || len(fields) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and fields are required"})
+	}
+
+	ttls, err := h.Store.HTTL(key, fields...)
+	if err != nil {
+		logger.Warn("HTTL failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("HTTL success", "key", key, "count", len(fields))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "ttls": ttls})
+}
+
+// HPersist reads ?key=k&fields=a,b and responds with a code per field: 1 when
+// the ttl was removed, -1 when the field had none and -2 when it does not exist
+func (h *Handler) HPersist(c *fiber.Ctx) error {
+	key := c.Query("key")
+	fields := splitList(c.Query("fields"))
+	if key == "" || len(fields) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and fields are required"})
+	}
+
+	codes, err := h.Store.HPersist(key, fields...)
+	if err != nil {
+		logger.Warn("HPERSIST failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("HPERSIST success", "key", key, "count", len(fields))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "results": codes})
+}
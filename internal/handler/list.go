@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+// --- List Edits ---
+
+type LSetRequest struct {
+	Key   string `json:"key"`
+	Index int    `json:"index"`
+	Value string `json:"value"`
+}
+
+type LInsertRequest struct {
+	Key      string `json:"key"`
+	Position string `json:"position"` // before or after
+	Pivot    string `json:"pivot"`
+	Value    string `json:"value"`
+}
+
+type LRemRequest struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+	Value string `json:"value"`
+}
+
+type LTrimRequest struct {
+	Key   string `json:"key"`
+	Start int    `json:"start"`
+	Stop  int    `json:"stop"`
+}
+
+type LMoveRequest struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	From        string `json:"from"` // left or right
+	To          string `json:"to"`
+}
+
+func (h *Handler) LPop(c *fiber.Ctx) error {
+	return h.listPop(c, "LPOP", h.Store.LPop)
+}
+
+func (h *Handler) RPop(c *fiber.Ctx) error {
+	return h.listPop(c, "RPOP", h.Store.RPop)
+}
+
+func (h *Handler) listPop(c *fiber.Ctx, name string, pop func(key string) (string, error)) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	value, err := pop(key)
+	if err != nil {
+		logger.Warn(name+" failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info(name+" success", "key", key)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "value": value})
+}
+
+func (h *Handler) LLen(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	length, err := h.Store.LLen(key)
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		logger.Warn("LLEN failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("LLEN success", "key", key, "length", length)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "length": length})
+}
+
+// LIndex returns the element at index, ?key=k&index=-1 for the last one
+func (h *Handler) LIndex(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	index := c.QueryInt("index", 0)
+	value, err := h.Store.LIndex(key, index)
+	if errors.Is(err, store.ErrKeyNotFound) || errors.Is(err, store.ErrIndexOutOfRange) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	if err != nil {
+		logger.Warn("LINDEX failed", "key", key, "index", index, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("LINDEX success", "key", key, "index", index)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "value": value})
+}
+
+func (h *Handler) LSet(c *fiber.Ctx) error {
+	var req LSetRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse LSET request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	if err := h.Store.LSet(req.Key, req.Index, req.Value); err != nil {
+		logger.Warn("LSET failed", "key", req.Key, "index", req.Index, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("LSET success", "key", req.Key, "index", req.Index)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok"})
+}
+
+// LInsert responds with the new length of the list, or -1 when the pivot was not found
+func (h *Handler) LInsert(c *fiber.Ctx) error {
+	var req LInsertRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse LINSERT request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	position := strings.ToLower(req.Position)
+	if req.Key == "" || (position != "before" && position != "after") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and a position of before or after are required"})
+	}
+
+	length, err := h.Store.LInsert(req.Key, req.Pivot, req.Value, position == "before")
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		logger.Warn("LINSERT failed", "key", req.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("LINSERT success", "key", req.Key, "length", length)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "length": length})
+}
+
+// LRem removes count occurrences of value from the head, -count from the tail or all of them for 0
+func (h *Handler) LRem(c *fiber.Ctx) error {
+	var req LRemRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse LREM request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	removed, err := h.Store.LRem(req.Key, req.Count, req.Value)
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		logger.Warn("LREM failed", "key", req.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("LREM success", "key", req.Key, "removed", removed)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "removed": removed})
+}
+
+func (h *Handler) LTrim(c *fiber.Ctx) error {
+	var req LTrimRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse LTRIM request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	if err := h.Store.LTrim(req.Key, req.Start, req.Stop); err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		logger.Warn("LTRIM failed", "key", req.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("LTRIM success", "key", req.Key, "start", req.Start, "stop", req.Stop)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok"})
+}
+
+// LMove pops from one end of source and pushes onto one end of destination atomically
+func (h *Handler) LMove(c *fiber.Ctx) error {
+	var req LMoveRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse LMOVE request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	from, to := strings.ToLower(req.From), strings.ToLower(req.To)
+	if req.Source == "" || req.Destination == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "source and destination are required"})
+	}
+	if (from != "left" && from != "right") || (to != "left" && to != "right") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "from and to must be left or right"})
+	}
+
+	value, err := h.Store.LMove(req.Source, req.Destination, from == "left", to == "left")
+	if err != nil {
+		logger.Warn("LMOVE failed", "source", req.Source, "destination", req.Destination, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("LMOVE success", "source", req.Source, "destination", req.Destination)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "value": value})
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+// --- Priority Queue Operations ---
+
+type PQPushRequest struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Priority int64  `json:"priority"`
+}
+
+// PQPush adds an item; higher priorities pop first and equal ones pop in insertion order
+func (h *Handler) PQPush(c *fiber.Ctx) error {
+	var req PQPushRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse PQPUSH request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" || req.Value == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and value are required"})
+	}
+
+	length, err := h.Store.PQPush(req.Key, req.Value, req.Priority)
+	if err != nil {
+		logger.Warn("PQPUSH failed", "key", req.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("PQPUSH success", "key", req.Key, "priority", req.Priority)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "length": length})
+}
+
+func (h *Handler) PQPop(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	item, err := h.Store.PQPop(key)
+	if err != nil {
+		logger.Warn("PQPOP failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("PQPOP success", "key", key, "priority", item.Priority)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "value": item.Value, "priority": item.Priority})
+}
+
+func (h *Handler) PQPeek(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	item, err := h.Store.PQPeek(key)
+	if err != nil {
+		logger.Warn("PQPEEK failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("PQPEEK success", "key", key, "priority", item.Priority)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "value": item.Value, "priority": item.Priority})
+}
+
+func (h *Handler) PQLen(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	length, err := h.Store.PQLen(key)
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		logger.Warn("PQLEN failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("PQLEN success", "key", key, "length", length)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "length": length})
+}
@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/pubsub"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+const (
+	sseHeartbeat   = 15 * time.Second
+	socketWriteTTL = 10 * time.Second
+)
+
+// --- Pub/Sub Operations ---
+
+type PublishRequest struct {
+	Channel string `json:"channel"`
+	Message string `json:"message"`
+}
+
+// PubSubCommand is a client frame on the pub/sub websocket
+type PubSubCommand struct {
+	Action   string   `json:"action"` // subscribe, psubscribe, unsubscribe, punsubscribe, publish
+	Channels []string `json:"channels"`
+	Channel  string   `json:"channel"` // publish only
+	Message  string   `json:"message"` // publish only
+}
+
+func (h *Handler) Publish(c *fiber.Ctx) error {
+	var req PublishRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse PUBLISH request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Channel == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "channel is required"})
+	}
+	if pubsub.Reserved(req.Channel) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": pubsub.ErrReservedChannel.Error()})
+	}
+
+	receivers := h.Broker.Publish(req.Channel, req.Message)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "receivers": receivers})
+}
+
+// Subscribe streams messages as server-sent events, ?channels=a,b&patterns=news.*
+func (h *Handler) Subscribe(c *fiber.Ctx) error {
+	channels := splitList(c.Query("channels"))
+	patterns := splitList(c.Query("patterns"))
+	if len(channels) == 0 && len(patterns) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "channels or patterns are required"})
+	}
+
+	sub := h.Broker.NewSubscriber()
+	sub.Subscribe(channels...)
+	count := sub.PSubscribe(patterns...)
+	logger.Info("SSE subscriber connected", "channels", channels, "patterns", patterns)
+
+	streamSSE(c, sub, fmt.Sprintf("subscribed to %d", count), func(msg pubsub.Message) (string, any, bool) {
+		if msg.Pattern != "" {
+			return "pmessage", msg, true
+		}
+		return "message", msg, true
+	})
+	return nil
+}
+
+// Events streams keyspace notifications as server-sent events, ?pattern=user:*&class=set,expired.
+// class is optional and takes data type names plus generic, expired and evicted.
+func (h *Handler) Events(c *fiber.Ctx) error {
+	pattern := c.Query("pattern", "*")
+	classes := make(map[string]bool)
+	for _, class := range splitList(c.Query("class")) {
+		classes[strings.ToLower(class)] = true
+	}
+
+	sub := h.Broker.NewSubscriber()
+	sub.PSubscribe(store.KeyspaceChannelPrefix + pattern)
+	logger.Info("Keyspace event subscriber connected", "pattern", pattern, "classes", c.Query("class"))
+
+	streamSSE(c, sub, "watching "+pattern, func(msg pubsub.Message) (string, any, bool) {
+		var event store.Event
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			return "", nil, false
+		}
+		if len(classes) > 0 && !classes[event.Class] {
+			return "", nil, false
+		}
+		return event.Op, json.RawMessage(msg.Payload), true
+	})
+	return nil
+}
+
+// streamSSE writes the messages of sub that event accepts until the client goes away or
+// the subscriber is closed. The subscriber is closed when the stream ends.
+func streamSSE(c *fiber.Ctx, sub *pubsub.Subscriber, hello string, event func(pubsub.Message) (string, any, bool)) {
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+
+		fmt.Fprintf(w, ": %s\n\n", hello)
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case msg := <-sub.Messages():
+				name, data, ok := event(msg)
+				if !ok {
+					continue
+				}
+				if err := writeSSE(w, name, data); err != nil {
+					logger.Debug("SSE subscriber disconnected", "error", err)
+					return
+				}
+			case <-heartbeat.C:
+				// comments keep proxies from timing out and detect dead clients
+				w.WriteString(": ping\n\n")
+				if err := w.Flush(); err != nil {
+					logger.Debug("SSE subscriber disconnected", "error", err)
+					return
+				}
+			case <-sub.Done():
+				if sub.Dropped() {
+					writeSSE(w, "error", fiber.Map{"message": "slow consumer, disconnected"})
+				}
+				return
+			}
+		}
+	})
+}
+
+func writeSSE(w *bufio.Writer, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return w.Flush()
+}
+
+// PubSubSocket upgrades to a websocket on which the client sends PubSubCommand frames
+// and receives messages. Initial subscriptions can be given like for Subscribe.
+func (h *Handler) PubSubSocket(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return c.Status(fiber.StatusUpgradeRequired).JSON(fiber.Map{"status": "error", "message": "websocket upgrade required"})
+	}
+	return h.pubSubSocket(c)
+}
+
+func (h *Handler) servePubSub(conn *websocket.Conn) {
+	sub := h.Broker.NewSubscriber()
+
+	var writeMu sync.Mutex
+	send := func(v any) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(socketWriteTTL))
+		return conn.WriteJSON(v)
+	}
+
+	// the writer has to be gone before returning, the library reuses conn afterwards
+	writerDone := make(chan struct{})
+	defer func() {
+		sub.Close()
+		<-writerDone
+	}()
+
+	go func() {
+		defer close(writerDone)
+		for {
+			select {
+			case msg := <-sub.Messages():
+				if err := send(messageFrame(msg)); err != nil {
+					conn.Close()
+					return
+				}
+			case <-sub.Done():
+				if sub.Dropped() {
+					writeMu.Lock()
+					conn.WriteControl(websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "slow consumer"),
+						time.Now().Add(socketWriteTTL))
+					writeMu.Unlock()
+					conn.Close()
+				}
+				return
+			}
+		}
+	}()
+
+	logger.Info("WebSocket subscriber connected", "ip", conn.IP())
+	if channels := splitList(conn.Query("channels")); len(channels) > 0 {
+		h.applyPubSubCommand(sub, PubSubCommand{Action: "subscribe", Channels: channels}, send)
+	}
+	if patterns := splitList(conn.Query("patterns")); len(patterns) > 0 {
+		h.applyPubSubCommand(sub, PubSubCommand{Action: "psubscribe", Channels: patterns}, send)
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			logger.Debug("WebSocket subscriber disconnected", "error", err)
+			return
+		}
+		var cmd PubSubCommand
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			send(fiber.Map{"type": "error", "message": "Invalid frame"})
+			continue
+		}
+		h.applyPubSubCommand(sub, cmd, send)
+	}
+}
+
+func (h *Handler) applyPubSubCommand(sub *pubsub.Subscriber, cmd PubSubCommand, send func(any) error) {
+	action := strings.ToLower(cmd.Action)
+	switch action {
+	case "subscribe", "psubscribe", "unsubscribe", "punsubscribe":
+		names := cmd.Channels
+		if len(names) == 0 {
+			switch action {
+			case "unsubscribe":
+				names = sub.Channels()
+			case "punsubscribe":
+				names = sub.Patterns()
+			default:
+				send(fiber.Map{"type": "error", "message": "channels are required"})
+				return
+			}
+		}
+		// one confirmation per channel, carrying the running count like redis does
+		for _, name := range names {
+			var count int
+			switch action {
+			case "subscribe":
+				count = sub.Subscribe(name)
+			case "psubscribe":
+				count = sub.PSubscribe(name)
+			case "unsubscribe":
+				count = sub.Unsubscribe(name)
+			case "punsubscribe":
+				count = sub.PUnsubscribe(name)
+			}
+			send(fiber.Map{"type": action, "channel": name, "count": count})
+		}
+	case "publish":
+		if cmd.Channel == "" {
+			send(fiber.Map{"type": "error", "message": "channel is required"})
+			return
+		}
+		if pubsub.Reserved(cmd.Channel) {
+			send(fiber.Map{"type": "error", "message": pubsub.ErrReservedChannel.Error()})
+			return
+		}
+		receivers := h.Broker.Publish(cmd.Channel, cmd.Message)
+		send(fiber.Map{"type": "publish", "channel": cmd.Channel, "receivers": receivers})
+	default:
+		send(fiber.Map{"type": "error", "message": "unknown action '" + cmd.Action + "'"})
+	}
+}
+
+func messageFrame(msg pubsub.Message) fiber.Map {
+	if msg.Pattern != "" {
+		return fiber.Map{"type": "pmessage", "pattern": msg.Pattern, "channel": msg.Channel, "message": msg.Payload}
+	}
+	return fiber.Map{"type": "message", "channel": msg.Channel, "message": msg.Payload}
+}
+
+// splitList splits a comma separated query value, dropping empty entries
+func splitList(v string) []string {
+	out := make([]string, 0)
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
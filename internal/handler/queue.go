@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+// defaultVisibility applies when /RDEQUEUE is called without a visibility timeout
+const defaultVisibility = 30 * time.Second
+
+type LeaseRequest struct {
+	Key string `json:"key"`
+	ID  string `json:"id"`
+}
+
+// RDequeue leases the head of a queue, ?key=&visibility=30&maxAttempts=5&deadLetter=jobs:dead.
+// The item comes back unless it is acked within visibility seconds.
+func (h *Handler) RDequeue(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Key is required"})
+	}
+	opts := store.LeaseOptions{Visibility: defaultVisibility, DeadLetter: c.Query("deadLetter")}
+	if v := c.Query("visibility"); v != "" {
+		seconds, err := strconv.ParseFloat(v, 64)
+		if err != nil || seconds <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "visibility must be a positive number of seconds"})
+		}
+		opts.Visibility = time.Duration(seconds * float64(time.Second))
+	}
+	if v := c.Query("maxAttempts"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "maxAttempts must be a positive integer"})
+		}
+		opts.MaxAttempts = n
+	}
+
+	lease, err := h.Store.Lease(key, opts)
+	if err != nil {
+		logger.Warn("RDEQUEUE failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	logger.Info("RDEQUEUE success", "key", key, "id", lease.ID, "attempts", lease.Attempts)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":   "success",
+		"message":  "ok",
+		"id":       lease.ID,
+		"value":    lease.Value,
+		"attempts": lease.Attempts,
+		"deadline": lease.Deadline.UnixMilli(),
+	})
+}
+
+func (h *Handler) Ack(c *fiber.Ctx) error {
+	return h.settleLease(c, "ACK", h.Store.Ack)
+}
+
+func (h *Handler) Nack(c *fiber.Ctx) error {
+	return h.settleLease(c, "NACK", h.Store.Nack)
+}
+
+func (h *Handler) settleLease(c *fiber.Ctx, name string, settle func(key, id string) (bool, error)) error {
+	var req LeaseRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse "+name+" request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body."})
+	}
+	if req.Key == "" || req.ID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and id are required"})
+	}
+
+	_, err := settle(req.Key, req.ID)
+	if errors.Is(err, store.ErrLeaseNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	if err != nil {
+		logger.Warn(name+" failed", "key", req.Key, "id", req.ID, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	logger.Info(name+" success", "key", req.Key, "id", req.ID)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok"})
+}
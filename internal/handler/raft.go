@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/raft"
+)
+
+func (h *Handler) RaftVote(c *fiber.Ctx) error {
+	var req raft.VoteRequest
+	if !h.parseRaftRequest(c, &req) {
+		return nil
+	}
+	return c.JSON(h.Raft.HandleRequestVote(&req))
+}
+
+func (h *Handler) RaftAppend(c *fiber.Ctx) error {
+	var req raft.AppendRequest
+	if !h.parseRaftRequest(c, &req) {
+		return nil
+	}
+	return c.JSON(h.Raft.HandleAppendEntries(&req))
+}
+
+func (h *Handler) RaftSnapshot(c *fiber.Ctx) error {
+	var req raft.SnapshotRequest
+	if !h.parseRaftRequest(c, &req) {
+		return nil
+	}
+	return c.JSON(h.Raft.HandleInstallSnapshot(&req))
+}
+
+// parseRaftRequest decodes the body of a raft RPC. When it fails the error
+// response has already been written.
+func (h *Handler) parseRaftRequest(c *fiber.Ctx, req any) bool {
+	if h.Raft == nil {
+		c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "error", "message": "cluster mode is not enabled on this node"})
+		return false
+	}
+	if err := c.BodyParser(req); err != nil {
+		logger.Error("Failed to parse raft request body", "path", c.Path(), "error", err)
+		c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid body."})
+		return false
+	}
+	return true
+}
+
+// Cluster reports this node's raft state and, on the leader, the progress of every peer
+func (h *Handler) Cluster(c *fiber.Ctx) error {
+	if h.Raft == nil {
+		return c.JSON(fiber.Map{"state": "standalone"})
+	}
+	return c.JSON(h.Raft.Status())
+}
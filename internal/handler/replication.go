@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"bufio"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+)
+
+// ReplicationSync sends a full binary snapshot; X-Repl-Id and X-Repl-Offset
+// tell the follower where to start streaming from
+func (h *Handler) ReplicationSync(c *fiber.Ctx) error {
+	if h.Leader == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "error", "message": "replication is not enabled on this node"})
+	}
+
+	snap := h.Leader.Snapshot()
+	logger.Info("Full sync requested", "addr", c.IP(), "offset", snap.Offset)
+
+	c.Set("Content-Type", "application/octet-stream")
+	c.Set("X-Repl-Id", snap.ID)
+	c.Set("X-Repl-Offset", strconv.FormatUint(snap.Offset, 10))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := snap.Encode(w); err != nil {
+			logger.Warn("Full sync aborted", "addr", c.IP(), "error", err)
+		}
+	})
+	return nil
+}
+
+// ReplicationStream streams operations after ?offset= as JSON lines. It answers
+// 409 when the follower has to start over with a full sync.
+func (h *Handler) ReplicationStream(c *fiber.Ctx) error {
+	if h.Leader == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "error", "message": "replication is not enabled on this node"})
+	}
+
+	id := c.Query("id")
+	offset, err := strconv.ParseUint(c.Query("offset"), 10, 64)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "offset must be a non-negative integer"})
+	}
+	if !h.Leader.CanResume(id, offset) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": "full sync required"})
+	}
+
+	addr := c.IP()
+	c.Set("Content-Type", "application/x-ndjson")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("X-Accel-Buffering", "no")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := h.Leader.Stream(w, addr, offset); err != nil {
+			logger.Debug("Replication stream ended", "addr", addr, "error", err)
+		}
+	})
+	return nil
+}
+
+// ReplicationInfo reports the role of this node, its offset and the lag of its followers or to its leader
+func (h *Handler) ReplicationInfo(c *fiber.Ctx) error {
+	switch {
+	case h.Follower != nil:
+		return c.JSON(h.Follower.Status())
+	case h.Leader != nil:
+		return c.JSON(h.Leader.Status())
+	}
+	return c.JSON(fiber.Map{"role": "standalone"})
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+// allPageSize is the default page size of /keys/all, /get/all and /values/all
+const allPageSize = 1000
+
+// parseScanOptions reads ?cursor=&match=&count=&type=. Cursors are passed as
+// strings in both directions since they do not fit in a JSON number.
+func parseScanOptions(c *fiber.Ctx, defaultCount int) (uint64, store.ScanOptions, error) {
+	opts := store.ScanOptions{Match: c.Query("match"), Count: defaultCount}
+
+	cursor, err := strconv.ParseUint(c.Query("cursor", "0"), 10, 64)
+	if err != nil {
+		return 0, opts, errors.New("invalid cursor")
+	}
+	if v := c.Query("count"); v != "" {
+		if opts.Count, err = strconv.Atoi(v); err != nil || opts.Count <= 0 {
+			return 0, opts, errors.New("count must be a positive integer")
+		}
+	}
+	if v := c.Query("type"); v != "" {
+		switch t := domain.DataType(v); t {
+		case domain.String, domain.Set, domain.List, domain.Queue, domain.Stack, domain.Hashmap, domain.SortedSet, domain.PriorityQueue:
+			opts.Type = t
+		default:
+			return 0, opts, fmt.Errorf("unknown type %q", v)
+		}
+	}
+	return cursor, opts, nil
+}
+
+func formatCursor(cursor uint64) string {
+	return strconv.FormatUint(cursor, 10)
+}
+
+// Scan pages through the keyspace, ?cursor=0&match=user:*&count=100&type=hashmap.
+// Iteration is complete when the returned cursor is "0".
+func (h *Handler) Scan(c *fiber.Ctx) error {
+	cursor, opts, err := parseScanOptions(c, store.DefaultScanCount)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	keys, next := h.Store.Scan(cursor, opts)
+	logger.Debug("SCAN", "cursor", cursor, "next", next, "count", len(keys))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "cursor": formatCursor(next), "keys": keys})
+}
+
+// SScan pages through the members of a set, ?key=&cursor=&match=&count=
+func (h *Handler) SScan(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+	cursor, opts, err := parseScanOptions(c, store.DefaultScanCount)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	members, next, err := h.Store.SScan(key, cursor, opts)
+	if errors.Is(err, store.ErrKeyNotFound) {
+		members, next, err = []string{}, 0, nil
+	}
+	if err != nil {
+		logger.Warn("SSCAN failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "cursor": formatCursor(next), "members": members})
+}
+
+// HScan pages through the fields of a hashmap, ?key=&cursor=&match=&count=
+func (h *Handler) HScan(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+	cursor, opts, err := parseScanOptions(c, store.DefaultScanCount)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	fields, next, err := h.Store.HScan(key, cursor, opts)
+	if errors.Is(err, store.ErrKeyNotFound) {
+		fields, next, err = map[string]string{}, 0, nil
+	}
+	if err != nil {
+		logger.Warn("HSCAN failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "cursor": formatCursor(next), "map": fields})
+}
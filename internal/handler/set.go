@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+// --- Set Algebra ---
+
+type SetStoreRequest struct {
+	Destination string   `json:"destination"`
+	Keys        []string `json:"keys"`
+}
+
+type SMoveRequest struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Member      string `json:"member"`
+}
+
+func (h *Handler) SInter(c *fiber.Ctx) error {
+	return h.setAlgebra(c, store.SetInter)
+}
+
+func (h *Handler) SUnion(c *fiber.Ctx) error {
+	return h.setAlgebra(c, store.SetUnion)
+}
+
+func (h *Handler) SDiff(c *fiber.Ctx) error {
+	return h.setAlgebra(c, store.SetDiff)
+}
+
+// setAlgebra serves ?keys=a,b,c; missing keys count as empty sets
+func (h *Handler) setAlgebra(c *fiber.Ctx, op store.SetOp) error {
+	name := strings.ToUpper(op.String())
+	keys := splitList(c.Query("keys"))
+	if len(keys) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "keys are required"})
+	}
+
+	var members []string
+	var err error
+	switch op {
+	case store.SetInter:
+		members, err = h.Store.SInter(keys...)
+	case store.SetUnion:
+		members, err = h.Store.SUnion(keys...)
+	default:
+		members, err = h.Store.SDiff(keys...)
+	}
+	if err != nil {
+		logger.Warn(name+" failed", "keys", keys, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info(name+" success", "keys", keys, "count", len(members))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "members": members})
+}
+
+func (h *Handler) SInterStore(c *fiber.Ctx) error {
+	return h.setStore(c, store.SetInter)
+}
+
+func (h *Handler) SUnionStore(c *fiber.Ctx) error {
+	return h.setStore(c, store.SetUnion)
+}
+
+func (h *Handler) SDiffStore(c *fiber.Ctx) error {
+	return h.setStore(c, store.SetDiff)
+}
+
+// setStore writes the result to destination, replacing it, and responds with its size
+func (h *Handler) setStore(c *fiber.Ctx, op store.SetOp) error {
+	name := strings.ToUpper(op.String()) + "STORE"
+	var req SetStoreRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse "+name+" request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Destination == "" || len(req.Keys) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "destination and keys are required"})
+	}
+
+	count, err := h.Store.SetStore(op, req.Destination, req.Keys...)
+	if err != nil {
+		logger.Warn(name+" failed", "destination", req.Destination, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info(name+" success", "destination", req.Destination, "count", count)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "count": count})
+}
+
+func (h *Handler) SIsMember(c *fiber.Ctx) error {
+	key := c.Query("key")
+	member := c.Query("member")
+	if key == "" || member == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and member are required"})
+	}
+
+	found, err := h.Store.SIsMember(key, member)
+	if err != nil {
+		logger.Warn("SISMEMBER failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("SISMEMBER success", "key", key, "member", member)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "member": found})
+}
+
+// SMIsMember checks ?key=k&members=a,b and responds with one boolean per member
+func (h *Handler) SMIsMember(c *fiber.Ctx) error {
+	key := c.Query("key")
+	members := splitList(c.Query("members"))
+	if key == "" || len(members) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and members are required"})
+	}
+
+	found, err := h.Store.SMIsMember(key, members...)
+	if err != nil {
+		logger.Warn("SMISMEMBER failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("SMISMEMBER success", "key", key, "count", len(members))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "members": found})
+}
+
+func (h *Handler) SCard(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	count, err := h.Store.SCard(key)
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		logger.Warn("SCARD failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("SCARD success", "key", key, "count", count)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "count": count})
+}
+
+// SRandMember returns ?count=1 distinct random members, or -count members that may repeat
+func (h *Handler) SRandMember(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	count := c.QueryInt("count", 1)
+	members, err := h.Store.SRandMember(key, count)
+	if err != nil {
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "members": []string{}})
+		}
+		logger.Warn("SRANDMEMBER failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("SRANDMEMBER success", "key", key, "count", len(members))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "members": members})
+}
+
+// SMove moves a member between two sets atomically, moved is false when source did not contain it
+func (h *Handler) SMove(c *fiber.Ctx) error {
+	var req SMoveRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse SMOVE request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Source == "" || req.Destination == "" || req.Member == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "source, destination and member are required"})
+	}
+
+	moved, err := h.Store.SMove(req.Source, req.Destination, req.Member)
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		logger.Warn("SMOVE failed", "source", req.Source, "destination", req.Destination, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("SMOVE success", "source", req.Source, "destination", req.Destination, "moved", moved)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "moved": moved})
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/slots"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+type RestoreRequest struct {
+	Key string `json:"key"`
+	// Value is the payload returned by /DUMP
+	Value []byte `json:"value"`
+	// ExpireAt is a unix time in milliseconds, 0 for keys that do not expire
+	ExpireAt int64 `json:"expireAt,omitempty"`
+	Replace  bool  `json:"replace,omitempty"`
+}
+
+// Dump returns the serialized value of a key, which /RESTORE recreates elsewhere
+func (h *Handler) Dump(c *fiber.Ctx) error {
+	key := c.Query("key")
+	payload, deadline, err := h.Store.DumpKey(key)
+	if errors.Is(err, store.ErrKeyNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Not found"})
+	}
+	if err != nil {
+		logger.Error("DUMP failed", "key", key, "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	var expireAt int64
+	if !deadline.IsZero() {
+		expireAt = deadline.UnixMilli()
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "value": payload, "expireAt": expireAt})
+}
+
+func (h *Handler) Restore(c *fiber.Ctx) error {
+	var req RestoreRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse RESTORE request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body."})
+	}
+	if req.Key == "" || len(req.Value) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and value are required"})
+	}
+	var deadline time.Time
+	if req.ExpireAt > 0 {
+		deadline = time.UnixMilli(req.ExpireAt)
+	}
+
+	err := h.Store.RestoreKey(req.Key, req.Value, deadline, req.Replace)
+	if errors.Is(err, store.ErrKeyExists) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	if err != nil {
+		logger.Warn("RESTORE failed", "key", req.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	logger.Info("Key restored", "key", req.Key)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok"})
+}
+
+// SlotKeys lists the keys that hash to the given slots, ?slots=0-100,200
+func (h *Handler) SlotKeys(c *fiber.Ctx) error {
+	ranges, err := slots.ParseRanges(c.Query("slots"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	keys := h.Store.KeysInSlots(slots.Expand(ranges))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "keys": keys})
+}
+
+// ReleaseSlots wakes the clients blocked on keys of ?slots= with a MOVED error
+// once the slots were handed to another node, see store.ReleaseSlots
+func (h *Handler) ReleaseSlots(c *fiber.Ctx) error {
+	ranges, err := slots.ParseRanges(c.Query("slots"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	h.Store.ReleaseSlots(slots.Expand(ranges))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok"})
+}
+
+// ClaimSlots lets blocking pops wait on keys of ?slots= again, before the slots are handed to this node
+func (h *Handler) ClaimSlots(c *fiber.Ctx) error {
+	ranges, err := slots.ParseRanges(c.Query("slots"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	h.Store.ClaimSlots(slots.Expand(ranges))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok"})
+}
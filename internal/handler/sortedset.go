@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/store"
+	valuepkg "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+// --- Sorted Set Operations ---
+
+type ZAddRequest struct {
+	Key     string             `json:"key"`
+	Members []valuepkg.ZMember `json:"members"`
+}
+
+type ZIncrByRequest struct {
+	Key       string  `json:"key"`
+	Member    string  `json:"member"`
+	Increment float64 `json:"increment"`
+}
+
+func (h *Handler) ZAdd(c *fiber.Ctx) error {
+	var req ZAddRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse ZADD request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" || len(req.Members) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and members are required"})
+	}
+
+	added, err := h.Store.ZAdd(req.Key, req.Members...)
+	if err != nil {
+		logger.Warn("ZADD failed", "key", req.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("ZADD success", "key", req.Key, "added", added)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "added": added})
+}
+
+func (h *Handler) ZIncrBy(c *fiber.Ctx) error {
+	var req ZIncrByRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse ZINCRBY request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" || req.Member == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and member are required"})
+	}
+
+	score, err := h.Store.ZIncrBy(req.Key, req.Member, req.Increment)
+	if err != nil {
+		logger.Warn("ZINCRBY failed", "key", req.Key, "member", req.Member, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("ZINCRBY success", "key", req.Key, "member", req.Member)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "score": score})
+}
+
+func (h *Handler) ZRem(c *fiber.Ctx) error {
+	var req SetKeyValue
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse ZREM request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" || len(req.Members) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and value are required"})
+	}
+
+	removed, err := h.Store.ZRem(req.Key, req.Members...)
+	if err != nil {
+		logger.Warn("ZREM failed", "key", req.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("ZREM success", "key", req.Key, "removed", removed)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "removed": removed})
+}
+
+func (h *Handler) ZScore(c *fiber.Ctx) error {
+	key := c.Query("key")
+	member := c.Query("member")
+	if key == "" || member == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and member are required"})
+	}
+
+	score, err := h.Store.ZScore(key, member)
+	if err != nil {
+		logger.Warn("ZSCORE failed", "key", key, "member", member, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("ZSCORE success", "key", key, "member", member)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "score": score})
+}
+
+func (h *Handler) ZCard(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	count, err := h.Store.ZCard(key)
+	if err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		logger.Warn("ZCARD failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("ZCARD success", "key", key, "count", count)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "count": count})
+}
+
+// ZRank returns the 0-based rank of member; rev=true ranks from the highest score
+func (h *Handler) ZRank(c *fiber.Ctx) error {
+	key := c.Query("key")
+	member := c.Query("member")
+	if key == "" || member == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key and member are required"})
+	}
+
+	rank, err := h.Store.ZRank(key, member, c.QueryBool("rev"))
+	if err != nil {
+		logger.Warn("ZRANK failed", "key", key, "member", member, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("ZRANK success", "key", key, "member", member)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "rank": rank})
+}
+
+// ZRange returns members between the start and stop ranks, ?key=k&start=0&stop=-1&rev=false
+func (h *Handler) ZRange(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+	start, err := strconv.Atoi(c.Query("start", "0"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "start must be an integer"})
+	}
+	stop, err := strconv.Atoi(c.Query("stop", "-1"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "stop must be an integer"})
+	}
+
+	members, err := h.Store.ZRange(key, start, stop, c.QueryBool("rev"))
+	if err != nil {
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "members": []valuepkg.ZMember{}})
+		}
+		logger.Warn("ZRANGE failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("ZRANGE success", "key", key, "count", len(members))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "members": members})
+}
+
+// ZRangeByScore returns members with scores in [min, max]. Bounds accept "(" for
+// exclusive and -inf/+inf; offset and limit page through the result.
+func (h *Handler) ZRangeByScore(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+	min, err := valuepkg.ParseScoreBound(c.Query("min", "-inf"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	max, err := valuepkg.ParseScoreBound(c.Query("max", "+inf"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "offset must be an integer"})
+	}
+	limit, err := strconv.Atoi(c.Query("limit", "-1"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "limit must be an integer"})
+	}
+
+	members, err := h.Store.ZRangeByScore(key, min, max, offset, limit, c.QueryBool("rev"))
+	if err != nil {
+		if errors.Is(err, store.ErrKeyNotFound) {
+			return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "members": []valuepkg.ZMember{}})
+		}
+		logger.Warn("ZRANGEBYSCORE failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("ZRANGEBYSCORE success", "key", key, "count", len(members))
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "members": members})
+}
@@ -0,0 +1,294 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+// --- Strings and Bitmaps ---
+
+// String values are binary safe. JSON can only carry UTF-8 text, so requests
+// and responses take an encoding option: "base64" for base64 encoded payloads,
+// empty for plain text.
+const encodingBase64 = "base64"
+
+var errEncoding = errors.New("encoding must be base64 or empty")
+
+type StringRangeRequest struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Offset   int    `json:"offset"` // only used by SETRANGE
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type SetBitRequest struct {
+	Key    string `json:"key"`
+	Offset int64  `json:"offset"`
+	Bit    int    `json:"bit"`
+}
+
+type BitOpRequest struct {
+	Op          string   `json:"op"` // AND, OR, XOR or NOT
+	Destination string   `json:"destination"`
+	Keys        []string `json:"keys"`
+}
+
+// decodeValue returns the bytes of a request value sent with encoding
+func decodeValue(value, encoding string) (string, error) {
+	switch encoding {
+	case "":
+		return value, nil
+	case encodingBase64:
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", errors.New("value is not valid base64")
+		}
+		return string(data), nil
+	}
+	return "", errEncoding
+}
+
+// encodeValue prepares a stored value for a response asked for with encoding
+func encodeValue(value, encoding string) string {
+	if encoding == encodingBase64 {
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	return value
+}
+
+func checkEncoding(encoding string) error {
+	if encoding != "" && encoding != encodingBase64 {
+		return errEncoding
+	}
+	return nil
+}
+
+// Append responds with the length of the string after the append
+func (h *Handler) Append(c *fiber.Ctx) error {
+	return h.writeRange(c, "APPEND", func(req StringRangeRequest, value string) (int, error) {
+		return h.Store.Append(req.Key, value)
+	})
+}
+
+// SetRange overwrites the string from offset on, padding it with zero bytes,
+// and responds with its new length
+func (h *Handler) SetRange(c *fiber.Ctx) error {
+	return h.writeRange(c, "SETRANGE", func(req StringRangeRequest, value string) (int, error) {
+		return h.Store.SetRange(req.Key, req.Offset, value)
+	})
+}
+
+func (h *Handler) writeRange(c *fiber.Ctx, name string, write func(req StringRangeRequest, value string) (int, error)) error {
+	var req StringRangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse "+name+" request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+	value, err := decodeValue(req.Value, req.Encoding)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	length, err := write(req, value)
+	if err != nil {
+		logger.Warn(name+" failed", "key", req.Key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info(name+" success", "key", req.Key, "length", length)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "length": length})
+}
+
+func (h *Handler) StrLen(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	length, err := h.Store.StrLen(key)
+	if err != nil {
+		logger.Warn("STRLEN failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("STRLEN success", "key", key, "length", length)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "length": length})
+}
+
+// GetRange reads ?key=k&start=0&end=-1[&encoding=base64] and responds with the
+// bytes between start and end inclusive
+func (h *Handler) GetRange(c *fiber.Ctx) error {
+	key := c.Query("key")
+	encoding := c.Query("encoding")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+	if err := checkEncoding(encoding); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	start, err := strconv.ParseInt(c.Query("start", "0"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "start must be an integer"})
+	}
+	end, err := strconv.ParseInt(c.Query("end", "-1"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "end must be an integer"})
+	}
+
+	value, err := h.Store.GetRange(key, start, end)
+	if err != nil {
+		logger.Warn("GETRANGE failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("GETRANGE success", "key", key, "start", start, "end", end)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "value": encodeValue(value, encoding)})
+}
+
+// SetBit responds with the previous value of the bit
+func (h *Handler) SetBit(c *fiber.Ctx) error {
+	var req SetBitRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse SETBIT request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+
+	old, err := h.Store.SetBit(req.Key, req.Offset, req.Bit)
+	if err != nil {
+		logger.Warn("SETBIT failed", "key", req.Key, "offset", req.Offset, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("SETBIT success", "key", req.Key, "offset", req.Offset, "bit", req.Bit)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "previous": old})
+}
+
+func (h *Handler) GetBit(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+	offset, err := strconv.ParseInt(c.Query("offset"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": store.ErrBitOffset.Error()})
+	}
+
+	bit, err := h.Store.GetBit(key, offset)
+	if err != nil {
+		logger.Warn("GETBIT failed", "key", key, "offset", offset, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("GETBIT success", "key", key, "offset", offset)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "bit": bit})
+}
+
+// BitCount reads ?key=k[&start=0&end=-1][&unit=byte|bit] and responds with the
+// number of set bits in the range, the whole string by default
+func (h *Handler) BitCount(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+	r, err := parseBitRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	count, err := h.Store.BitCount(key, r)
+	if err != nil {
+		logger.Warn("BITCOUNT failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("BITCOUNT success", "key", key, "count", count)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "count": count})
+}
+
+// BitPos reads ?key=k&bit=0|1 and the range of BitCount, and responds with the
+// position of the first matching bit, or -1
+func (h *Handler) BitPos(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required"})
+	}
+	bit, err := strconv.Atoi(c.Query("bit"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": store.ErrBitValue.Error()})
+	}
+	r, err := parseBitRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	pos, err := h.Store.BitPos(key, bit, r)
+	if err != nil {
+		logger.Warn("BITPOS failed", "key", key, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("BITPOS success", "key", key, "bit", bit, "position", pos)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "position": pos})
+}
+
+// parseBitRange reads the optional start, end and unit query parameters of BITCOUNT and BITPOS
+func parseBitRange(c *fiber.Ctx) (store.BitRange, error) {
+	r := store.WholeString
+	var err error
+	if q := c.Query("start"); q != "" {
+		if r.Start, err = strconv.ParseInt(q, 10, 64); err != nil {
+			return r, errors.New("start must be an integer")
+		}
+	}
+	if q := c.Query("end"); q != "" {
+		if r.End, err = strconv.ParseInt(q, 10, 64); err != nil {
+			return r, errors.New("end must be an integer")
+		}
+		r.HasEnd = true
+	}
+	switch c.Query("unit", "byte") {
+	case "byte":
+	case "bit":
+		r.Bits = true
+	default:
+		return r, errors.New("unit must be byte or bit")
+	}
+	return r, nil
+}
+
+// BitOp stores the bitwise combination of keys at destination and responds
+// with the length of the result
+func (h *Handler) BitOp(c *fiber.Ctx) error {
+	var req BitOpRequest
+	if err := c.BodyParser(&req); err != nil {
+		logger.Error("Failed to parse BITOP request body", "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if req.Destination == "" || len(req.Keys) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "destination and keys are required"})
+	}
+	op, ok := store.ParseBitOp(req.Op)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "op must be AND, OR, XOR or NOT"})
+	}
+
+	length, err := h.Store.BitOpStore(op, req.Destination, req.Keys...)
+	if err != nil {
+		logger.Warn("BITOP failed", "op", req.Op, "destination", req.Destination, "error", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	logger.Info("BITOP success", "op", req.Op, "destination", req.Destination, "length", length)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "ok", "length": length})
+}
@@ -0,0 +1,219 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/slots"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+// migrateBatch is the number of slots moved under one lock. Requests for those
+// slots wait while their keys are copied, every other slot keeps being served.
+const migrateBatch = 64
+
+type Migration struct {
+	ID         int       `json:"id"`
+	Slots      string    `json:"slots"`
+	Target     string    `json:"target"`
+	State      string    `json:"state"` // running, done or failed
+	TotalSlots int       `json:"totalSlots"`
+	MovedSlots int       `json:"movedSlots"`
+	MovedKeys  int       `json:"movedKeys"`
+	Error      string    `json:"error,omitempty"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished,omitzero"`
+}
+
+type MigrateRequest struct {
+	Slots string `json:"slots"` // e.g. "0-1000,5000"
+	To    string `json:"to"`    // node id
+}
+
+// Migrate starts moving slots to another node in the background. Only one
+// migration runs at a time; its progress is reported by /shards/migrations.
+func (p *Proxy) Migrate(c *fiber.Ctx) error {
+	var req MigrateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	if _, ok := p.nodes[req.To]; !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("unknown node %q", req.To)})
+	}
+	ranges, err := slots.ParseRanges(req.Slots)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
+	p.mu.Lock()
+	if n := len(p.migrations); n > 0 && p.migrations[n-1].State == "running" {
+		p.mu.Unlock()
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": "a migration is already running"})
+	}
+	pending := make([]int, 0)
+	for _, slot := range slots.Expand(ranges) {
+		if p.table.Owner(slot) != req.To {
+			pending = append(pending, slot)
+		}
+	}
+	m := &Migration{
+		ID:         len(p.migrations) + 1,
+		Slots:      req.Slots,
+		Target:     req.To,
+		State:      "running",
+		TotalSlots: len(pending),
+		Started:    time.Now(),
+	}
+	p.migrations = append(p.migrations, m)
+	snapshot := *m
+	p.mu.Unlock()
+
+	logger.Info("Slot migration started", "id", m.ID, "slots", req.Slots, "to", req.To, "moving", len(pending))
+	go p.migrate(m, pending)
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"status": "success", "migration": snapshot})
+}
+
+func (p *Proxy) Migrations(c *fiber.Ctx) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	list := make([]Migration, 0, len(p.migrations))
+	for _, m := range p.migrations {
+		list = append(list, *m)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "migrations": list})
+}
+
+func (p *Proxy) migrate(m *Migration, pending []int) {
+	var err error
+	for start := 0; start < len(pending) && err == nil; start += migrateBatch {
+		batch := pending[start:min(start+migrateBatch, len(pending))]
+		var moved int
+		moved, err = p.moveBatch(m.Target, batch)
+
+		p.mu.Lock()
+		m.MovedKeys += moved
+		if err == nil {
+			m.MovedSlots += len(batch)
+		}
+		p.mu.Unlock()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m.Finished = time.Now()
+	if err != nil {
+		m.State, m.Error = "failed", err.Error()
+		logger.Error("Slot migration failed", "id", m.ID, "movedSlots", m.MovedSlots, "error", err)
+		return
+	}
+	m.State = "done"
+	logger.Info("Slot migration finished", "id", m.ID, "slots", m.MovedSlots, "keys", m.MovedKeys, "took", m.Finished.Sub(m.Started))
+}
+
+// moveBatch copies the keys of batch from their current owners to target and
+// then hands the slots over. Each key is restored on the target before it is
+// deleted from its source, so when a step fails the slots stay with the source,
+// which still has every key, and retrying the migration overwrites the copies.
+// Sources release the slots once their keys are gone, so blocking pops parked
+// on them are sent to target.
+func (p *Proxy) moveBatch(target string, batch []int) (int, error) {
+	for _, slot := range batch {
+		p.locks[slot].Lock()
+	}
+	p.moving.Lock()
+	defer func() {
+		p.moving.Unlock()
+		for _, slot := range batch {
+			p.locks[slot].Unlock()
+		}
+	}()
+
+	// the target may have released some of the slots when they last moved away
+	if err := p.slotsCall(target, "claim", batch); err != nil {
+		return 0, fmt.Errorf("claiming slots on %s: %w", target, err)
+	}
+
+	bySource := make(map[string][]int)
+	for _, slot := range batch {
+		if owner := p.table.Owner(slot); owner != target {
+			bySource[owner] = append(bySource[owner], slot)
+		}
+	}
+
+	moved := 0
+	for source, owned := range bySource {
+		var reply struct {
+			Keys []string `json:"keys"`
+		}
+		query := url.Values{"slots": {slots.FormatRanges(owned)}}
+		if err := p.call(http.MethodGet, p.nodes[source]+"/api/v0/slots/keys?"+query.Encode(), nil, &reply); err != nil {
+			return moved, fmt.Errorf("listing keys on %s: %w", source, err)
+		}
+		for _, key := range reply.Keys {
+			if err := p.copyKey(source, target, key); err != nil {
+				return moved, fmt.Errorf("copying %q from %s to %s: %w", key, source, target, err)
+			}
+		}
+		if err := p.deleteKeys(source, reply.Keys); err != nil {
+			return moved, fmt.Errorf("deleting moved keys on %s: %w", source, err)
+		}
+		moved += len(reply.Keys)
+		// waiters blocked on the source come back to BlockingPop, which forwards
+		// them again once the slots are unlocked and assigned to target
+		if err := p.slotsCall(source, "release", owned); err != nil {
+			return moved, fmt.Errorf("releasing slots on %s: %w", source, err)
+		}
+	}
+
+	if err := p.table.Assign(target, batch...); err != nil {
+		return moved, err
+	}
+	return moved, nil
+}
+
+// slotsCall claims or releases slotList on node, see store.ReleaseSlots
+func (p *Proxy) slotsCall(node, action string, slotList []int) error {
+	query := url.Values{"slots": {slots.FormatRanges(slotList)}}
+	return p.call(http.MethodPost, p.nodes[node]+"/api/v0/slots/"+action+"?"+query.Encode(), nil, nil)
+}
+
+// copyKey recreates key on target from a DUMP taken on source. A key that
+// expired since it was listed is skipped.
+func (p *Proxy) copyKey(source, target, key string) error {
+	var dump struct {
+		Value    []byte `json:"value"`
+		ExpireAt int64  `json:"expireAt"`
+	}
+	err := p.call(http.MethodGet, p.nodes[source]+"/api/v0/DUMP?"+url.Values{"key": {key}}.Encode(), nil, &dump)
+	var nodeErr *nodeError
+	if errors.As(err, &nodeErr) && nodeErr.status == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(fiber.Map{"key": key, "value": dump.Value, "expireAt": dump.ExpireAt, "replace": true})
+	if err != nil {
+		return err
+	}
+	return p.call(http.MethodPost, p.nodes[target]+"/api/v0/RESTORE", body, nil)
+}
+
+// deleteKeys removes keys from node in a single transaction
+func (p *Proxy) deleteKeys(node string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(fiber.Map{"commands": []store.Command{{Name: "del", Args: keys}}})
+	if err != nil {
+		return err
+	}
+	return p.call(http.MethodPost, p.nodes[node]+"/api/v0/tx", body, nil)
+}
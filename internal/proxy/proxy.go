@@ -0,0 +1,514 @@
+// Package proxy fronts a set of independent mini_db nodes that each serve part
+// of the keyspace. Requests are forwarded to the node owning the hash slot of
+// their key, calls that span the whole keyspace are sent to every node and their
+// results combined, and slots can be moved between nodes while traffic flows.
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	fiberProxy "github.com/gofiber/fiber/v2/middleware/proxy"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/pubsub"
+	"github.com/mrpurushotam/mini_db/internal/slots"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+type Proxy struct {
+	// nodes maps node ids to the base URL of their HTTP server
+	nodes map[string]string
+	ids   []string
+	table *slots.Table
+	// a request holds the read lock of its slots until the node has answered,
+	// a migration takes the write lock of the slots it is moving
+	locks [slots.Count]sync.RWMutex
+	// fan-out and scan calls hold the read lock so they never see a key on two nodes
+	// halfway through a migration batch
+	moving sync.RWMutex
+	client *http.Client
+
+	mu         sync.Mutex
+	migrations []*Migration
+}
+
+// New routes requests to nodes, a map of node id to base URL, according to table
+func New(nodes map[string]string, table *slots.Table) *Proxy {
+	p := &Proxy{
+		nodes:  make(map[string]string, len(nodes)),
+		table:  table,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	for id, url := range nodes {
+		p.nodes[id] = strings.TrimSuffix(url, "/")
+		p.ids = append(p.ids, id)
+	}
+	sort.Strings(p.ids)
+	return p
+}
+
+// Register installs the proxy's routes on the /api/v0 group
+func (p *Proxy) Register(router fiber.Router) {
+	for _, path := range []string{"/SCAN", "/keys/all", "/get/all", "/values/all"} {
+		router.Get(path, func(c *fiber.Ctx) error {
+			return p.Scan(c)
+		})
+	}
+
+	router.Get("/stats", func(c *fiber.Ctx) error {
+		return p.Stats(c)
+	})
+
+	router.Get("/snapshot", func(c *fiber.Ctx) error {
+		return p.Snapshot(c)
+	})
+
+	router.Post("/PUBLISH", func(c *fiber.Ctx) error {
+		return p.Publish(c)
+	})
+
+	router.Post("/tx", func(c *fiber.Ctx) error {
+		return p.Tx(c)
+	})
+
+	for _, path := range []string{"/BLPOP", "/BRPOP", "/BDEQUEUE", "/BPOP"} {
+		router.Post(path, func(c *fiber.Ctx) error {
+			return p.BlockingPop(c)
+		})
+	}
+
+	for _, path := range []string{"/SINTER", "/SUNION", "/SDIFF"} {
+		router.Get(path, func(c *fiber.Ctx) error {
+			return p.MultiKey(c)
+		})
+	}
+
+	for _, path := range []string{"/LMOVE", "/SINTERSTORE", "/SUNIONSTORE", "/SDIFFSTORE", "/SMOVE", "/BITOP"} {
+		router.Post(path, func(c *fiber.Ctx) error {
+			return p.MultiKey(c)
+		})
+	}
+
+	router.Get("/shards", func(c *fiber.Ctx) error {
+		return p.Shards(c)
+	})
+
+	router.Post("/shards/migrate", func(c *fiber.Ctx) error {
+		return p.Migrate(c)
+	})
+
+	router.Get("/shards/migrations", func(c *fiber.Ctx) error {
+		return p.Migrations(c)
+	})
+
+	// streams and node-to-node traffic are only served by the nodes themselves
+	for _, path := range []string{"/SUBSCRIBE", "/ws/pubsub", "/events", "/replication", "/replication/*", "/cluster", "/raft/*", "/slots/keys", "/slots/release", "/slots/claim"} {
+		router.All(path, func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{"status": "error", "message": c.Path() + " is not available through the proxy, connect to a node directly"})
+		})
+	}
+
+	router.Get("/", func(c *fiber.Ctx) error {
+		return c.JSON(map[string]string{"message": "Proxy is running"})
+	})
+
+	router.All("/*", func(c *fiber.Ctx) error {
+		return p.Route(c)
+	})
+}
+
+// Route forwards a single-key request to the node owning the key's slot.
+// The key is read from the query string or from the JSON body.
+func (p *Proxy) Route(c *fiber.Ctx) error {
+	key := c.Query("key")
+	if key == "" && len(c.Body()) > 0 {
+		var body struct {
+			Key string `json:"key" form:"key"`
+		}
+		if err := c.BodyParser(&body); err == nil {
+			key = body.Key
+		}
+	}
+	if key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key is required to route the request"})
+	}
+	return p.forward(c, []string{key}, true)
+}
+
+// movedRetries bounds how often a blocking pop follows its slot to a new owner
+const movedRetries = 3
+
+// BlockingPop forwards a blocking pop to the node serving all of its keys.
+// Unlike other requests it does not hold the slot locks while the node waits
+// for an element, which would stall migrations and, behind them, every write to
+// those slots. Instead a migration has the old node answer the waiters on the
+// slots it moves with 421 Misdirected Request, and the pop is forwarded again
+// to the new owner, waiting for its full timeout once more.
+func (p *Proxy) BlockingPop(c *fiber.Ctx) error {
+	var req struct {
+		Keys []string `json:"keys"`
+		Key  string   `json:"key"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	keys := req.Keys
+	if req.Key != "" {
+		keys = append(keys, req.Key)
+	}
+	if len(keys) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "key or keys are required"})
+	}
+	for attempt := 0; ; attempt++ {
+		err := p.forward(c, keys, false)
+		if err != nil || c.Response().StatusCode() != fiber.StatusMisdirectedRequest || attempt == movedRetries {
+			return err
+		}
+		logger.Info("Blocking pop retried on the new owner of its slot", "keys", keys)
+	}
+}
+
+// MultiKey forwards a request naming several keys, in the comma separated keys
+// query parameter or the keys, source and destination body fields, to the node
+// serving all of them
+func (p *Proxy) MultiKey(c *fiber.Ctx) error {
+	var req struct {
+		Keys        []string `json:"keys"`
+		Source      string   `json:"source"`
+		Destination string   `json:"destination"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+		}
+	}
+	keys := req.Keys
+	if q := c.Query("keys"); q != "" {
+		keys = append(keys, strings.Split(q, ",")...)
+	}
+	for _, key := range []string{req.Source, req.Destination} {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "keys are required to route the request"})
+	}
+	return p.forward(c, keys, true)
+}
+
+// Tx forwards a transaction to the node serving all of its keys, watched keys included
+func (p *Proxy) Tx(c *fiber.Ctx) error {
+	var req struct {
+		Watch    map[string]uint64 `json:"watch"`
+		Commands []store.Command   `json:"commands"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid body"})
+	}
+	keys := make([]string, 0)
+	for key := range req.Watch {
+		keys = append(keys, key)
+	}
+	for _, cmd := range req.Commands {
+		keys = append(keys, commandKeys(cmd)...)
+	}
+	if len(keys) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "commands are required"})
+	}
+	return p.forward(c, keys, true)
+}
+
+// commandKeys returns the keys a transaction command touches: every argument
+// for the multi-key commands, the first two for the moves, the ones after the
+// operation for BITOP and the first one for all others
+func commandKeys(cmd store.Command) []string {
+	if len(cmd.Args) == 0 {
+		return nil
+	}
+	switch strings.ToLower(cmd.Name) {
+	case "del", "exists":
+		return cmd.Args
+	case "sinter", "sunion", "sdiff", "sinterstore", "sunionstore", "sdiffstore":
+		return cmd.Args
+	case "lmove", "smove":
+		if len(cmd.Args) >= 2 {
+			return cmd.Args[:2]
+		}
+	case "bitop":
+		// the operation name comes first
+		if len(cmd.Args) >= 2 {
+			return cmd.Args[1:]
+		}
+	}
+	return cmd.Args[:1]
+}
+
+// forward sends the request to the node owning the slots of keys, which must all
+// be served by the same node. With hold, the slots stay read locked until the node
+// answers, so a migration cannot move them while the request is in flight.
+func (p *Proxy) forward(c *fiber.Ctx, keys []string, hold bool) error {
+	owned := make([]int, 0, len(keys))
+	for _, key := range keys {
+		owned = append(owned, slots.ForKey(key))
+	}
+	owned = p.rlock(owned)
+	locked := true
+	defer func() {
+		if locked {
+			p.runlock(owned)
+		}
+	}()
+
+	owner := p.table.Owner(owned[0])
+	for _, slot := range owned[1:] {
+		if p.table.Owner(slot) != owner {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "CROSSSLOT keys in request are served by different nodes, use {hash tags} to keep them together"})
+		}
+	}
+	if !hold {
+		p.runlock(owned)
+		locked = false
+	}
+
+	if err := fiberProxy.Do(c, p.nodes[owner]+c.OriginalURL()); err != nil {
+		logger.Error("Failed to forward request", "node", owner, "path", c.Path(), "error", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("node %s is unreachable", owner)})
+	}
+	return nil
+}
+
+// rlock read locks slots in ascending order, which keeps concurrent lockers
+// from deadlocking, and returns the sorted distinct slots for runlock
+func (p *Proxy) rlock(owned []int) []int {
+	sort.Ints(owned)
+	distinct := owned[:0]
+	for i, slot := range owned {
+		if i == 0 || slot != owned[i-1] {
+			distinct = append(distinct, slot)
+		}
+	}
+	for _, slot := range distinct {
+		p.locks[slot].RLock()
+	}
+	return distinct
+}
+
+func (p *Proxy) runlock(owned []int) {
+	for _, slot := range owned {
+		p.locks[slot].RUnlock()
+	}
+}
+
+// nodeReply is the decoded JSON body a node answered a fan-out call with
+type nodeReply map[string]json.RawMessage
+
+// fanOut sends the request to every node and returns their replies by node id.
+// It fails as a whole if any node cannot be reached or does not answer 200,
+// since a merge missing a node would silently drop part of the keyspace.
+func (p *Proxy) fanOut(c *fiber.Ctx) (map[string]nodeReply, error) {
+	p.moving.RLock()
+	defer p.moving.RUnlock()
+
+	method, uri := c.Method(), c.OriginalURL()
+	body := append([]byte(nil), c.Body()...)
+
+	var mu sync.Mutex
+	var firstErr error
+	replies := make(map[string]nodeReply, len(p.nodes))
+	var wg sync.WaitGroup
+	for _, id := range p.ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			var reply nodeReply
+			err := p.call(method, p.nodes[id]+uri, body, &reply)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("node %s: %w", id, err)
+				}
+				return
+			}
+			replies[id] = reply
+		}(id)
+	}
+	wg.Wait()
+	return replies, firstErr
+}
+
+// call sends body to url and decodes the JSON answer into out. Answers other
+// than 200 are returned as errors carrying the node's message.
+func (p *Proxy) call(method, url string, body []byte, out any) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var reply struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(b, &reply) == nil && reply.Message != "" {
+			return &nodeError{status: resp.StatusCode, message: reply.Message}
+		}
+		return &nodeError{status: resp.StatusCode, message: resp.Status}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
+
+type nodeError struct {
+	status  int
+	message string
+}
+
+func (e *nodeError) Error() string {
+	return e.message
+}
+
+func (p *Proxy) fanOutError(c *fiber.Ctx, err error) error {
+	logger.Error("Fan-out request failed", "path", c.Path(), "error", err)
+	return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"status": "error", "message": err.Error()})
+}
+
+// Scan serves the paginated keyspace routes by walking the nodes one after the
+// other: the proxy's cursor holds the node's position above store.ScanCursorBits
+// and that node's own cursor below. Keys moved by a migration while a scan is in progress may
+// be missed or returned twice.
+func (p *Proxy) Scan(c *fiber.Ctx) error {
+	cursor, err := strconv.ParseUint(c.Query("cursor", "0"), 10, 64)
+	if err != nil || cursor>>store.ScanCursorBits >= uint64(len(p.ids)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "invalid cursor"})
+	}
+	index := int(cursor >> store.ScanCursorBits)
+	id := p.ids[index]
+
+	query := url.Values{}
+	c.Request().URI().QueryArgs().VisitAll(func(k, v []byte) {
+		query.Add(string(k), string(v))
+	})
+	query.Set("cursor", strconv.FormatUint(cursor&(1<<store.ScanCursorBits-1), 10))
+
+	p.moving.RLock()
+	var reply nodeReply
+	err = p.call(http.MethodGet, p.nodes[id]+c.Path()+"?"+query.Encode(), nil, &reply)
+	p.moving.RUnlock()
+	var nodeErr *nodeError
+	if errors.As(err, &nodeErr) {
+		return c.Status(nodeErr.status).JSON(fiber.Map{"status": "error", "message": nodeErr.message})
+	}
+	if err != nil {
+		return p.fanOutError(c, fmt.Errorf("node %s: %w", id, err))
+	}
+
+	var nodeCursor string
+	json.Unmarshal(reply["cursor"], &nodeCursor)
+	next, err := strconv.ParseUint(nodeCursor, 10, 64)
+	if err != nil {
+		return p.fanOutError(c, fmt.Errorf("node %s returned an invalid cursor", id))
+	}
+	switch {
+	case next != 0:
+		next |= uint64(index) << store.ScanCursorBits
+	case index+1 < len(p.ids):
+		next = uint64(index+1) << store.ScanCursorBits
+	}
+	reply["cursor"], _ = json.Marshal(strconv.FormatUint(next, 10))
+	return c.Status(fiber.StatusOK).JSON(reply)
+}
+
+// Stats adds up the counters of every node and lists each node's own stats
+func (p *Proxy) Stats(c *fiber.Ctx) error {
+	replies, err := p.fanOut(c)
+	if err != nil {
+		return p.fanOutError(c, err)
+	}
+	total := make(map[string]any)
+	nodes := make(map[string]json.RawMessage, len(replies))
+	for id, reply := range replies {
+		nodes[id] = reply["stats"]
+		var part map[string]any
+		if err := json.Unmarshal(reply["stats"], &part); err != nil {
+			return p.fanOutError(c, fmt.Errorf("node %s: %w", id, err))
+		}
+		for name, v := range part {
+			// numbers are summed, settings such as the eviction policy are shown when the nodes agree
+			if n, ok := v.(float64); ok {
+				sum, _ := total[name].(float64)
+				total[name] = sum + n
+			} else if prev, seen := total[name]; !seen || prev == v {
+				total[name] = v
+			} else {
+				total[name] = "mixed"
+			}
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "stats": total, "nodes": nodes})
+}
+
+func (p *Proxy) Snapshot(c *fiber.Ctx) error {
+	if _, err := p.fanOut(c); err != nil {
+		return p.fanOutError(c, err)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "message": "Snapshot created successfully"})
+}
+
+// Publish delivers the message on every node, since subscribers may be connected to any of them
+func (p *Proxy) Publish(c *fiber.Ctx) error {
+	var req struct {
+		Channel string `json:"channel"`
+	}
+	// rejected here rather than by every node, which fanOut would report as a gateway error
+	if json.Unmarshal(c.Body(), &req) == nil && pubsub.Reserved(req.Channel) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": pubsub.ErrReservedChannel.Error()})
+	}
+	replies, err := p.fanOut(c)
+	if err != nil {
+		return p.fanOutError(c, err)
+	}
+	receivers := 0
+	for _, reply := range replies {
+		var n int
+		json.Unmarshal(reply["receivers"], &n)
+		receivers += n
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "receivers": receivers})
+}
+
+// Shards reports the nodes and the slots each of them serves
+func (p *Proxy) Shards(c *fiber.Ctx) error {
+	ranges := p.table.Ranges()
+	nodes := make([]fiber.Map, 0, len(p.ids))
+	for _, id := range p.ids {
+		nodes = append(nodes, fiber.Map{"id": id, "url": p.nodes[id], "slots": ranges[id]})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "success", "slotCount": slots.Count, "nodes": nodes})
+}
@@ -0,0 +1,286 @@
+package pubsub
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mrpurushotam/mini_db/internal/glob"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+)
+
+// KeyspacePrefix starts the channels keyspace events are published on. Only
+// patterns starting with it match those channels, so a PSUBSCRIBE * sees the
+// messages clients publish and not every change to the keyspace.
+const KeyspacePrefix = "__keyspace__:"
+
+// KeyeventPrefix is reserved for keyspace events along with KeyspacePrefix
+const KeyeventPrefix = "__keyevent__:"
+
+// ErrReservedChannel rejects a client PUBLISH to a keyspace event channel,
+// which would pass for an event the store never made
+var ErrReservedChannel = errors.New("channels starting with " + KeyspacePrefix + " or " + KeyeventPrefix + " are reserved for keyspace events")
+
+// Reserved reports whether only the server publishes on channel
+func Reserved(channel string) bool {
+	return strings.HasPrefix(channel, KeyspacePrefix) || strings.HasPrefix(channel, KeyeventPrefix)
+}
+
+// Message is a published message as seen by one subscriber. Pattern is set
+// when it was delivered through a PSUBSCRIBE pattern.
+type Message struct {
+	Channel string `json:"channel"`
+	Pattern string `json:"pattern,omitempty"`
+	Payload string `json:"message"`
+}
+
+// Broker is an in-process fan-out of published messages to subscribers.
+// Messages are fire-and-forget: nothing is persisted and a subscriber only
+// sees what is published while it is subscribed.
+type Broker struct {
+	mu         sync.RWMutex
+	channels   map[string]map[*Subscriber]struct{}
+	patterns   map[string]map[*Subscriber]struct{}
+	bufferSize int
+}
+
+// Subscriber receives messages on a bounded buffer. If the buffer is full when
+// a message is published the subscriber is considered too slow and is closed,
+// so one stuck client can never block publishers.
+type Subscriber struct {
+	broker   *Broker
+	messages chan Message
+	done     chan struct{}
+	once     sync.Once
+	dropped  atomic.Bool
+
+	mu       sync.Mutex
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+func NewBroker(bufferSize int) *Broker {
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+	return &Broker{
+		channels:   make(map[string]map[*Subscriber]struct{}),
+		patterns:   make(map[string]map[*Subscriber]struct{}),
+		bufferSize: bufferSize,
+	}
+}
+
+func (b *Broker) NewSubscriber() *Subscriber {
+	return &Subscriber{
+		broker:   b,
+		messages: make(chan Message, b.bufferSize),
+		done:     make(chan struct{}),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+}
+
+// Publish delivers payload to every subscriber of channel and of every matching
+// pattern, and returns the number of deliveries.
+func (b *Broker) Publish(channel, payload string) int {
+	var slow []*Subscriber
+	receivers := 0
+
+	b.mu.RLock()
+	for sub := range b.channels[channel] {
+		if sub.deliver(Message{Channel: channel, Payload: payload}) {
+			receivers++
+		} else {
+			slow = append(slow, sub)
+		}
+	}
+	keyspace := strings.HasPrefix(channel, KeyspacePrefix)
+	for pattern, subs := range b.patterns {
+		if (keyspace && !strings.HasPrefix(pattern, KeyspacePrefix)) || !glob.Match(pattern, channel) {
+			continue
+		}
+		for sub := range subs {
+			if sub.deliver(Message{Channel: channel, Pattern: pattern, Payload: payload}) {
+				receivers++
+			} else {
+				slow = append(slow, sub)
+			}
+		}
+	}
+	b.mu.RUnlock()
+
+	// closing takes the write lock, so it has to wait until the fan-out is done
+	for _, sub := range slow {
+		if sub.dropped.CompareAndSwap(false, true) {
+			logger.Warn("Disconnecting slow pub/sub consumer", "channel", channel, "buffer", b.bufferSize)
+		}
+		sub.Close()
+	}
+	logger.Debug("PUBLISH", "channel", channel, "receivers", receivers)
+	return receivers
+}
+
+// NumSub returns the number of subscribers of channel, not counting patterns
+func (b *Broker) NumSub(channel string) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.channels[channel])
+}
+
+// NumPat returns the number of distinct patterns subscribed to
+func (b *Broker) NumPat() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.patterns)
+}
+
+// Channels returns the channels with at least one subscriber that match pattern
+// (every channel when pattern is empty)
+func (b *Broker) Channels(pattern string) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	channels := make([]string, 0, len(b.channels))
+	for channel := range b.channels {
+		if pattern == "" || glob.Match(pattern, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+func (b *Broker) add(index map[string]map[*Subscriber]struct{}, name string, sub *Subscriber) {
+	subs, ok := index[name]
+	if !ok {
+		subs = make(map[*Subscriber]struct{})
+		index[name] = subs
+	}
+	subs[sub] = struct{}{}
+}
+
+func (b *Broker) remove(index map[string]map[*Subscriber]struct{}, name string, sub *Subscriber) {
+	if subs, ok := index[name]; ok {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(index, name)
+		}
+	}
+}
+
+// deliver never blocks; it reports false when the buffer is full
+func (s *Subscriber) deliver(msg Message) bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+	}
+	select {
+	case s.messages <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+// Subscribe adds channels and returns the total number of subscriptions
+func (s *Subscriber) Subscribe(channels ...string) int {
+	return s.update(channels, s.channels, s.broker.channels, true)
+}
+
+// PSubscribe adds glob patterns and returns the total number of subscriptions
+func (s *Subscriber) PSubscribe(patterns ...string) int {
+	return s.update(patterns, s.patterns, s.broker.patterns, true)
+}
+
+// Unsubscribe removes channels, or every channel when none are given
+func (s *Subscriber) Unsubscribe(channels ...string) int {
+	if len(channels) == 0 {
+		channels = s.Channels()
+	}
+	return s.update(channels, s.channels, s.broker.channels, false)
+}
+
+// PUnsubscribe removes patterns, or every pattern when none are given
+func (s *Subscriber) PUnsubscribe(patterns ...string) int {
+	if len(patterns) == 0 {
+		patterns = s.Patterns()
+	}
+	return s.update(patterns, s.patterns, s.broker.patterns, false)
+}
+
+func (s *Subscriber) update(names []string, own map[string]struct{}, index map[string]map[*Subscriber]struct{}, add bool) int {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return len(s.channels) + len(s.patterns)
+	default:
+	}
+
+	for _, name := range names {
+		if add {
+			own[name] = struct{}{}
+			s.broker.add(index, name, s)
+		} else {
+			delete(own, name)
+			s.broker.remove(index, name, s)
+		}
+	}
+	return len(s.channels) + len(s.patterns)
+}
+
+func (s *Subscriber) Channels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return keys(s.channels)
+}
+
+func (s *Subscriber) Patterns() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return keys(s.patterns)
+}
+
+// Messages is never closed; select on Done as well to notice the end of the subscription
+func (s *Subscriber) Messages() <-chan Message {
+	return s.messages
+}
+
+// Done is closed once the subscriber is closed, either by its owner or for being too slow
+func (s *Subscriber) Done() <-chan struct{} {
+	return s.done
+}
+
+// Dropped reports whether the subscriber was disconnected because its buffer overflowed
+func (s *Subscriber) Dropped() bool {
+	return s.dropped.Load()
+}
+
+// Close removes every subscription. It is safe to call more than once.
+func (s *Subscriber) Close() {
+	s.once.Do(func() {
+		s.broker.mu.Lock()
+		s.mu.Lock()
+		for channel := range s.channels {
+			s.broker.remove(s.broker.channels, channel, s)
+		}
+		for pattern := range s.patterns {
+			s.broker.remove(s.broker.patterns, pattern, s)
+		}
+		close(s.done)
+		s.mu.Unlock()
+		s.broker.mu.Unlock()
+	})
+}
+
+func keys(m map[string]struct{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
@@ -0,0 +1,1094 @@
+// Package raft runs a store as one member of a cluster that agrees on every
+// write with the Raft consensus algorithm. Writes are only accepted by the
+// leader, appended to its log as entries holding the aof.Operation records
+// the write produced and acknowledged once a majority of the cluster has
+// stored them. Every member applies committed entries to its own store, so a
+// cluster of 2f+1 nodes keeps serving writes with f of them down.
+//
+// The log takes the place of the AOF: it is kept as checksummed JSON lines in
+// the node's data directory, and once it grows past a threshold the store is
+// written as a binary rdb snapshot and the entries it covers are dropped.
+// Followers too far behind to be caught up from the log receive the snapshot.
+//
+// The leader applies a write to its store as it proposes it, one entry per
+// command, and keeps the store locked until the entry is committed, so reads
+// never see a write the cluster has not agreed on. A write that cannot be
+// committed fails; if the leader lost leadership with proposals uncommitted,
+// the store is rebuilt from the snapshot and the committed log, so nothing
+// that was not agreed on survives. Until that rebuild has run, reads on the
+// old leader can still see the failed write.
+package raft
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/aof"
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/rdb"
+)
+
+var (
+	ErrNotLeader      = errors.New("not the cluster leader")
+	ErrLeadershipLost = errors.New("leadership lost before the write was committed")
+	ErrCommitTimeout  = errors.New("write was not committed in time")
+	ErrClosed         = errors.New("raft node is closed")
+)
+
+type State int
+
+const (
+	Follower State = iota
+	Candidate
+	Leader
+)
+
+func (s State) String() string {
+	switch s {
+	case Candidate:
+		return "candidate"
+	case Leader:
+		return "leader"
+	}
+	return "follower"
+}
+
+// Entry is one position of the replicated log. A new leader appends an entry
+// without operations to commit everything left over from earlier terms.
+type Entry struct {
+	Term  uint64          `json:"term"`
+	Index uint64          `json:"index"`
+	Ops   []aof.Operation `json:"ops,omitempty"`
+}
+
+// StateMachine is what the log is applied to; *store.Store satisfies it
+type StateMachine interface {
+	// Apply applies the operations of one entry atomically
+	Apply(ops []aof.Operation) error
+	// Fork copies the state, calling mark while no write can happen
+	Fork(mark func()) (map[string]domain.Value, map[string]time.Time)
+	// Load replaces the state with an rdb snapshot
+	Load(r io.Reader) error
+	SetReadOnly(readOnly bool)
+}
+
+type Config struct {
+	ID string
+	// Peers maps the id of every member, this node included, to its HTTP base URL
+	Peers map[string]string
+	Dir   string
+	// ElectionTimeout is the minimum time without a leader before an election; the
+	// actual timeout is randomised up to twice as long
+	ElectionTimeout time.Duration
+	// HeartbeatInterval should be well below ElectionTimeout, by default a fifth of it
+	HeartbeatInterval time.Duration
+	// SnapshotThreshold is the number of applied entries that triggers compaction
+	SnapshotThreshold uint64
+	// CommitTimeout bounds how long a write waits to be committed
+	CommitTimeout time.Duration
+}
+
+// Node is one member of the cluster. Lock order: the store lock is taken before
+// n.mu (Propose, Wait and Fork's mark run under it), never the other way around.
+type Node struct {
+	cfg       Config
+	peers     []string
+	sm        StateMachine
+	transport Transport
+	storage   *storage
+
+	mu       sync.Mutex
+	state    State
+	term     uint64
+	votedFor string
+	leader   string
+
+	log       []Entry // entries after the snapshot
+	snapIndex uint64
+	snapTerm  uint64
+
+	commitIndex uint64
+	lastApplied uint64
+	// durable is the last entry of the leader's own log known to be fsynced
+	durable uint64
+	// speculated is the last entry the leader applied to the store when proposing it
+	speculated uint64
+	// dirty is set when the store may hold writes that will never be committed
+	dirty bool
+	// restore asks the applier to load the snapshot into the store
+	restore bool
+	// writable mirrors whether the store accepts writes, see applier
+	writable  bool
+	noopIndex uint64
+
+	nextIndex  map[string]uint64
+	matchIndex map[string]uint64
+	lastAck    map[string]time.Time
+
+	electionDeadline time.Time
+	// committed is closed and replaced whenever commitIndex moves or leadership changes
+	committed chan struct{}
+
+	applyKick     chan struct{}
+	persistKick   chan struct{}
+	replicateKick map[string]chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewNode restores the node's state from cfg.Dir. sm should be empty: it is
+// loaded from the snapshot and then brought up to date from the log.
+func NewNode(cfg Config, sm StateMachine, transport Transport) (*Node, error) {
+	if cfg.ElectionTimeout <= 0 {
+		cfg.ElectionTimeout = time.Second
+	}
+	if cfg.HeartbeatInterval <= 0 {
+		cfg.HeartbeatInterval = cfg.ElectionTimeout / 5
+	}
+	if cfg.SnapshotThreshold == 0 {
+		cfg.SnapshotThreshold = 10000
+	}
+	if cfg.CommitTimeout <= 0 {
+		cfg.CommitTimeout = 5 * time.Second
+	}
+
+	st, err := openStorage(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	hs, err := st.loadState()
+	if err != nil {
+		return nil, err
+	}
+	snap, err := st.loadSnapshotInfo()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := st.loadLog(snap.Index)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Node{
+		cfg:           cfg,
+		sm:            sm,
+		transport:     transport,
+		storage:       st,
+		term:          hs.Term,
+		votedFor:      hs.VotedFor,
+		log:           entries,
+		snapIndex:     snap.Index,
+		snapTerm:      snap.Term,
+		commitIndex:   snap.Index,
+		restore:       snap.Index > 0,
+		committed:     make(chan struct{}),
+		applyKick:     make(chan struct{}, 1),
+		persistKick:   make(chan struct{}, 1),
+		replicateKick: make(map[string]chan struct{}),
+	}
+	for id := range cfg.Peers {
+		if id != cfg.ID {
+			n.peers = append(n.peers, id)
+			n.replicateKick[id] = make(chan struct{}, 1)
+		}
+	}
+	sort.Strings(n.peers)
+	logger.Info("Raft node restored", "id", cfg.ID, "term", n.term, "snapshot", n.snapIndex, "lastIndex", n.lastIndex())
+	return n, nil
+}
+
+// Start begins taking part in elections and replication
+func (n *Node) Start() {
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	n.sm.SetReadOnly(true)
+
+	n.mu.Lock()
+	n.resetElectionDeadline()
+	n.mu.Unlock()
+	kick(n.applyKick)
+
+	n.run(n.ticker)
+	n.run(n.applier)
+	n.run(n.persister)
+	for _, peer := range n.peers {
+		n.run(func() { n.replicator(peer) })
+	}
+}
+
+func (n *Node) run(fn func()) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		fn()
+	}()
+}
+
+func (n *Node) Close() error {
+	if n.cancel == nil {
+		return n.storage.close()
+	}
+	n.cancel()
+	n.wg.Wait()
+	n.mu.Lock()
+	n.notifyCommitted()
+	n.mu.Unlock()
+	return n.storage.close()
+}
+
+func kick(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// -- log helpers, all require n.mu --
+
+func (n *Node) lastIndex() uint64 {
+	return n.snapIndex + uint64(len(n.log))
+}
+
+func (n *Node) lastTerm() uint64 {
+	if len(n.log) == 0 {
+		return n.snapTerm
+	}
+	return n.log[len(n.log)-1].Term
+}
+
+// termAt returns the term of the entry at index, 0 if it is not known
+func (n *Node) termAt(index uint64) uint64 {
+	if index == n.snapIndex {
+		return n.snapTerm
+	}
+	if index < n.snapIndex || index > n.lastIndex() {
+		return 0
+	}
+	return n.log[index-n.snapIndex-1].Term
+}
+
+func (n *Node) entry(index uint64) Entry {
+	return n.log[index-n.snapIndex-1]
+}
+
+func (n *Node) majority() int {
+	return (len(n.peers)+1)/2 + 1
+}
+
+func (n *Node) notifyCommitted() {
+	close(n.committed)
+	n.committed = make(chan struct{})
+}
+
+func (n *Node) resetElectionDeadline() {
+	timeout := n.cfg.ElectionTimeout + time.Duration(rand.Int63n(int64(n.cfg.ElectionTimeout)))
+	n.electionDeadline = time.Now().Add(timeout)
+}
+
+func (n *Node) persistState() {
+	if err := n.storage.saveState(hardState{Term: n.term, VotedFor: n.votedFor}); err != nil {
+		logger.Error("Failed to persist raft state", "error", err)
+	}
+}
+
+// stepDown moves to term as a follower. Requires n.mu.
+func (n *Node) stepDown(term uint64) {
+	if term > n.term {
+		n.term = term
+		n.votedFor = ""
+		n.leader = ""
+		n.persistState()
+	}
+	if n.state == Leader {
+		logger.Info("Stepping down as leader", "id", n.cfg.ID, "term", n.term)
+		if n.speculated > n.commitIndex {
+			n.dirty = true
+		}
+	}
+	n.state = Follower
+	n.notifyCommitted()
+	kick(n.applyKick)
+}
+
+// -- elections --
+
+// ticker starts elections when the leader has gone quiet, and makes a leader
+// that can no longer reach a majority step down
+func (n *Node) ticker() {
+	ticker := time.NewTicker(n.cfg.ElectionTimeout / 10)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n.mu.Lock()
+		now := time.Now()
+		switch n.state {
+		case Leader:
+			reachable := 1
+			for _, peer := range n.peers {
+				if now.Sub(n.lastAck[peer]) < n.cfg.ElectionTimeout {
+					reachable++
+				}
+			}
+			if reachable < n.majority() {
+				logger.Warn("Lost contact with the majority of the cluster", "id", n.cfg.ID)
+				n.stepDown(n.term)
+				n.resetElectionDeadline()
+			}
+		default:
+			if now.After(n.electionDeadline) {
+				n.startElection()
+			}
+		}
+		n.mu.Unlock()
+	}
+}
+
+// startElection requires n.mu
+func (n *Node) startElection() {
+	n.state = Candidate
+	n.term++
+	n.votedFor = n.cfg.ID
+	n.leader = ""
+	n.persistState()
+	n.resetElectionDeadline()
+	logger.Info("Starting election", "id", n.cfg.ID, "term", n.term)
+
+	term := n.term
+	req := &VoteRequest{Term: term, CandidateID: n.cfg.ID, LastLogIndex: n.lastIndex(), LastLogTerm: n.lastTerm()}
+	votes := 1
+	if votes >= n.majority() {
+		n.becomeLeader()
+		return
+	}
+
+	for _, peer := range n.peers {
+		go func() {
+			ctx, cancel := context.WithTimeout(n.ctx, n.cfg.ElectionTimeout)
+			defer cancel()
+			resp, err := n.transport.RequestVote(ctx, peer, req)
+			if err != nil {
+				logger.Debug("Vote request failed", "peer", peer, "error", err)
+				return
+			}
+
+			n.mu.Lock()
+			defer n.mu.Unlock()
+			if resp.Term > n.term {
+				n.stepDown(resp.Term)
+				return
+			}
+			if !resp.Granted || n.state != Candidate || n.term != term {
+				return
+			}
+			votes++
+			if votes >= n.majority() {
+				n.becomeLeader()
+			}
+		}()
+	}
+}
+
+// becomeLeader requires n.mu
+func (n *Node) becomeLeader() {
+	n.state = Leader
+	n.leader = n.cfg.ID
+	n.nextIndex = make(map[string]uint64)
+	n.matchIndex = make(map[string]uint64)
+	n.lastAck = make(map[string]time.Time)
+	now := time.Now()
+	for _, peer := range n.peers {
+		n.nextIndex[peer] = n.lastIndex() + 1
+		n.lastAck[peer] = now
+	}
+
+	// the log was fsynced before it was acknowledged as a follower
+	n.durable = n.lastIndex()
+	noop := Entry{Term: n.term, Index: n.lastIndex() + 1}
+	if err := n.appendLocal(noop); err != nil {
+		return
+	}
+	n.noopIndex = noop.Index
+	logger.Info("Elected leader", "id", n.cfg.ID, "term", n.term)
+}
+
+// appendLocal adds entries proposed by this node as leader. A leader that cannot
+// write its own log steps down, as counting itself towards a majority would
+// acknowledge entries it does not have. Requires n.mu.
+func (n *Node) appendLocal(entries ...Entry) error {
+	if err := n.storage.append(entries...); err != nil {
+		logger.Error("Failed to append to raft log, stepping down", "error", err)
+		n.stepDown(n.term)
+		return err
+	}
+	n.log = append(n.log, entries...)
+	kick(n.persistKick)
+	for _, ch := range n.replicateKick {
+		kick(ch)
+	}
+	return nil
+}
+
+// persister fsyncs the leader's own log in the background, so proposals made
+// while the disk is busy share one fsync
+func (n *Node) persister() {
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-n.persistKick:
+		}
+
+		n.mu.Lock()
+		target := n.lastIndex()
+		n.mu.Unlock()
+
+		if err := n.storage.sync(); err != nil {
+			logger.Error("Failed to sync raft log, stepping down", "error", err)
+			n.mu.Lock()
+			if n.state == Leader {
+				n.stepDown(n.term)
+			}
+			n.mu.Unlock()
+			continue
+		}
+
+		n.mu.Lock()
+		if n.state == Leader && target > n.durable {
+			n.durable = min(target, n.lastIndex())
+			n.advanceCommit()
+		}
+		n.mu.Unlock()
+	}
+}
+
+// -- proposals --
+
+// Propose implements store.Consensus. It fails on any node but the leader.
+func (n *Node) Propose(ops []aof.Operation) (uint64, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.state != Leader {
+		// the store has already applied these, it has to be rebuilt from the log
+		n.dirty = true
+		kick(n.applyKick)
+		return 0, ErrNotLeader
+	}
+	entry := Entry{Term: n.term, Index: n.lastIndex() + 1, Ops: ops}
+	if err := n.appendLocal(entry); err != nil {
+		n.dirty = true
+		kick(n.applyKick)
+		return 0, err
+	}
+	n.speculated = entry.Index
+	return entry.Index, nil
+}
+
+// Wait implements store.Consensus
+func (n *Node) Wait(index uint64) error {
+	timeout := time.NewTimer(n.cfg.CommitTimeout)
+	defer timeout.Stop()
+
+	for {
+		n.mu.Lock()
+		if n.commitIndex >= index {
+			n.mu.Unlock()
+			return nil
+		}
+		if n.state != Leader {
+			n.mu.Unlock()
+			return ErrLeadershipLost
+		}
+		committed := n.committed
+		n.mu.Unlock()
+
+		select {
+		case <-committed:
+		case <-timeout.C:
+			return ErrCommitTimeout
+		case <-n.ctx.Done():
+			return ErrClosed
+		}
+	}
+}
+
+// -- replication --
+
+func (n *Node) replicator(peer string) {
+	ticker := time.NewTicker(n.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+
+	// an unreachable peer is only retried on heartbeats, not on every proposal
+	unreachable := false
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-n.replicateKick[peer]:
+			if unreachable {
+				continue
+			}
+		}
+		for {
+			more, err := n.replicateTo(peer)
+			unreachable = err != nil
+			if !more {
+				break
+			}
+		}
+	}
+}
+
+// maxBatch bounds the entries sent in one AppendEntries
+const maxBatch = 256
+
+// replicateTo sends peer what it is missing, or a heartbeat if nothing. It
+// reports whether there is more to send right away.
+func (n *Node) replicateTo(peer string) (bool, error) {
+	n.mu.Lock()
+	if n.state != Leader {
+		n.mu.Unlock()
+		return false, nil
+	}
+	term := n.term
+	next := n.nextIndex[peer]
+	if next <= n.snapIndex {
+		n.mu.Unlock()
+		return n.sendSnapshot(peer, term)
+	}
+
+	prev := next - 1
+	end := min(n.lastIndex(), prev+maxBatch)
+	entries := make([]Entry, end-prev)
+	copy(entries, n.log[prev-n.snapIndex:end-n.snapIndex])
+	req := &AppendRequest{
+		Term:         term,
+		LeaderID:     n.cfg.ID,
+		PrevLogIndex: prev,
+		PrevLogTerm:  n.termAt(prev),
+		Entries:      entries,
+		LeaderCommit: n.commitIndex,
+	}
+	n.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(n.ctx, n.cfg.ElectionTimeout)
+	defer cancel()
+	resp, err := n.transport.AppendEntries(ctx, peer, req)
+	if err != nil {
+		logger.Debug("AppendEntries failed", "peer", peer, "error", err)
+		return false, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if resp.Term > n.term {
+		n.stepDown(resp.Term)
+		return false, nil
+	}
+	if n.state != Leader || n.term != term {
+		return false, nil
+	}
+	n.lastAck[peer] = time.Now()
+
+	if !resp.Success {
+		if resp.ConflictIndex == 0 {
+			// the follower failed to store the entries, retry on the next heartbeat
+			return false, nil
+		}
+		n.nextIndex[peer] = max(1, min(resp.ConflictIndex, next-1))
+		return true, nil
+	}
+	if resp.MatchIndex > n.matchIndex[peer] {
+		n.matchIndex[peer] = resp.MatchIndex
+		n.advanceCommit()
+	}
+	n.nextIndex[peer] = n.matchIndex[peer] + 1
+	return n.nextIndex[peer] <= n.lastIndex(), nil
+}
+
+// snapshotChunk is how much of a snapshot goes in one InstallSnapshot
+// request, well under the default HTTP body limit even base64 encoded.
+// A variable so tests can send small snapshots in several chunks.
+var snapshotChunk = 1 << 20
+
+// sendSnapshot streams the latest snapshot to peer in chunks. A follower that
+// lost track of the transfer answers with the offset it expects, and the
+// leader resumes from there a few times before giving up until the next round.
+func (n *Node) sendSnapshot(peer string, term uint64) (bool, error) {
+	info, file, err := n.storage.openSnapshot()
+	if err != nil {
+		logger.Error("Failed to read snapshot for follower", "peer", peer, "error", err)
+		return false, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	size := stat.Size()
+
+	buf := make([]byte, snapshotChunk)
+	var offset int64
+	for resumes := 0; ; {
+		count, err := file.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			logger.Error("Failed to read snapshot for follower", "peer", peer, "error", err)
+			return false, err
+		}
+		next := offset + int64(count)
+		req := &SnapshotRequest{
+			Term: term, LeaderID: n.cfg.ID, Index: info.Index, LogTerm: info.Term,
+			Offset: offset, Data: buf[:count], Done: next >= size,
+		}
+
+		ctx, cancel := context.WithTimeout(n.ctx, 10*n.cfg.ElectionTimeout)
+		resp, err := n.transport.InstallSnapshot(ctx, peer, req)
+		cancel()
+		if err != nil {
+			logger.Debug("InstallSnapshot failed", "peer", peer, "error", err)
+			return false, err
+		}
+
+		n.mu.Lock()
+		if resp.Term > n.term {
+			n.stepDown(resp.Term)
+			n.mu.Unlock()
+			return false, nil
+		}
+		if n.state != Leader || n.term != term {
+			n.mu.Unlock()
+			return false, nil
+		}
+		n.lastAck[peer] = time.Now()
+		n.mu.Unlock()
+
+		if resp.Installed {
+			break
+		}
+		if resp.Offset == next && !req.Done {
+			offset = next
+			continue
+		}
+		if resumes++; resumes > 3 || resp.Offset < 0 || resp.Offset > size {
+			return false, errors.New("follower did not take the snapshot")
+		}
+		offset = resp.Offset
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.state != Leader || n.term != term {
+		return false, nil
+	}
+	logger.Info("Snapshot sent to follower", "peer", peer, "index", info.Index, "bytes", size)
+	n.matchIndex[peer] = max(n.matchIndex[peer], info.Index)
+	n.nextIndex[peer] = n.matchIndex[peer] + 1
+	return n.nextIndex[peer] <= n.lastIndex(), nil
+}
+
+// advanceCommit commits the highest entry of the current term stored on a
+// majority. Entries of earlier terms are committed along with it. Requires n.mu.
+func (n *Node) advanceCommit() {
+	for index := n.lastIndex(); index > n.commitIndex; index-- {
+		if n.termAt(index) != n.term {
+			break
+		}
+		count := 0
+		if n.durable >= index {
+			count++
+		}
+		for _, peer := range n.peers {
+			if n.matchIndex[peer] >= index {
+				count++
+			}
+		}
+		if count >= n.majority() {
+			n.commitIndex = index
+			n.notifyCommitted()
+			kick(n.applyKick)
+			return
+		}
+	}
+}
+
+// -- RPC handlers --
+
+func (n *Node) HandleRequestVote(req *VoteRequest) *VoteResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if req.Term > n.term {
+		n.stepDown(req.Term)
+	}
+	resp := &VoteResponse{Term: n.term}
+	if req.Term < n.term {
+		return resp
+	}
+	upToDate := req.LastLogTerm > n.lastTerm() ||
+		(req.LastLogTerm == n.lastTerm() && req.LastLogIndex >= n.lastIndex())
+	if (n.votedFor == "" || n.votedFor == req.CandidateID) && upToDate {
+		n.votedFor = req.CandidateID
+		n.persistState()
+		n.resetElectionDeadline()
+		resp.Granted = true
+		logger.Debug("Vote granted", "candidate", req.CandidateID, "term", req.Term)
+	}
+	return resp
+}
+
+// acceptLeader handles the term of a request from a leader. Requires n.mu.
+func (n *Node) acceptLeader(term uint64, leader string) bool {
+	if term < n.term {
+		return false
+	}
+	if term > n.term || n.state != Follower {
+		n.stepDown(term)
+	}
+	if n.leader != leader {
+		logger.Info("Following leader", "id", n.cfg.ID, "leader", leader, "term", term)
+	}
+	n.leader = leader
+	n.resetElectionDeadline()
+	return true
+}
+
+func (n *Node) HandleAppendEntries(req *AppendRequest) *AppendResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	resp := &AppendResponse{Term: n.term}
+	if !n.acceptLeader(req.Term, req.LeaderID) {
+		return resp
+	}
+	resp.Term = n.term
+
+	// entries already covered by our snapshot are committed and cannot conflict
+	prev, prevTerm, entries := req.PrevLogIndex, req.PrevLogTerm, req.Entries
+	if prev < n.snapIndex {
+		skip := min(n.snapIndex-prev, uint64(len(entries)))
+		entries = entries[skip:]
+		prev, prevTerm = n.snapIndex, n.snapTerm
+	}
+
+	if prev > n.lastIndex() {
+		resp.ConflictIndex = n.lastIndex() + 1
+		return resp
+	}
+	if term := n.termAt(prev); term != prevTerm {
+		// skip back over the whole conflicting term
+		index := prev
+		for index > n.snapIndex+1 && n.termAt(index-1) == term {
+			index--
+		}
+		resp.ConflictIndex = index
+		return resp
+	}
+
+	appended := false
+	for i, e := range entries {
+		if e.Index <= n.lastIndex() {
+			if n.termAt(e.Index) == e.Term {
+				continue
+			}
+			n.log = n.log[:e.Index-n.snapIndex-1]
+			if err := n.storage.rewrite(n.log); err != nil {
+				logger.Error("Failed to truncate raft log", "error", err)
+				return resp
+			}
+		}
+		// only entries that reached the log file count as present on a retry
+		if err := n.storage.append(entries[i:]...); err != nil {
+			logger.Error("Failed to append to raft log", "error", err)
+			return resp
+		}
+		n.log = append(n.log, entries[i:]...)
+		appended = true
+		break
+	}
+	// acknowledging entries promises they are on disk
+	if appended {
+		if err := n.storage.sync(); err != nil {
+			logger.Error("Failed to sync raft log", "error", err)
+			return resp
+		}
+	}
+
+	last := prev + uint64(len(entries))
+	if req.LeaderCommit > n.commitIndex {
+		n.commitIndex = min(req.LeaderCommit, last)
+		n.notifyCommitted()
+		kick(n.applyKick)
+	}
+	resp.Success = true
+	resp.MatchIndex = last
+	return resp
+}
+
+func (n *Node) HandleInstallSnapshot(req *SnapshotRequest) *SnapshotResponse {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	resp := &SnapshotResponse{Term: n.term}
+	if !n.acceptLeader(req.Term, req.LeaderID) {
+		return resp
+	}
+	resp.Term = n.term
+	if req.Index <= n.commitIndex {
+		// everything the snapshot covers is already here
+		resp.Installed = true
+		return resp
+	}
+
+	next, err := n.storage.receiveSnapshot(req.Index, req.LogTerm, req.Offset, req.Data)
+	resp.Offset = next
+	if err != nil {
+		logger.Error("Failed to receive snapshot", "error", err)
+		return resp
+	}
+	if !req.Done || next != req.Offset+int64(len(req.Data)) {
+		return resp
+	}
+	if err := n.storage.finishSnapshot(); err != nil {
+		logger.Error("Failed to install snapshot", "error", err)
+		resp.Offset = 0
+		return resp
+	}
+	resp.Installed = true
+
+	// keep what follows the snapshot if our log agrees with it, drop the rest
+	if n.termAt(req.Index) == req.LogTerm {
+		n.log = append([]Entry(nil), n.log[req.Index-n.snapIndex:]...)
+	} else {
+		n.log = nil
+	}
+	n.snapIndex, n.snapTerm = req.Index, req.LogTerm
+	if err := n.storage.rewrite(n.log); err != nil {
+		logger.Error("Failed to rewrite raft log", "error", err)
+	}
+	n.commitIndex = req.Index
+	n.restore = true
+	n.notifyCommitted()
+	kick(n.applyKick)
+	logger.Info("Snapshot installed", "index", req.Index, "term", req.LogTerm)
+	return resp
+}
+
+// -- applying --
+
+// applier is the only goroutine that changes the store on behalf of the log:
+// it applies committed entries, loads snapshots, rebuilds the store when it
+// holds writes that were never committed, switches it between read only and
+// writable as leadership changes, and compacts the log.
+func (n *Node) applier() {
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-n.applyKick:
+		}
+		n.applyCommitted()
+		n.updateWritable()
+		n.maybeCompact()
+	}
+}
+
+func (n *Node) applyCommitted() {
+	for {
+		n.mu.Lock()
+		if n.dirty || n.restore {
+			n.mu.Unlock()
+			// stop taking writes before throwing away what the store holds
+			n.setWritable(false)
+			if err := n.restoreSnapshot(); err != nil {
+				logger.Error("Failed to restore store from snapshot", "error", err)
+				return
+			}
+			continue
+		}
+		if n.lastApplied >= n.commitIndex {
+			n.mu.Unlock()
+			return
+		}
+		index := n.lastApplied + 1
+		entry := n.entry(index)
+		// the leader applied its own proposals when it made them
+		speculated := index <= n.speculated
+		n.mu.Unlock()
+
+		if !speculated && len(entry.Ops) > 0 {
+			if err := n.sm.Apply(entry.Ops); err != nil {
+				logger.Error("Failed to apply raft entry", "index", index, "error", err)
+			}
+		}
+
+		n.mu.Lock()
+		if !n.dirty && !n.restore {
+			n.lastApplied = index
+		}
+		n.mu.Unlock()
+	}
+}
+
+// restoreSnapshot loads the latest snapshot into the store, after which the
+// committed log is applied on top of it
+func (n *Node) restoreSnapshot() error {
+	info, data, err := n.storage.readSnapshot()
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		var buf bytes.Buffer
+		if err := rdb.Write(&buf, nil, nil); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	if err := n.sm.Load(bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.dirty {
+		logger.Warn("Discarding uncommitted writes", "id", n.cfg.ID, "speculated", n.speculated, "commitIndex", n.commitIndex)
+	}
+	n.lastApplied = info.Index
+	n.speculated = 0
+	n.dirty = false
+	n.restore = false
+	return nil
+}
+
+// updateWritable lets clients write once this node leads and has applied
+// everything committed before its term, so its proposals build on the full state
+func (n *Node) updateWritable() {
+	n.mu.Lock()
+	writable := n.state == Leader && !n.dirty && n.lastApplied >= n.noopIndex
+	n.mu.Unlock()
+	n.setWritable(writable)
+}
+
+func (n *Node) setWritable(writable bool) {
+	n.mu.Lock()
+	changed := n.writable != writable
+	n.writable = writable
+	n.mu.Unlock()
+	if changed {
+		n.sm.SetReadOnly(!writable)
+	}
+}
+
+// maybeCompact snapshots the store and drops the log entries it covers once
+// enough have been applied. On the leader the store may be ahead of what is
+// committed; the snapshot is skipped then and tried again later.
+func (n *Node) maybeCompact() {
+	n.mu.Lock()
+	due := n.lastApplied-n.snapIndex >= n.cfg.SnapshotThreshold
+	n.mu.Unlock()
+	if !due {
+		return
+	}
+
+	var index, term uint64
+	ok := true
+	data, expires := n.sm.Fork(func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		index = max(n.lastApplied, n.speculated)
+		term = n.termAt(index)
+		ok = index <= n.commitIndex && !n.dirty && !n.restore
+	})
+	if !ok {
+		return
+	}
+
+	if err := n.storage.saveSnapshot(index, term, func(w io.Writer) error {
+		return rdb.Write(w, data, expires)
+	}); err != nil {
+		logger.Error("Failed to write raft snapshot", "error", err)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if index <= n.snapIndex {
+		return
+	}
+	n.log = append([]Entry(nil), n.log[index-n.snapIndex:]...)
+	n.snapIndex, n.snapTerm = index, term
+	// the leader's snapshot may cover proposals the applier has not reached yet,
+	// which it would skip anyway as they are already in the store
+	n.lastApplied = max(n.lastApplied, index)
+	if err := n.storage.rewrite(n.log); err != nil {
+		logger.Error("Failed to compact raft log", "error", err)
+		return
+	}
+	logger.Info("Raft log compacted", "id", n.cfg.ID, "snapshot", index, "remaining", len(n.log))
+}
+
+// -- status --
+
+type PeerStatus struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	MatchIndex  uint64    `json:"matchIndex"`
+	NextIndex   uint64    `json:"nextIndex"`
+	LastContact time.Time `json:"lastContact"`
+}
+
+type Status struct {
+	ID            string       `json:"id"`
+	State         string       `json:"state"`
+	Term          uint64       `json:"term"`
+	Leader        string       `json:"leader"`
+	LeaderURL     string       `json:"leaderUrl,omitempty"`
+	CommitIndex   uint64       `json:"commitIndex"`
+	LastApplied   uint64       `json:"lastApplied"`
+	LastIndex     uint64       `json:"lastIndex"`
+	SnapshotIndex uint64       `json:"snapshotIndex"`
+	Peers         []PeerStatus `json:"peers,omitempty"`
+}
+
+func (n *Node) Status() Status {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	status := Status{
+		ID:            n.cfg.ID,
+		State:         n.state.String(),
+		Term:          n.term,
+		Leader:        n.leader,
+		LeaderURL:     n.cfg.Peers[n.leader],
+		CommitIndex:   n.commitIndex,
+		LastApplied:   n.lastApplied,
+		LastIndex:     n.lastIndex(),
+		SnapshotIndex: n.snapIndex,
+	}
+	if n.state == Leader {
+		for _, peer := range n.peers {
+			status.Peers = append(status.Peers, PeerStatus{
+				ID:          peer,
+				URL:         n.cfg.Peers[peer],
+				MatchIndex:  n.matchIndex[peer],
+				NextIndex:   n.nextIndex[peer],
+				LastContact: n.lastAck[peer],
+			})
+		}
+	}
+	return status
+}
@@ -0,0 +1,386 @@
+package raft
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+var errUnreachable = errors.New("peer unreachable")
+
+// network connects the nodes of a test cluster in memory. A cut member
+// neither sends nor receives anything, as if its cable was pulled.
+type network struct {
+	mu        sync.Mutex
+	nodes     map[string]*Node
+	cut       map[string]bool
+	snapshots int
+}
+
+func (net *network) target(from, to string) (*Node, error) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	node, ok := net.nodes[to]
+	if !ok || net.cut[from] || net.cut[to] {
+		return nil, errUnreachable
+	}
+	return node, nil
+}
+
+func (net *network) setCut(id string, cut bool) {
+	net.mu.Lock()
+	defer net.mu.Unlock()
+	net.cut[id] = cut
+}
+
+// loopback is the Transport of one node; requests and responses go through
+// JSON like they do over HTTP, so no memory is shared between nodes
+type loopback struct {
+	net  *network
+	from string
+}
+
+func wire[T any](v *T) *T {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	out := new(T)
+	if err := json.Unmarshal(data, out); err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func (l *loopback) RequestVote(ctx context.Context, peer string, req *VoteRequest) (*VoteResponse, error) {
+	node, err := l.net.target(l.from, peer)
+	if err != nil {
+		return nil, err
+	}
+	return wire(node.HandleRequestVote(wire(req))), nil
+}
+
+func (l *loopback) AppendEntries(ctx context.Context, peer string, req *AppendRequest) (*AppendResponse, error) {
+	node, err := l.net.target(l.from, peer)
+	if err != nil {
+		return nil, err
+	}
+	return wire(node.HandleAppendEntries(wire(req))), nil
+}
+
+func (l *loopback) InstallSnapshot(ctx context.Context, peer string, req *SnapshotRequest) (*SnapshotResponse, error) {
+	node, err := l.net.target(l.from, peer)
+	if err != nil {
+		return nil, err
+	}
+	l.net.mu.Lock()
+	l.net.snapshots++
+	l.net.mu.Unlock()
+	return wire(node.HandleInstallSnapshot(wire(req))), nil
+}
+
+type cluster struct {
+	t         *testing.T
+	net       *network
+	threshold uint64
+	ids       []string
+	dirs      map[string]string
+	nodes     map[string]*Node
+	stores    map[string]*store.Store
+}
+
+// newCluster starts three nodes, each with its own store and data directory
+func newCluster(t *testing.T, threshold uint64) *cluster {
+	c := &cluster{
+		t:         t,
+		net:       &network{nodes: make(map[string]*Node), cut: make(map[string]bool)},
+		threshold: threshold,
+		ids:       []string{"n1", "n2", "n3"},
+		dirs:      make(map[string]string),
+		nodes:     make(map[string]*Node),
+		stores:    make(map[string]*store.Store),
+	}
+	for _, id := range c.ids {
+		c.dirs[id] = t.TempDir()
+		c.start(id)
+	}
+	t.Cleanup(func() {
+		for _, id := range c.ids {
+			c.stop(id)
+		}
+	})
+	return c
+}
+
+// start runs the node id from its data directory, as after a restart
+func (c *cluster) start(id string) {
+	c.t.Helper()
+	peers := make(map[string]string)
+	for _, peer := range c.ids {
+		peers[peer] = "loopback://" + peer
+	}
+	db := store.NewStore()
+	node, err := NewNode(Config{
+		ID:                id,
+		Peers:             peers,
+		Dir:               c.dirs[id],
+		ElectionTimeout:   100 * time.Millisecond,
+		SnapshotThreshold: c.threshold,
+		CommitTimeout:     2 * time.Second,
+	}, db, &loopback{net: c.net, from: id})
+	if err != nil {
+		c.t.Fatalf("NewNode %s: %v", id, err)
+	}
+	db.SetConsensus(node)
+	node.Start()
+
+	c.net.mu.Lock()
+	c.net.nodes[id] = node
+	c.net.mu.Unlock()
+	c.nodes[id], c.stores[id] = node, db
+}
+
+func (c *cluster) stop(id string) {
+	node, ok := c.nodes[id]
+	if !ok {
+		return
+	}
+	c.net.mu.Lock()
+	delete(c.net.nodes, id)
+	c.net.mu.Unlock()
+	node.Close()
+	delete(c.nodes, id)
+	delete(c.stores, id)
+}
+
+// leader waits until one of the running nodes leads and takes writes
+func (c *cluster) leader() string {
+	c.t.Helper()
+	var found string
+	c.eventually("a writable leader", func() bool {
+		for id, node := range c.nodes {
+			if node.Status().State == Leader.String() && !c.stores[id].ReadOnly() {
+				found = id
+				return true
+			}
+		}
+		return false
+	})
+	return found
+}
+
+// eventually fails the test if cond does not hold within a few seconds
+func (c *cluster) eventually(what string, cond func() bool) {
+	c.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			c.t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// hasValue waits until the store of id holds key with value
+func (c *cluster) hasValue(id, key, value string) {
+	c.t.Helper()
+	c.eventually(fmt.Sprintf("%s to hold %s=%s", id, key, value), func() bool {
+		got, ok := c.stores[id].Get(key)
+		return ok && got == value
+	})
+}
+
+func (c *cluster) followers(leader string) []string {
+	var ids []string
+	for _, id := range c.ids {
+		if _, ok := c.nodes[id]; ok && id != leader {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func TestElectsOneLeader(t *testing.T) {
+	c := newCluster(t, 0)
+	leader := c.leader()
+
+	term := c.nodes[leader].Status().Term
+	c.eventually("followers to know the leader", func() bool {
+		for _, id := range c.followers(leader) {
+			status := c.nodes[id].Status()
+			if status.State != Follower.String() || status.Leader != leader || status.Term != term {
+				return false
+			}
+		}
+		return true
+	})
+	for _, id := range c.followers(leader) {
+		if err := c.stores[id].Set("k", "v"); !errors.Is(err, store.ErrReadOnly) {
+			t.Fatalf("Set on follower %s = %v, want ErrReadOnly", id, err)
+		}
+	}
+}
+
+func TestWriteCommitsOnMajority(t *testing.T) {
+	c := newCluster(t, 0)
+	leader := c.leader()
+	followers := c.followers(leader)
+
+	// one follower down still leaves a majority
+	c.net.setCut(followers[0], true)
+	if err := c.stores[leader].Set("k", "v"); err != nil {
+		t.Fatalf("Set with a majority: %v", err)
+	}
+	c.hasValue(followers[1], "k", "v")
+
+	// the follower that missed the write catches up from the log
+	c.net.setCut(followers[0], false)
+	c.hasValue(followers[0], "k", "v")
+
+	// without a majority the write is not acknowledged
+	c.net.setCut(followers[0], true)
+	c.net.setCut(followers[1], true)
+	if err := c.stores[leader].Set("k", "lost"); err == nil {
+		t.Fatal("Set without a majority succeeded")
+	}
+}
+
+func TestWriteSurvivesLeaderLoss(t *testing.T) {
+	c := newCluster(t, 0)
+	old := c.leader()
+	if err := c.stores[old].Set("k", "v"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	c.stop(old)
+	leader := c.leader()
+	if leader == old {
+		t.Fatal("stopped node still leads")
+	}
+	c.hasValue(leader, "k", "v")
+	if err := c.stores[leader].Set("k2", "v2"); err != nil {
+		t.Fatalf("Set on new leader: %v", err)
+	}
+
+	// the old leader restarts from its directory and catches up
+	c.start(old)
+	c.hasValue(old, "k", "v")
+	c.hasValue(old, "k2", "v2")
+}
+
+func TestStaleLeaderLogIsTruncated(t *testing.T) {
+	c := newCluster(t, 0)
+	old := c.leader()
+	if err := c.stores[old].Set("k", "v"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// cut off, the leader appends an entry it can never commit
+	c.net.setCut(old, true)
+	if err := c.stores[old].Set("stale", "x"); err == nil {
+		t.Fatal("write on a cut off leader succeeded")
+	}
+
+	var leader string
+	c.eventually("a new leader", func() bool {
+		for _, id := range c.followers(old) {
+			if c.nodes[id].Status().State == Leader.String() && !c.stores[id].ReadOnly() {
+				leader = id
+				return true
+			}
+		}
+		return false
+	})
+	if err := c.stores[leader].Set("k", "new"); err != nil {
+		t.Fatalf("Set on new leader: %v", err)
+	}
+
+	// back in touch, the old leader drops its entry for the new leader's
+	c.net.setCut(old, false)
+	c.hasValue(old, "k", "new")
+	c.eventually("the stale write to be discarded", func() bool {
+		_, ok := c.stores[old].Get("stale")
+		return !ok
+	})
+
+	lead, stale := c.nodes[leader], c.nodes[old]
+	c.eventually("the logs to match", func() bool {
+		lead.mu.Lock()
+		defer lead.mu.Unlock()
+		stale.mu.Lock()
+		defer stale.mu.Unlock()
+		if stale.lastIndex() != lead.lastIndex() {
+			return false
+		}
+		for index := max(lead.snapIndex, stale.snapIndex) + 1; index <= lead.lastIndex(); index++ {
+			if stale.termAt(index) != lead.termAt(index) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestLaggingFollowerGetsSnapshot(t *testing.T) {
+	chunk := snapshotChunk
+	snapshotChunk = 64
+	t.Cleanup(func() { snapshotChunk = chunk })
+
+	c := newCluster(t, 5)
+	leader := c.leader()
+	lagging := c.followers(leader)[0]
+	c.net.setCut(lagging, true)
+
+	for i := range 20 {
+		if err := c.stores[leader].Set(fmt.Sprintf("key%d", i), fmt.Sprintf("value%d", i)); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	// the entries the follower misses are compacted away on the leader
+	c.eventually("the leader to compact its log", func() bool {
+		return c.nodes[leader].Status().SnapshotIndex > c.nodes[lagging].Status().LastIndex
+	})
+
+	c.net.setCut(lagging, false)
+	for i := range 20 {
+		c.hasValue(lagging, fmt.Sprintf("key%d", i), fmt.Sprintf("value%d", i))
+	}
+	c.net.mu.Lock()
+	sent := c.net.snapshots
+	c.net.mu.Unlock()
+	if sent < 2 {
+		t.Fatalf("snapshot sent in %d requests, want several chunks", sent)
+	}
+	if status := c.nodes[lagging].Status(); status.SnapshotIndex == 0 {
+		t.Fatal("follower did not install the snapshot")
+	}
+}
+
+// A leader whose log cannot be written fails the write and steps down rather
+// than counting itself towards the majority
+func TestLeaderStepsDownWhenItsLogFails(t *testing.T) {
+	c := newCluster(t, 0)
+	old := c.leader()
+	st := c.nodes[old].storage
+	st.mu.Lock()
+	st.file.Close()
+	st.mu.Unlock()
+
+	if err := c.stores[old].Set("k", "v"); err == nil {
+		t.Fatal("Set on a leader that cannot write its log succeeded")
+	}
+	if state := c.nodes[old].Status().State; state == Leader.String() {
+		t.Fatalf("leader with a failed log is still %s", state)
+	}
+	for _, id := range c.followers(old) {
+		if got, ok := c.stores[id].Get("k"); ok {
+			t.Fatalf("follower %s holds k=%s from a failed write", id, got)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package raft
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mrpurushotam/mini_db/internal/aof"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+)
+
+const (
+	stateFile    = "state.json"
+	logFile      = "raft.log"
+	snapshotMeta = "snapshot.json"
+)
+
+// hardState is what a node must never forget across restarts to keep its votes honest
+type hardState struct {
+	Term     uint64 `json:"term"`
+	VotedFor string `json:"votedFor,omitempty"`
+}
+
+// snapshotInfo names the snapshot the log was compacted into
+type snapshotInfo struct {
+	Index uint64 `json:"index"`
+	Term  uint64 `json:"term"`
+	File  string `json:"file"`
+}
+
+// storage keeps the durable part of a node in dir: the hard state, the log as
+// checksummed JSON lines in the same framing as the AOF, and the latest snapshot.
+// It has its own lock so the log can be fsynced without holding the node's.
+type storage struct {
+	dir string
+
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	// size is the length of the log up to its last complete append
+	size int64
+
+	// incoming is the snapshot being received from the leader, used under the node's lock
+	incoming *incomingSnapshot
+}
+
+// incomingSnapshot is a snapshot received chunk by chunk into a .part file
+type incomingSnapshot struct {
+	info snapshotInfo
+	file *os.File
+	size int64
+}
+
+func openStorage(dir string) (*storage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create raft directory: %w", err)
+	}
+	return &storage{dir: dir}, nil
+}
+
+func (st *storage) path(name string) string {
+	return filepath.Join(st.dir, name)
+}
+
+func (st *storage) loadState() (hardState, error) {
+	var hs hardState
+	err := readJSON(st.path(stateFile), &hs)
+	return hs, err
+}
+
+func (st *storage) saveState(hs hardState) error {
+	return writeJSON(st.path(stateFile), hs)
+}
+
+func (st *storage) loadSnapshotInfo() (snapshotInfo, error) {
+	var info snapshotInfo
+	err := readJSON(st.path(snapshotMeta), &info)
+	return info, err
+}
+
+// readSnapshot returns the latest snapshot, or a zero info and no data if there is none
+func (st *storage) readSnapshot() (snapshotInfo, []byte, error) {
+	info, err := st.loadSnapshotInfo()
+	if err != nil || info.File == "" {
+		return info, nil, err
+	}
+	data, err := os.ReadFile(st.path(info.File))
+	if err != nil {
+		return info, nil, fmt.Errorf("failed to read raft snapshot: %w", err)
+	}
+	return info, data, nil
+}
+
+// openSnapshot opens the latest snapshot for reading; the caller closes it
+func (st *storage) openSnapshot() (snapshotInfo, *os.File, error) {
+	info, err := st.loadSnapshotInfo()
+	if err != nil {
+		return info, nil, err
+	}
+	if info.File == "" {
+		return info, nil, errors.New("no raft snapshot to send")
+	}
+	file, err := os.Open(st.path(info.File))
+	if err != nil {
+		return info, nil, fmt.Errorf("failed to open raft snapshot: %w", err)
+	}
+	return info, file, nil
+}
+
+func snapshotName(index, term uint64) string {
+	return fmt.Sprintf("snapshot-%d-%d.rdb", term, index)
+}
+
+// saveSnapshot writes a snapshot through write and then makes it the current one.
+// The previous snapshot file is removed once the metadata points at the new one.
+func (st *storage) saveSnapshot(index, term uint64, write func(io.Writer) error) error {
+	info := snapshotInfo{Index: index, Term: term, File: snapshotName(index, term)}
+
+	file, err := os.Create(st.path(info.File))
+	if err != nil {
+		return fmt.Errorf("failed to create raft snapshot: %w", err)
+	}
+	err = write(file)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(st.path(info.File))
+		return fmt.Errorf("failed to write raft snapshot: %w", err)
+	}
+	return st.useSnapshot(info)
+}
+
+// useSnapshot points the metadata at the snapshot file of info and removes the previous one
+func (st *storage) useSnapshot(info snapshotInfo) error {
+	prev, _ := st.loadSnapshotInfo()
+	if err := writeJSON(st.path(snapshotMeta), info); err != nil {
+		os.Remove(st.path(info.File))
+		return fmt.Errorf("failed to write raft snapshot: %w", err)
+	}
+	if prev.File != "" && prev.File != info.File {
+		os.Remove(st.path(prev.File))
+	}
+	return nil
+}
+
+// receiveSnapshot writes a chunk of the snapshot at index and term and returns
+// the offset of the next chunk it expects. A chunk at offset 0 starts over; a
+// chunk that does not follow the previous one is not written, and the offset
+// returned tells the leader where to resume.
+func (st *storage) receiveSnapshot(index, term uint64, offset int64, data []byte) (int64, error) {
+	in := st.incoming
+	if offset == 0 {
+		st.discardIncoming()
+		info := snapshotInfo{Index: index, Term: term, File: snapshotName(index, term)}
+		file, err := os.Create(st.path(info.File + ".part"))
+		if err != nil {
+			return 0, fmt.Errorf("failed to create raft snapshot: %w", err)
+		}
+		in = &incomingSnapshot{info: info, file: file}
+		st.incoming = in
+	}
+	if in == nil || in.info.Index != index || in.info.Term != term {
+		return 0, nil
+	}
+	if offset != in.size {
+		return in.size, nil
+	}
+	n, err := in.file.Write(data)
+	in.size += int64(n)
+	if err != nil {
+		st.discardIncoming()
+		return 0, fmt.Errorf("failed to write raft snapshot: %w", err)
+	}
+	return in.size, nil
+}
+
+// finishSnapshot makes the snapshot received through receiveSnapshot the current one
+func (st *storage) finishSnapshot() error {
+	in := st.incoming
+	if in == nil {
+		return errors.New("no raft snapshot being received")
+	}
+	st.incoming = nil
+	part := st.path(in.info.File + ".part")
+
+	err := in.file.Sync()
+	if closeErr := in.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(part, st.path(in.info.File))
+	}
+	if err != nil {
+		os.Remove(part)
+		return fmt.Errorf("failed to write raft snapshot: %w", err)
+	}
+	return st.useSnapshot(in.info)
+}
+
+// discardIncoming drops a partly received snapshot
+func (st *storage) discardIncoming() {
+	if st.incoming == nil {
+		return
+	}
+	st.incoming.file.Close()
+	os.Remove(st.path(st.incoming.info.File + ".part"))
+	st.incoming = nil
+}
+
+// loadLog reads the entries following index after. A torn or corrupt tail is cut
+// off: entries are fsynced before they are acknowledged, so it was never promised
+// to anyone.
+func (st *storage) loadLog(after uint64) ([]Entry, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	path := st.path(logFile)
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, st.openLog()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	var valid int64
+	br := bufio.NewReader(file)
+	for {
+		line, readErr := br.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			file.Close()
+			return nil, readErr
+		}
+		if len(line) == 0 {
+			break
+		}
+		entry, err := parseEntry(string(line))
+		next := after + uint64(len(entries)) + 1
+		if err != nil || line[len(line)-1] != '\n' || (entry.Index > after && entry.Index != next) {
+			logger.Warn("Dropping damaged raft log tail", "offset", valid, "error", err)
+			break
+		}
+		valid += int64(len(line))
+		if entry.Index > after {
+			entries = append(entries, entry)
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+	file.Close()
+
+	if err := os.Truncate(path, valid); err != nil {
+		return nil, fmt.Errorf("failed to truncate raft log: %w", err)
+	}
+	return entries, st.openLog()
+}
+
+func parseEntry(line string) (Entry, error) {
+	var entry Entry
+	body, err := aof.VerifyRecord(strings.TrimSpace(line))
+	if err != nil {
+		return entry, err
+	}
+	err = json.Unmarshal([]byte(body), &entry)
+	return entry, err
+}
+
+// openLog opens the log for appending. Requires st.mu.
+func (st *storage) openLog() error {
+	file, err := os.OpenFile(st.path(logFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open raft log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open raft log: %w", err)
+	}
+	st.file = file
+	st.w = bufio.NewWriter(file)
+	st.size = info.Size()
+	return nil
+}
+
+// append hands entries to the OS without waiting for the disk, see sync. It
+// adds all of them or none: a failed append is cut off again, so no partial
+// record is left for later entries to follow.
+func (st *storage) append(entries ...Entry) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	written := 0
+	for _, entry := range entries {
+		b, err := json.Marshal(entry)
+		if err != nil {
+			return st.rollback(err)
+		}
+		n, err := st.w.Write(aof.FrameRecord(b))
+		written += n
+		if err != nil {
+			return st.rollback(err)
+		}
+	}
+	if err := st.w.Flush(); err != nil {
+		return st.rollback(err)
+	}
+	st.size += int64(written)
+	return nil
+}
+
+// rollback drops whatever a failed append buffered or wrote. Requires st.mu.
+func (st *storage) rollback(err error) error {
+	st.w.Reset(st.file)
+	if truncErr := st.file.Truncate(st.size); truncErr != nil {
+		return fmt.Errorf("%w (and failed to cut the partial append: %v)", err, truncErr)
+	}
+	return err
+}
+
+func (st *storage) sync() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err := st.w.Flush(); err != nil {
+		return err
+	}
+	return st.file.Sync()
+}
+
+// rewrite replaces the log with entries, used after compaction and when a
+// leader overwrites a conflicting suffix
+func (st *storage) rewrite(entries []Entry) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	tmp := st.path(logFile + ".tmp")
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite raft log: %w", err)
+	}
+	w := bufio.NewWriter(file)
+	for _, entry := range entries {
+		b, err := json.Marshal(entry)
+		if err == nil {
+			_, err = w.Write(aof.FrameRecord(b))
+		}
+		if err != nil {
+			file.Close()
+			os.Remove(tmp)
+			return fmt.Errorf("failed to rewrite raft log: %w", err)
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, st.path(logFile))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to rewrite raft log: %w", err)
+	}
+
+	st.file.Close()
+	return st.openLog()
+}
+
+func (st *storage) close() error {
+	st.discardIncoming()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.file == nil {
+		return nil
+	}
+	st.w.Flush()
+	return st.file.Close()
+}
+
+func readJSON(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// writeJSON replaces the file at path atomically
+func writeJSON(path string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(b)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
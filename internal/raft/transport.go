@@ -0,0 +1,146 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type VoteRequest struct {
+	Term         uint64 `json:"term"`
+	CandidateID  string `json:"candidateId"`
+	LastLogIndex uint64 `json:"lastLogIndex"`
+	LastLogTerm  uint64 `json:"lastLogTerm"`
+}
+
+type VoteResponse struct {
+	Term    uint64 `json:"term"`
+	Granted bool   `json:"granted"`
+}
+
+type AppendRequest struct {
+	Term         uint64  `json:"term"`
+	LeaderID     string  `json:"leaderId"`
+	PrevLogIndex uint64  `json:"prevLogIndex"`
+	PrevLogTerm  uint64  `json:"prevLogTerm"`
+	Entries      []Entry `json:"entries,omitempty"`
+	LeaderCommit uint64  `json:"leaderCommit"`
+}
+
+// AppendResponse carries the follower's last matching index on success, and on
+// failure the index the leader should retry from, so a lagging follower is found
+// in one round trip per term instead of one per entry
+type AppendResponse struct {
+	Term          uint64 `json:"term"`
+	Success       bool   `json:"success"`
+	MatchIndex    uint64 `json:"matchIndex,omitempty"`
+	ConflictIndex uint64 `json:"conflictIndex,omitempty"`
+}
+
+// SnapshotRequest carries one chunk of a snapshot: Data is the part of the rdb
+// file starting at Offset and Done marks the last chunk. Sending it in chunks
+// keeps every request under the default HTTP body limit however large the store.
+type SnapshotRequest struct {
+	Term     uint64 `json:"term"`
+	LeaderID string `json:"leaderId"`
+	Index    uint64 `json:"index"`
+	LogTerm  uint64 `json:"logTerm"`
+	Offset   int64  `json:"offset"`
+	Data     []byte `json:"data"`
+	Done     bool   `json:"done,omitempty"`
+}
+
+// SnapshotResponse carries the offset the follower expects next, so a leader
+// whose chunk did not line up resumes from there. Installed is set once the
+// follower holds the snapshot, or already held everything it covers.
+type SnapshotResponse struct {
+	Term      uint64 `json:"term"`
+	Offset    int64  `json:"offset"`
+	Installed bool   `json:"installed,omitempty"`
+}
+
+// Transport delivers RPCs to the other members of the cluster, named by their id
+type Transport interface {
+	RequestVote(ctx context.Context, peer string, req *VoteRequest) (*VoteResponse, error)
+	AppendEntries(ctx context.Context, peer string, req *AppendRequest) (*AppendResponse, error)
+	InstallSnapshot(ctx context.Context, peer string, req *SnapshotRequest) (*SnapshotResponse, error)
+}
+
+// HTTPTransport posts RPCs as JSON to the /api/v0/raft routes of each peer
+type HTTPTransport struct {
+	peers  map[string]string
+	client *http.Client
+}
+
+// NewHTTPTransport maps peer ids to the base URL of their HTTP server
+func NewHTTPTransport(peers map[string]string) *HTTPTransport {
+	urls := make(map[string]string, len(peers))
+	for id, url := range peers {
+		urls[id] = strings.TrimSuffix(url, "/")
+	}
+	return &HTTPTransport{peers: urls, client: &http.Client{}}
+}
+
+func (t *HTTPTransport) RequestVote(ctx context.Context, peer string, req *VoteRequest) (*VoteResponse, error) {
+	var resp VoteResponse
+	return &resp, t.post(ctx, peer, "/vote", req, &resp)
+}
+
+func (t *HTTPTransport) AppendEntries(ctx context.Context, peer string, req *AppendRequest) (*AppendResponse, error) {
+	var resp AppendResponse
+	return &resp, t.post(ctx, peer, "/append", req, &resp)
+}
+
+func (t *HTTPTransport) InstallSnapshot(ctx context.Context, peer string, req *SnapshotRequest) (*SnapshotResponse, error) {
+	var resp SnapshotResponse
+	return &resp, t.post(ctx, peer, "/snapshot", req, &resp)
+}
+
+func (t *HTTPTransport) post(ctx context.Context, peer, path string, req, resp any) error {
+	base, ok := t.peers[peer]
+	if !ok {
+		return fmt.Errorf("unknown peer %s", peer)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/api/v0/raft"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := t.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s%s: %s", peer, path, httpResp.Status)
+	}
+	return json.NewDecoder(httpResp.Body).Decode(resp)
+}
+
+// ParsePeers reads a cluster layout such as "n1=http://10.0.0.1:3000,n2=http://10.0.0.2:3000"
+func ParsePeers(s string) (map[string]string, error) {
+	peers := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, url, ok := strings.Cut(part, "=")
+		if !ok || id == "" || url == "" {
+			return nil, fmt.Errorf("invalid peer %q, expected id=url", part)
+		}
+		peers[id] = url
+	}
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("no peers given")
+	}
+	return peers, nil
+}
@@ -0,0 +1,529 @@
+// Package rdb implements a compact binary snapshot of the keyspace, loosely
+// modelled on redis' RDB files. A file is laid out as
+//
+//	magic "MINIDB" + 4 digit version
+//	entries:  [0xFC expiry-ms(8)] type(1) key value
+//	0xFF
+//	CRC-32 (Castagnoli) of everything above, 4 bytes big endian
+//
+// Strings are a uvarint length followed by the bytes; collections are a
+// uvarint count followed by their elements, sorted set scores are
+// stored as the 8 byte IEEE 754 bits and priorities as 8 byte two's complement.
+package rdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	valuepkg "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+const (
+	magic   = "MINIDB"
+	version = "0001"
+
+	opExpireMs = 0xFC
+	opEOF      = 0xFF
+)
+
+// type tags, never reorder: they are part of the file format
+const (
+	typeString    byte = 1
+	typeSet       byte = 2
+	typeList      byte = 3
+	typeQueue     byte = 4
+	typeStack     byte = 5
+	typeHashmap   byte = 6
+	typeSortedSet byte = 7
+	// a queue with leased, redelivered or delayed items, plain queues keep typeQueue
+	typeQueueState    byte = 8
+	typePriorityQueue byte = 9
+	// a hashmap with field deadlines, plain hashmaps keep typeHashmap
+	typeHashmapTTL byte = 10
+)
+
+var (
+	ErrBadMagic = errors.New("not a mini_db snapshot")
+	ErrChecksum = errors.New("snapshot checksum mismatch")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Write encodes data and the deadlines in expires to w
+func Write(w io.Writer, data map[string]domain.Value, expires map[string]time.Time) error {
+	bw := bufio.NewWriter(w)
+	crc := crc32.New(crcTable)
+	e := &encoder{w: io.MultiWriter(bw, crc)}
+
+	e.raw([]byte(magic + version))
+	for key, val := range data {
+		if deadline, ok := expires[key]; ok {
+			e.byte(opExpireMs)
+			e.uint64(uint64(deadline.UnixMilli()))
+		}
+		e.value(key, val)
+	}
+	e.byte(opEOF)
+	if e.err != nil {
+		return e.err
+	}
+
+	if err := binary.Write(bw, binary.BigEndian, crc.Sum32()); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// WriteFile writes a snapshot to path and fsyncs it before returning
+func WriteFile(path string, data map[string]domain.Value, expires map[string]time.Time) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	if err := Write(file, data, expires); err != nil {
+		file.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+	return file.Close()
+}
+
+// Read decodes a snapshot, verifying the checksum before anything is returned
+func Read(r io.Reader) (map[string]domain.Value, map[string]time.Time, error) {
+	br := bufio.NewReader(r)
+	d := &decoder{r: br, crc: crc32.New(crcTable)}
+
+	head := make([]byte, len(magic)+len(version))
+	d.full(head)
+	if d.err != nil || !bytes.HasPrefix(head, []byte(magic)) {
+		return nil, nil, ErrBadMagic
+	}
+	if v := string(head[len(magic):]); v != version {
+		return nil, nil, fmt.Errorf("unsupported snapshot version %s", v)
+	}
+
+	data := make(map[string]domain.Value)
+	expires := make(map[string]time.Time)
+	for d.err == nil {
+		op := d.byte()
+		if op == opEOF {
+			break
+		}
+		var deadline time.Time
+		if op == opExpireMs {
+			deadline = time.UnixMilli(int64(d.uint64()))
+			op = d.byte()
+		}
+		key := d.string()
+		val := d.value(op)
+		if d.err != nil {
+			break
+		}
+		data[key] = val
+		if !deadline.IsZero() {
+			expires[key] = deadline
+		}
+	}
+	if d.err != nil {
+		return nil, nil, fmt.Errorf("failed to decode snapshot: %w", d.err)
+	}
+
+	var sum uint32
+	if err := binary.Read(br, binary.BigEndian, &sum); err != nil {
+		return nil, nil, fmt.Errorf("failed to read snapshot checksum: %w", err)
+	}
+	if sum != d.crc.Sum32() {
+		return nil, nil, ErrChecksum
+	}
+	return data, expires, nil
+}
+
+// ReadFile reads the snapshot stored at path
+func ReadFile(path string) (map[string]domain.Value, map[string]time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// DumpValue serializes a single value like redis' DUMP: the type tag and the
+// encoded value followed by the format version and a CRC-32 of all of it
+func DumpValue(val domain.Value) ([]byte, error) {
+	var buf bytes.Buffer
+	e := &encoder{w: &buf}
+	tag := typeTag(val)
+	if tag == 0 {
+		return nil, fmt.Errorf("cannot encode %s value", val.Type())
+	}
+	e.byte(tag)
+	e.payload(val)
+	e.raw([]byte(version))
+	if e.err != nil {
+		return nil, e.err
+	}
+	return binary.BigEndian.AppendUint32(buf.Bytes(), crc32.Checksum(buf.Bytes(), crcTable)), nil
+}
+
+// RestoreValue decodes the output of DumpValue
+func RestoreValue(b []byte) (domain.Value, error) {
+	if len(b) < 1+len(version)+4 {
+		return nil, ErrChecksum
+	}
+	body, sum := b[:len(b)-4], binary.BigEndian.Uint32(b[len(b)-4:])
+	if crc32.Checksum(body, crcTable) != sum {
+		return nil, ErrChecksum
+	}
+	if v := string(body[len(body)-len(version):]); v != version {
+		return nil, fmt.Errorf("unsupported dump version %s", v)
+	}
+	payload := body[:len(body)-len(version)]
+
+	d := &decoder{r: bufio.NewReader(bytes.NewReader(payload[1:])), crc: crc32.New(crcTable)}
+	val := d.value(payload[0])
+	if d.err != nil {
+		return nil, fmt.Errorf("failed to decode value: %w", d.err)
+	}
+	return val, nil
+}
+
+// encoder keeps the first error so callers can check once at the end
+type encoder struct {
+	w       io.Writer
+	scratch [binary.MaxVarintLen64]byte
+	err     error
+}
+
+func (e *encoder) raw(b []byte) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.Write(b)
+}
+
+func (e *encoder) byte(b byte) {
+	e.raw([]byte{b})
+}
+
+func (e *encoder) uvarint(n uint64) {
+	e.raw(binary.AppendUvarint(e.scratch[:0], n))
+}
+
+func (e *encoder) uint64(n uint64) {
+	e.raw(binary.BigEndian.AppendUint64(e.scratch[:0], n))
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.raw([]byte(s))
+}
+
+func (e *encoder) strings(items []string) {
+	e.uvarint(uint64(len(items)))
+	for _, item := range items {
+		e.string(item)
+	}
+}
+
+func (e *encoder) value(key string, val domain.Value) {
+	tag := typeTag(val)
+	if tag == 0 {
+		if e.err == nil {
+			e.err = fmt.Errorf("cannot encode %s value of key %s", val.Type(), key)
+		}
+		return
+	}
+	e.byte(tag)
+	e.string(key)
+	e.payload(val)
+}
+
+func typeTag(val domain.Value) byte {
+	switch v := val.(type) {
+	case *valuepkg.StringValue:
+		return typeString
+	case *valuepkg.SetValue:
+		return typeSet
+	case *valuepkg.ListValue:
+		return typeList
+	case *valuepkg.QueueValue:
+		if _, delayed := v.NextDue(); delayed || len(v.Leases) > 0 || v.Attempts != nil {
+			return typeQueueState
+		}
+		return typeQueue
+	case *valuepkg.StackValue:
+		return typeStack
+	case *valuepkg.HashmapValue:
+		if len(v.Expires) > 0 {
+			return typeHashmapTTL
+		}
+		return typeHashmap
+	case *valuepkg.SortedSetValue:
+		return typeSortedSet
+	case *valuepkg.PriorityQueueValue:
+		return typePriorityQueue
+	}
+	return 0
+}
+
+func (e *encoder) payload(val domain.Value) {
+	switch v := val.(type) {
+	case *valuepkg.StringValue:
+		e.string(string(v.Data))
+	case *valuepkg.SetValue:
+		e.uvarint(uint64(len(v.Data)))
+		for member := range v.Data {
+			e.string(member)
+		}
+	case *valuepkg.ListValue:
+		e.strings(v.Data)
+	case *valuepkg.QueueValue:
+		e.strings(v.Data)
+		if typeTag(v) == typeQueueState {
+			e.queueState(v)
+		}
+	case *valuepkg.StackValue:
+		e.strings(v.Data)
+	case *valuepkg.HashmapValue:
+		e.uvarint(uint64(len(v.Data)))
+		for field, value := range v.Data {
+			e.string(field)
+			e.string(value)
+		}
+		if typeTag(v) == typeHashmapTTL {
+			// the deadlines follow as field and unix ms pairs
+			e.uvarint(uint64(len(v.Expires)))
+			for field, deadline := range v.Expires {
+				e.string(field)
+				e.uint64(uint64(deadline.UnixMilli()))
+			}
+		}
+	case *valuepkg.SortedSetValue:
+		members := v.Members()
+		e.uvarint(uint64(len(members)))
+		for _, m := range members {
+			e.string(m.Member)
+			e.uint64(math.Float64bits(m.Score))
+		}
+	case *valuepkg.PriorityQueueValue:
+		// in pop order, which pushing them back in keeps ties in order
+		items := v.Items()
+		e.uvarint(uint64(len(items)))
+		for _, item := range items {
+			e.string(item.Value)
+			e.uint64(uint64(item.Priority))
+		}
+	}
+}
+
+// queueState encodes the delivery counts of the queued items, zero of them when
+// none was redelivered, followed by the leases and the delayed items in the
+// order they are due
+func (e *encoder) queueState(q *valuepkg.QueueValue) {
+	e.uvarint(uint64(len(q.Attempts)))
+	for _, n := range q.Attempts {
+		e.uvarint(uint64(n))
+	}
+	e.uvarint(uint64(len(q.Leases)))
+	for _, lease := range q.Leases {
+		e.string(lease.ID)
+		e.string(lease.Value)
+		e.uvarint(uint64(lease.Attempts))
+		e.uint64(uint64(lease.Deadline.UnixMilli()))
+		e.uvarint(uint64(lease.MaxAttempts))
+		e.string(lease.DeadLetter)
+	}
+	scheduled := q.Scheduled()
+	e.uvarint(uint64(len(scheduled)))
+	for _, item := range scheduled {
+		e.string(item.Value)
+		e.uint64(uint64(item.At.UnixMilli()))
+	}
+}
+
+// decoder hashes every byte it consumes, so the trailer can be checked
+// against exactly the bytes before it
+type decoder struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+	err error
+}
+
+func (d *decoder) full(b []byte) {
+	if d.err != nil {
+		return
+	}
+	if _, d.err = io.ReadFull(d.r, b); d.err == nil {
+		d.crc.Write(b)
+	}
+}
+
+func (d *decoder) byte() byte {
+	var b [1]byte
+	d.full(b[:])
+	return b[0]
+}
+
+func (d *decoder) ReadByte() (byte, error) {
+	b := d.byte()
+	return b, d.err
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	n, err := binary.ReadUvarint(d)
+	if err != nil {
+		d.err = err
+	}
+	return n
+}
+
+func (d *decoder) uint64() uint64 {
+	var b [8]byte
+	d.full(b[:])
+	return binary.BigEndian.Uint64(b[:])
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if n <= 4096 {
+		b := make([]byte, n)
+		d.full(b)
+		return string(b)
+	}
+	// large strings grow as bytes actually arrive, so a corrupt length
+	// cannot make us allocate gigabytes before the checksum is checked
+	if n > math.MaxInt64 {
+		d.err = fmt.Errorf("string length %d out of range", n)
+		return ""
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, d.r, int64(n)); err != nil {
+		d.err = err
+		return ""
+	}
+	d.crc.Write(buf.Bytes())
+	return buf.String()
+}
+
+// count reads a collection length and a capacity hint capped for the same reason as string
+func (d *decoder) count() (int, int) {
+	n := d.uvarint()
+	if n > math.MaxInt32 {
+		d.err = fmt.Errorf("collection length %d out of range", n)
+		return 0, 0
+	}
+	return int(n), min(int(n), 1024)
+}
+
+func (d *decoder) strings() []string {
+	n, hint := d.count()
+	items := make([]string, 0, hint)
+	for i := 0; i < n && d.err == nil; i++ {
+		items = append(items, d.string())
+	}
+	return items
+}
+
+func (d *decoder) value(tag byte) domain.Value {
+	switch tag {
+	case typeString:
+		return &valuepkg.StringValue{Data: []byte(d.string())}
+	case typeSet:
+		n, _ := d.count()
+		set := valuepkg.NewSetValue()
+		for i := 0; i < n && d.err == nil; i++ {
+			set.Add(d.string())
+		}
+		return set
+	case typeList:
+		return &valuepkg.ListValue{Data: d.strings()}
+	case typeQueue:
+		return &valuepkg.QueueValue{Data: d.strings()}
+	case typeQueueState:
+		queue := &valuepkg.QueueValue{Data: d.strings()}
+		if n, hint := d.count(); n > 0 {
+			queue.Attempts = make([]int, 0, hint)
+			for i := 0; i < n && d.err == nil; i++ {
+				queue.Attempts = append(queue.Attempts, int(d.uvarint()))
+			}
+		}
+		if n, hint := d.count(); n > 0 {
+			queue.Leases = make(map[string]*valuepkg.QueueLease, hint)
+			for i := 0; i < n && d.err == nil; i++ {
+				lease := &valuepkg.QueueLease{ID: d.string(), Value: d.string(), Attempts: int(d.uvarint())}
+				lease.Deadline = time.UnixMilli(int64(d.uint64()))
+				lease.MaxAttempts = int(d.uvarint())
+				lease.DeadLetter = d.string()
+				queue.Leases[lease.ID] = lease
+			}
+		}
+		n, _ := d.count()
+		for i := 0; i < n && d.err == nil; i++ {
+			item := d.string()
+			queue.Schedule(item, time.UnixMilli(int64(d.uint64())))
+		}
+		if d.err == nil && queue.Attempts != nil && len(queue.Attempts) != len(queue.Data) {
+			d.err = fmt.Errorf("queue has %d delivery counts for %d items", len(queue.Attempts), len(queue.Data))
+		}
+		return queue
+	case typeStack:
+		return &valuepkg.StackValue{Data: d.strings()}
+	case typeHashmap, typeHashmapTTL:
+		n, _ := d.count()
+		hash := valuepkg.NewHashmapValue()
+		for i := 0; i < n && d.err == nil; i++ {
+			field := d.string()
+			hash.Set(field, d.string())
+		}
+		if tag == typeHashmapTTL {
+			n, _ := d.count()
+			for i := 0; i < n && d.err == nil; i++ {
+				field := d.string()
+				hash.ExpireAt(field, time.UnixMilli(int64(d.uint64())))
+			}
+		}
+		return hash
+	case typeSortedSet:
+		n, _ := d.count()
+		zset := valuepkg.NewSortedSetValue()
+		for i := 0; i < n && d.err == nil; i++ {
+			member := d.string()
+			zset.Add(member, math.Float64frombits(d.uint64()))
+		}
+		return zset
+	case typePriorityQueue:
+		n, _ := d.count()
+		pq := valuepkg.NewPriorityQueueValue()
+		for i := 0; i < n && d.err == nil; i++ {
+			value := d.string()
+			pq.Push(value, int64(d.uint64()))
+		}
+		return pq
+	}
+	if d.err == nil {
+		d.err = fmt.Errorf("unknown type tag 0x%02x", tag)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Package replication lets follower instances keep a copy of a leader's
+// keyspace over HTTP. A follower first downloads a binary snapshot together
+// with the replication offset it was taken at, then streams every operation
+// the leader logs after that offset. Operations are the same aof.Operation
+// records the AOF holds, so a follower applies them exactly as a restart
+// would replay them.
+//
+// The leader keeps the most recent operations in a backlog. A follower that
+// reconnects with an offset still covered by the backlog resumes from there,
+// anything older needs a new full sync.
+package replication
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	"github.com/mrpurushotam/mini_db/internal/aof"
+)
+
+var (
+	// ErrResync means the requested offset cannot be served from the backlog
+	ErrResync = errors.New("offset is not in the replication backlog, full sync required")
+)
+
+// Entry is one operation of the replication stream. Entries without an
+// operation are heartbeats that only carry the leader's current offset.
+type Entry struct {
+	Offset uint64         `json:"offset"`
+	Op     *aof.Operation `json:"op,omitempty"`
+}
+
+// Backlog is a bounded ring of the operations most recently logged by the
+// store, numbered by their replication offset. The first operation ever
+// appended has offset 1, so offset 0 is the state before any write.
+type Backlog struct {
+	mu     sync.Mutex
+	id     string
+	ops    []aof.Operation
+	start  int // ring index of the oldest operation
+	count  int
+	last   uint64
+	notify chan struct{}
+}
+
+// NewBacklog keeps the last size operations; size is at least 1
+func NewBacklog(size int) *Backlog {
+	if size <= 0 {
+		size = 1
+	}
+	return &Backlog{
+		id:     newID(),
+		ops:    make([]aof.Operation, size),
+		notify: make(chan struct{}),
+	}
+}
+
+// newID identifies one run of the leader: offsets only mean something
+// together with the id they were handed out under
+func newID() string {
+	b := make([]byte, 10)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (b *Backlog) ID() string {
+	return b.id
+}
+
+// Offset returns the offset of the last operation appended
+func (b *Backlog) Offset() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.last
+}
+
+// Append implements store.Feed. It never blocks: streams waiting for new
+// operations are woken by closing the notify channel.
+func (b *Backlog) Append(ops ...aof.Operation) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, op := range ops {
+		b.last++
+		if b.count < len(b.ops) {
+			b.ops[(b.start+b.count)%len(b.ops)] = op
+			b.count++
+		} else {
+			b.ops[b.start] = op
+			b.start = (b.start + 1) % len(b.ops)
+		}
+	}
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// Since returns up to max operations following offset. When there is nothing
+// new yet the returned channel is closed as soon as something is appended.
+func (b *Backlog) Since(offset uint64, max int) ([]Entry, <-chan struct{}, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	first := b.last - uint64(b.count) + 1
+	if offset > b.last || offset+1 < first {
+		return nil, nil, ErrResync
+	}
+	n := min(int(b.last-offset), max)
+	entries := make([]Entry, 0, n)
+	for i := 0; i < n; i++ {
+		pos := offset + 1 + uint64(i)
+		op := b.ops[(b.start+int(pos-first))%len(b.ops)]
+		entries = append(entries, Entry{Offset: pos, Op: &op})
+	}
+	return entries, b.notify, nil
+}
@@ -0,0 +1,272 @@
+package replication
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/aof"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+const (
+	// readTimeout drops a stream that has been silent for several heartbeats
+	readTimeout = 5 * Heartbeat
+	maxBackoff  = 30 * time.Second
+)
+
+// Follower keeps its store in sync with a leader. The store should be read
+// only, so the only writes it sees are the ones applied from the leader.
+type Follower struct {
+	leader string
+	store  *store.Store
+	client *http.Client
+
+	mu           sync.Mutex
+	id           string
+	offset       uint64
+	leaderOffset uint64
+	connected    bool
+	lastContact  time.Time
+	fullSyncs    int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type FollowerStatus struct {
+	Role         string    `json:"role"`
+	Leader       string    `json:"leader"`
+	ID           string    `json:"id"`
+	Connected    bool      `json:"connected"`
+	Offset       uint64    `json:"offset"`
+	LeaderOffset uint64    `json:"leaderOffset"`
+	Lag          uint64    `json:"lag"`
+	SinceContact float64   `json:"secondsSinceContact"`
+	LastContact  time.Time `json:"lastContact"`
+	FullSyncs    int       `json:"fullSyncs"`
+}
+
+// NewFollower replicates from the leader at leaderURL, the base address of its
+// HTTP server such as http://10.0.0.1:3000
+func NewFollower(leaderURL string, s *store.Store) *Follower {
+	return &Follower{
+		leader: strings.TrimSuffix(leaderURL, "/"),
+		store:  s,
+		client: &http.Client{},
+	}
+}
+
+// Start connects to the leader in the background and keeps reconnecting until Close
+func (f *Follower) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	f.cancel = cancel
+	f.done = make(chan struct{})
+	go f.run(ctx)
+}
+
+func (f *Follower) Close() {
+	if f.cancel == nil {
+		return
+	}
+	f.cancel()
+	<-f.done
+}
+
+func (f *Follower) run(ctx context.Context) {
+	defer close(f.done)
+
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := f.replicate(ctx)
+		f.mu.Lock()
+		if f.connected {
+			// the stream was up, so this is a fresh failure rather than a retry
+			backoff = time.Second
+		}
+		f.connected = false
+		f.mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, ErrResync) {
+			// the leader told us where we stand, no point in waiting
+			logger.Info("Leader requested a full resync")
+			continue
+		}
+		logger.Warn("Replication interrupted", "leader", f.leader, "error", err, "retry", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// replicate runs one connection to the leader: a full sync if we have nothing
+// to resume from, then the operation stream until it breaks
+func (f *Follower) replicate(ctx context.Context) error {
+	f.mu.Lock()
+	id, offset := f.id, f.offset
+	f.mu.Unlock()
+
+	if id == "" {
+		var err error
+		if id, offset, err = f.fullSync(ctx); err != nil {
+			return err
+		}
+	}
+	return f.stream(ctx, id, offset)
+}
+
+func (f *Follower) fullSync(ctx context.Context) (string, uint64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.leader+"/api/v0/replication/sync", nil)
+	if err != nil {
+		return "", 0, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("full sync failed: %s", resp.Status)
+	}
+
+	id := resp.Header.Get("X-Repl-Id")
+	offset, err := strconv.ParseUint(resp.Header.Get("X-Repl-Offset"), 10, 64)
+	if id == "" || err != nil {
+		return "", 0, errors.New("full sync response is missing its replication offset")
+	}
+	if err := f.store.Load(resp.Body); err != nil {
+		return "", 0, fmt.Errorf("failed to load snapshot from leader: %w", err)
+	}
+	// nothing was logged while loading, rewrite the AOF so a restart starts from this state
+	if f.store.AOFEnabled() {
+		if err := f.store.Snapshot(); err != nil {
+			logger.Error("Failed to persist snapshot from leader", "error", err)
+		}
+	}
+
+	f.mu.Lock()
+	f.id, f.offset, f.leaderOffset = id, offset, offset
+	f.lastContact = time.Now()
+	f.fullSyncs++
+	f.mu.Unlock()
+	logger.Info("Full sync completed", "leader", f.leader, "id", id, "offset", offset)
+	return id, offset, nil
+}
+
+func (f *Follower) stream(ctx context.Context, id string, offset uint64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	query := url.Values{"id": {id}, "offset": {strconv.FormatUint(offset, 10)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.leader+"/api/v0/replication/stream?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusConflict:
+		f.mu.Lock()
+		f.id = ""
+		f.mu.Unlock()
+		return ErrResync
+	default:
+		return fmt.Errorf("stream request failed: %s", resp.Status)
+	}
+
+	f.mu.Lock()
+	f.connected = true
+	f.mu.Unlock()
+	logger.Info("Streaming from leader", "leader", f.leader, "offset", offset)
+
+	// the leader sends a heartbeat every second, silence means the connection is gone
+	timer := time.AfterFunc(readTimeout, cancel)
+	defer timer.Stop()
+
+	// operations of a transaction are held back until its EXEC arrives
+	var pending []aof.Operation
+	r := bufio.NewReader(resp.Body)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF {
+				return errors.New("leader closed the stream")
+			}
+			return err
+		}
+		timer.Reset(readTimeout)
+
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("malformed stream entry: %w", err)
+		}
+
+		applied := false
+		switch {
+		case entry.Op == nil:
+		case entry.Op.Type == "MULTI":
+			pending = make([]aof.Operation, 0)
+		case entry.Op.Type == "EXEC":
+			if err := f.store.Apply(pending); err != nil {
+				logger.Error("Failed to log replicated transaction", "error", err)
+			}
+			pending = nil
+			applied = true
+		case pending != nil:
+			pending = append(pending, *entry.Op)
+		default:
+			if err := f.store.Apply([]aof.Operation{*entry.Op}); err != nil {
+				logger.Error("Failed to log replicated operation", "error", err)
+			}
+			applied = true
+		}
+
+		f.mu.Lock()
+		if applied {
+			f.offset = entry.Offset
+		}
+		f.leaderOffset = max(f.leaderOffset, entry.Offset)
+		f.lastContact = time.Now()
+		f.mu.Unlock()
+	}
+}
+
+func (f *Follower) Status() FollowerStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	status := FollowerStatus{
+		Role:         "follower",
+		Leader:       f.leader,
+		ID:           f.id,
+		Connected:    f.connected,
+		Offset:       f.offset,
+		LeaderOffset: f.leaderOffset,
+		Lag:          f.leaderOffset - f.offset,
+		LastContact:  f.lastContact,
+		FullSyncs:    f.fullSyncs,
+	}
+	if !f.lastContact.IsZero() {
+		status.SinceContact = time.Since(f.lastContact).Seconds()
+	}
+	return status
+}
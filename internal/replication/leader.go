@@ -0,0 +1,177 @@
+package replication
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/rdb"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+const (
+	// Heartbeat is how often an idle stream tells the follower the leader's offset
+	Heartbeat = time.Second
+	// streamBatch bounds the entries written between flushes
+	streamBatch = 512
+)
+
+// Leader serves full syncs and operation streams to followers
+type Leader struct {
+	store   *store.Store
+	backlog *Backlog
+
+	mu        sync.Mutex
+	followers map[*peer]struct{}
+}
+
+// peer is a follower currently attached to a stream
+type peer struct {
+	addr        string
+	offset      uint64
+	connectedAt time.Time
+}
+
+// FollowerInfo describes a connected follower as seen by the leader
+type FollowerInfo struct {
+	Addr        string    `json:"addr"`
+	Offset      uint64    `json:"offset"`
+	Lag         uint64    `json:"lag"`
+	ConnectedAt time.Time `json:"connectedAt"`
+}
+
+type LeaderStatus struct {
+	Role      string         `json:"role"`
+	ID        string         `json:"id"`
+	Offset    uint64         `json:"offset"`
+	Followers []FollowerInfo `json:"followers"`
+}
+
+// NewLeader starts recording the operations of s in a backlog of backlogSize entries
+func NewLeader(s *store.Store, backlogSize int) *Leader {
+	l := &Leader{
+		store:     s,
+		backlog:   NewBacklog(backlogSize),
+		followers: make(map[*peer]struct{}),
+	}
+	s.SetFeed(l.backlog)
+	return l
+}
+
+// Snapshot is a point-in-time copy of the keyspace and the offset it was taken at
+type Snapshot struct {
+	ID     string
+	Offset uint64
+
+	data    map[string]domain.Value
+	expires map[string]time.Time
+}
+
+// Snapshot copies the keyspace. The offset is read while the store is locked,
+// so the copy holds exactly the operations up to and including it.
+func (l *Leader) Snapshot() *Snapshot {
+	snap := &Snapshot{ID: l.backlog.ID()}
+	snap.data, snap.expires = l.store.Fork(func() {
+		snap.Offset = l.backlog.Offset()
+	})
+	return snap
+}
+
+// Encode writes the snapshot in the binary rdb format
+func (s *Snapshot) Encode(w io.Writer) error {
+	return rdb.Write(w, s.data, s.expires)
+}
+
+// CanResume reports whether a follower at offset of run id can stream without a full sync
+func (l *Leader) CanResume(id string, offset uint64) bool {
+	if id != l.backlog.ID() {
+		return false
+	}
+	_, _, err := l.backlog.Since(offset, 0)
+	return err == nil
+}
+
+// Stream writes every operation after offset to w as JSON lines, with a
+// heartbeat entry whenever the stream is idle, until writing fails or the
+// follower falls out of the backlog.
+func (l *Leader) Stream(w *bufio.Writer, addr string, offset uint64) error {
+	p := &peer{addr: addr, offset: offset, connectedAt: time.Now()}
+	l.mu.Lock()
+	l.followers[p] = struct{}{}
+	l.mu.Unlock()
+	logger.Info("Follower attached", "addr", addr, "offset", offset)
+
+	defer func() {
+		l.mu.Lock()
+		delete(l.followers, p)
+		l.mu.Unlock()
+		logger.Info("Follower detached", "addr", addr)
+	}()
+
+	heartbeat := time.NewTicker(Heartbeat)
+	defer heartbeat.Stop()
+	enc := json.NewEncoder(w)
+
+	// an immediate heartbeat gets the response headers out before the first write
+	if err := l.heartbeat(w, enc); err != nil {
+		return err
+	}
+	for {
+		entries, notify, err := l.backlog.Since(offset, streamBatch)
+		if err != nil {
+			logger.Warn("Follower fell behind the replication backlog", "addr", addr, "offset", offset)
+			return err
+		}
+		if len(entries) > 0 {
+			for _, entry := range entries {
+				if err := enc.Encode(entry); err != nil {
+					return err
+				}
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			offset = entries[len(entries)-1].Offset
+			l.mu.Lock()
+			p.offset = offset
+			l.mu.Unlock()
+			continue
+		}
+
+		select {
+		case <-notify:
+		case <-heartbeat.C:
+			if err := l.heartbeat(w, enc); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (l *Leader) heartbeat(w *bufio.Writer, enc *json.Encoder) error {
+	if err := enc.Encode(Entry{Offset: l.backlog.Offset()}); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (l *Leader) Status() LeaderStatus {
+	last := l.backlog.Offset()
+	status := LeaderStatus{Role: "leader", ID: l.backlog.ID(), Offset: last, Followers: []FollowerInfo{}}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for p := range l.followers {
+		status.Followers = append(status.Followers, FollowerInfo{
+			Addr:        p.addr,
+			Offset:      p.offset,
+			Lag:         last - p.offset,
+			ConnectedAt: p.connectedAt,
+		})
+	}
+	return status
+}
@@ -0,0 +1,321 @@
+package resp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/pubsub"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+// command describes a handler and its arity in redis terms: a positive
+// arity is exact, a negative one is the minimum number of arguments
+// (both counting the command name itself). Only commands that act on the
+// connection, the broker or the server live here; data commands are the
+// store's, see store.Run and dispatch.
+type command struct {
+	arity   int
+	handler func(c *conn, args []string)
+}
+
+func (s *Server) buildCommands() map[string]command {
+	return map[string]command{
+		// connection
+		"ping":    {-1, s.cmdPing},
+		"echo":    {2, s.cmdEcho},
+		"hello":   {-1, s.cmdHello},
+		"quit":    {-1, s.cmdQuit},
+		"select":  {2, s.cmdSelect},
+		"client":  {-2, s.cmdClient},
+		"command": {-1, s.cmdCommand},
+		"info":    {-1, s.cmdInfo},
+
+		// blocking pops park the connection, so they cannot run inside the store
+		"blpop":    {-3, s.cmdBLPop},
+		"brpop":    {-3, s.cmdBRPop},
+		"bdequeue": {-3, s.cmdBDequeue},
+		"bpop":     {-3, s.cmdBPop},
+
+		// pub/sub
+		"publish": {3, s.cmdPublish},
+		"pubsub":  {-2, s.cmdPubSub},
+
+		// transactions
+		"multi":   {1, s.cmdMulti},
+		"exec":    {1, s.cmdExec},
+		"discard": {1, s.cmdDiscard},
+		"watch":   {-2, s.cmdWatch},
+		"unwatch": {1, s.cmdUnwatch},
+
+		// persistence
+		"bgrewriteaof": {1, s.cmdRewrite},
+	}
+}
+
+// writeStoreError maps store errors onto the error codes redis clients expect
+func writeStoreError(c *conn, err error) {
+	switch {
+	case errors.Is(err, store.ErrWrongType):
+		c.w.WriteError("WRONGTYPE Operation against a key holding the wrong kind of value")
+	case errors.Is(err, store.ErrOutOfMemory), errors.Is(err, store.ErrMoved):
+		c.w.WriteError(err.Error())
+	case errors.Is(err, store.ErrReadOnly):
+		c.w.WriteError("READONLY You can't write against a read only replica.")
+	default:
+		c.w.WriteError("ERR " + err.Error())
+	}
+}
+
+func parseFloat(c *conn, s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		c.w.WriteError("ERR value is not a valid float")
+		return 0, false
+	}
+	return f, true
+}
+
+// -- Connection --
+
+func (s *Server) cmdPing(c *conn, args []string) {
+	if len(args) > 1 {
+		c.w.WriteBulk(args[1])
+		return
+	}
+	c.w.WriteSimple("PONG")
+}
+
+func (s *Server) cmdEcho(c *conn, args []string) {
+	c.w.WriteBulk(args[1])
+}
+
+// cmdHello negotiates the protocol version: HELLO [protover [AUTH user pass] [SETNAME name]]
+func (s *Server) cmdHello(c *conn, args []string) {
+	if len(args) > 1 {
+		proto, err := strconv.Atoi(args[1])
+		if err != nil || proto < 2 || proto > 3 {
+			c.w.WriteError("NOPROTO unsupported protocol version")
+			return
+		}
+		c.w.Proto = proto
+	}
+	for i := 2; i < len(args); i++ {
+		switch strings.ToLower(args[i]) {
+		case "auth":
+			// no authentication configured, accept any credentials like redis without requirepass
+			i += 2
+		case "setname":
+			if i+1 < len(args) {
+				c.name = args[i+1]
+				i++
+			}
+		}
+	}
+
+	c.w.WriteMap(7)
+	c.w.WriteBulk("server")
+	c.w.WriteBulk("mini_db")
+	c.w.WriteBulk("version")
+	c.w.WriteBulk("7.0.0")
+	c.w.WriteBulk("proto")
+	c.w.WriteInt(int64(c.w.Proto))
+	c.w.WriteBulk("id")
+	c.w.WriteInt(c.id)
+	c.w.WriteBulk("mode")
+	c.w.WriteBulk("standalone")
+	c.w.WriteBulk("role")
+	c.w.WriteBulk("master")
+	c.w.WriteBulk("modules")
+	c.w.WriteArray(0)
+}
+
+func (s *Server) cmdQuit(c *conn, args []string) {
+	c.quit = true
+	c.w.WriteOK()
+}
+
+func (s *Server) cmdSelect(c *conn, args []string) {
+	if args[1] != "0" {
+		c.w.WriteError("ERR DB index is out of range")
+		return
+	}
+	c.w.WriteOK()
+}
+
+func (s *Server) cmdClient(c *conn, args []string) {
+	switch strings.ToLower(args[1]) {
+	case "setname":
+		if len(args) != 3 {
+			c.w.WriteError("ERR wrong number of arguments for 'client|setname' command")
+			return
+		}
+		c.name = args[2]
+		c.w.WriteOK()
+	case "getname":
+		if c.name == "" {
+			c.w.WriteNull()
+			return
+		}
+		c.w.WriteBulk(c.name)
+	case "id":
+		c.w.WriteInt(c.id)
+	case "setinfo":
+		c.w.WriteOK()
+	default:
+		c.w.WriteError("ERR unknown subcommand '" + args[1] + "'")
+	}
+}
+
+// cmdCommand exists so redis-cli's startup introspection does not fail
+func (s *Server) cmdCommand(c *conn, args []string) {
+	if len(args) > 1 && strings.ToLower(args[1]) == "count" {
+		c.w.WriteInt(int64(len(s.commands) + store.CommandCount()))
+		return
+	}
+	c.w.WriteArray(0)
+}
+
+func (s *Server) cmdInfo(c *conn, args []string) {
+	stats := s.store.Stats()
+	var b strings.Builder
+	b.WriteString("# Server\r\nredis_version:7.0.0\r\nmini_db:1\r\n")
+	b.WriteString("# Memory\r\n")
+	fmt.Fprintf(&b, "used_memory:%d\r\nmaxmemory:%d\r\nmaxmemory_policy:%s\r\n", stats.UsedMemory, stats.MaxMemory, stats.MaxMemoryPolicy)
+	b.WriteString("# Stats\r\n")
+	fmt.Fprintf(&b, "evicted_keys:%d\r\nexpired_keys:%d\r\ndropped_events:%d\r\n", stats.EvictedKeys, stats.ExpiredKeys, stats.DroppedEvents)
+	b.WriteString("# Replication\r\n")
+	if s.store.ReadOnly() {
+		b.WriteString("role:slave\r\n")
+	} else {
+		b.WriteString("role:master\r\n")
+	}
+	b.WriteString("# Keyspace\r\n")
+	fmt.Fprintf(&b, "db0:keys=%d,expires=%d\r\n", stats.Keys, stats.Expires)
+	c.w.WriteBulk(b.String())
+}
+
+// -- Blocking pops --
+
+func (s *Server) cmdBLPop(c *conn, args []string) {
+	s.blockingPop(c, args, store.PopListLeft)
+}
+
+func (s *Server) cmdBRPop(c *conn, args []string) {
+	s.blockingPop(c, args, store.PopListRight)
+}
+
+func (s *Server) cmdBDequeue(c *conn, args []string) {
+	s.blockingPop(c, args, store.PopQueue)
+}
+
+func (s *Server) cmdBPop(c *conn, args []string) {
+	s.blockingPop(c, args, store.PopStack)
+}
+
+// blockingPop implements BLPOP key [key ...] timeout and its queue and stack
+// counterparts. The reply is [key, value], or a null array on timeout.
+func (s *Server) blockingPop(c *conn, args []string, kind store.PopKind) {
+	seconds, ok := parseFloat(c, args[len(args)-1])
+	if !ok {
+		return
+	}
+	if seconds < 0 {
+		c.w.WriteError("ERR timeout is negative")
+		return
+	}
+
+	// answer pipelined commands before parking, and stop waiting if the client goes away
+	c.w.Flush()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if c.r.Buffered() == 0 {
+		watched := make(chan struct{})
+		go func() {
+			defer close(watched)
+			var netErr net.Error
+			if err := c.r.Wait(); err != nil && !(errors.As(err, &netErr) && netErr.Timeout()) {
+				cancel()
+			}
+		}()
+		defer func() {
+			// wake the watcher with an expired deadline, then lift it again
+			c.netc.SetReadDeadline(time.Now())
+			<-watched
+			c.netc.SetReadDeadline(time.Time{})
+		}()
+	}
+
+	key, value, ok, err := s.store.BlockingPop(ctx, kind, args[1:len(args)-1], time.Duration(seconds*float64(time.Second)))
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		writeStoreError(c, err)
+		return
+	}
+	if !ok {
+		c.w.WriteNullArray()
+		return
+	}
+	c.w.WriteStrings([]string{key, value})
+}
+
+// writeOptionalStrings replies with an array holding a null for every nil value
+func writeOptionalStrings(c *conn, values []*string) {
+	c.w.WriteArray(len(values))
+	for _, v := range values {
+		if v == nil {
+			c.w.WriteNull()
+			continue
+		}
+		c.w.WriteBulk(*v)
+	}
+}
+
+// -- Pub/Sub --
+
+func (s *Server) cmdPublish(c *conn, args []string) {
+	if pubsub.Reserved(args[1]) {
+		c.w.WriteError("ERR " + pubsub.ErrReservedChannel.Error())
+		return
+	}
+	c.w.WriteInt(int64(s.broker.Publish(args[1], args[2])))
+}
+
+// cmdPubSub serves PUBSUB CHANNELS [pattern], NUMSUB [channel ...] and NUMPAT
+func (s *Server) cmdPubSub(c *conn, args []string) {
+	switch strings.ToLower(args[1]) {
+	case "channels":
+		pattern := ""
+		if len(args) > 2 {
+			pattern = args[2]
+		}
+		c.w.WriteStrings(s.broker.Channels(pattern))
+	case "numsub":
+		c.w.WriteArray(2 * (len(args) - 2))
+		for _, channel := range args[2:] {
+			c.w.WriteBulk(channel)
+			c.w.WriteInt(int64(s.broker.NumSub(channel)))
+		}
+	case "numpat":
+		c.w.WriteInt(int64(s.broker.NumPat()))
+	default:
+		c.w.WriteError("ERR unknown subcommand '" + args[1] + "'")
+	}
+}
+
+// -- Persistence --
+
+func (s *Server) cmdRewrite(c *conn, args []string) {
+	if err := s.store.BackgroundRewrite(); err != nil {
+		c.w.WriteError("ERR " + err.Error())
+		return
+	}
+	c.w.WriteSimple("Background append only file rewriting started")
+}
@@ -0,0 +1,118 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// limits match redis' defaults for a single request
+	maxBulkLen  = 512 * 1024 * 1024
+	maxArrayLen = 1024 * 1024
+	maxInline   = 64 * 1024
+)
+
+var errProtocol = errors.New("protocol error")
+
+// Reader parses client requests: RESP arrays of bulk strings, or inline
+// commands (space separated text lines) as typed into telnet or redis-cli.
+type Reader struct {
+	rd *bufio.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{rd: bufio.NewReader(r)}
+}
+
+// Buffered reports whether more pipelined input is already available,
+// so the caller can hold back flushing replies until the batch is done.
+func (r *Reader) Buffered() int {
+	return r.rd.Buffered()
+}
+
+// Wait blocks until more input arrives or the connection fails, without consuming anything
+func (r *Reader) Wait() error {
+	_, err := r.rd.Peek(1)
+	return err
+}
+
+// ReadCommand returns the next command as its argument list. Empty inline lines yield an empty slice.
+func (r *Reader) ReadCommand() ([]string, error) {
+	prefix, err := r.rd.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if prefix[0] != '*' {
+		return r.readInline()
+	}
+
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n > maxArrayLen {
+		return nil, fmt.Errorf("%w: invalid multibulk length", errProtocol)
+	}
+	if n <= 0 {
+		return []string{}, nil
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		arg, err := r.readBulk()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+func (r *Reader) readBulk() (string, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return "", err
+	}
+	if len(line) == 0 || line[0] != '$' {
+		return "", fmt.Errorf("%w: expected '$', got '%s'", errProtocol, line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 || n > maxBulkLen {
+		return "", fmt.Errorf("%w: invalid bulk length", errProtocol)
+	}
+
+	buf := make([]byte, n+2)
+	if _, err := io.ReadFull(r.rd, buf); err != nil {
+		return "", err
+	}
+	if buf[n] != '\r' || buf[n+1] != '\n' {
+		return "", fmt.Errorf("%w: bulk string not terminated by CRLF", errProtocol)
+	}
+	return string(buf[:n]), nil
+}
+
+func (r *Reader) readInline() ([]string, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) > maxInline {
+		return nil, fmt.Errorf("%w: too big inline request", errProtocol)
+	}
+	return strings.Fields(line), nil
+}
+
+// readLine reads up to CRLF (or a bare LF, which inline clients often send)
+func (r *Reader) readLine() (string, error) {
+	line, err := r.rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
@@ -0,0 +1,169 @@
+package resp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/pubsub"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+// Server speaks the redis wire protocol and dispatches commands to the store,
+// so redis-cli and regular redis client libraries can talk to mini_db.
+type Server struct {
+	store    *store.Store
+	broker   *pubsub.Broker
+	commands map[string]command
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[*conn]struct{}
+	nextID   atomic.Int64
+	closed   bool
+}
+
+// conn is the per-client state
+type conn struct {
+	id     int64
+	netc   net.Conn
+	r      *Reader
+	w      *Writer
+	name   string
+	server *Server
+	quit   bool
+	tx     txState
+}
+
+func NewServer(s *store.Store, broker *pubsub.Broker) *Server {
+	srv := &Server{
+		store:  s,
+		broker: broker,
+		conns:  make(map[*conn]struct{}),
+	}
+	srv.commands = srv.buildCommands()
+	return srv
+}
+
+// ListenAndServe accepts connections on addr until Close is called
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.listener = ln
+	s.mu.Unlock()
+
+	logger.Info("RESP listener started", "addr", addr)
+	for {
+		netc, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			logger.Warn("RESP accept failed", "error", err)
+			continue
+		}
+		go s.serve(netc)
+	}
+}
+
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for c := range s.conns {
+		c.netc.Close()
+	}
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+func (s *Server) serve(netc net.Conn) {
+	c := &conn{
+		id:     s.nextID.Add(1),
+		netc:   netc,
+		r:      NewReader(netc),
+		w:      NewWriter(netc),
+		server: s,
+	}
+
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		netc.Close()
+		logger.Debug("RESP client disconnected", "id", c.id, "addr", netc.RemoteAddr())
+	}()
+
+	logger.Debug("RESP client connected", "id", c.id, "addr", netc.RemoteAddr())
+	for !c.quit {
+		args, err := c.r.ReadCommand()
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				c.w.WriteError("ERR " + err.Error())
+				c.w.Flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				logger.Warn("RESP read failed", "id", c.id, "error", err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		s.dispatch(c, args)
+
+		// pipelining: only flush once every already received command is answered
+		if c.r.Buffered() == 0 {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+	c.w.Flush()
+}
+
+// dispatch runs the server's own commands directly and hands everything else
+// to the store's command table, queueing it instead while MULTI is active
+func (s *Server) dispatch(c *conn, args []string) {
+	name := strings.ToLower(args[0])
+	cmd, ok := s.commands[name]
+	if !ok {
+		if c.tx.multi {
+			s.queueCommand(c, args)
+			return
+		}
+		value, err := s.store.Run(store.Command{Name: args[0], Args: args[1:]})
+		writeResult(c, value, err)
+		return
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		if c.tx.multi {
+			c.tx.dirty = true
+		}
+		c.w.WriteError("ERR wrong number of arguments for '" + name + "' command")
+		return
+	}
+	if c.tx.multi && !isTxControl(name) {
+		s.queueCommand(c, args)
+		return
+	}
+	cmd.handler(c, args)
+}
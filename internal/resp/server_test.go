@@ -0,0 +1,91 @@
+package resp
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/pubsub"
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+// client talks to a server over an in-memory connection using inline commands
+type client struct {
+	t    *testing.T
+	netc net.Conn
+	r    *bufio.Reader
+}
+
+func newClient(t *testing.T) *client {
+	srv := NewServer(store.NewStore(), pubsub.NewBroker(16))
+	server, netc := net.Pipe()
+	go srv.serve(server)
+	t.Cleanup(func() { netc.Close() })
+	return &client{t: t, netc: netc, r: bufio.NewReader(netc)}
+}
+
+// do sends line and checks the raw reply
+func (c *client) do(line, want string) {
+	c.t.Helper()
+	c.netc.SetDeadline(time.Now().Add(time.Second))
+	if _, err := io.WriteString(c.netc, line+"\r\n"); err != nil {
+		c.t.Fatalf("%s: write: %v", line, err)
+	}
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(c.r, got); err != nil {
+		c.t.Fatalf("%s: read: %v (got %q)", line, err, got)
+	}
+	if string(got) != want {
+		c.t.Fatalf("%s = %q, want %q", line, got, want)
+	}
+}
+
+// Commands reply the same whether they run directly or inside MULTI, since
+// both go through the store's command table
+func TestCommandsReplyTheSameInsideMulti(t *testing.T) {
+	cases := []struct {
+		line, want string
+	}{
+		{"SET k v EX 0", "-ERR invalid expire time in 'set' command\r\n"},
+		{"LSET missing 0 v", "-ERR no such key\r\n"},
+		{"ZADD z 1 a 2 b", ":2\r\n"},
+		{"ZRANGE z 0 -1 WITHSCORES", "*4\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$1\r\n2\r\n"},
+		{"PQPOP missing", "*-1\r\n"},
+		{"SCAN 0 MATCH z", "*2\r\n$1\r\n0\r\n*1\r\n$1\r\nz\r\n"},
+		{"TYPE z", "+sortedset\r\n"},
+	}
+
+	direct := newClient(t)
+	for _, tc := range cases {
+		direct.do(tc.line, tc.want)
+	}
+
+	queued := newClient(t)
+	queued.do("MULTI", "+OK\r\n")
+	for _, tc := range cases {
+		queued.do(tc.line, "+QUEUED\r\n")
+	}
+	want := "*7\r\n"
+	for _, tc := range cases {
+		want += tc.want
+	}
+	queued.do("EXEC", want)
+}
+
+func TestUnknownCommandFailsTheTransaction(t *testing.T) {
+	c := newClient(t)
+	c.do("NOSUCH k", "-ERR unknown command 'NOSUCH'\r\n")
+	c.do("MULTI", "+OK\r\n")
+	c.do("NOSUCH k", "-ERR unknown command 'NOSUCH'\r\n")
+	c.do("GET", "-ERR wrong number of arguments for 'get' command\r\n")
+	c.do("EXEC", "-EXECABORT Transaction discarded because of previous errors.\r\n")
+}
+
+func TestPublishToKeyspaceChannelsIsRejected(t *testing.T) {
+	c := newClient(t)
+	c.do("PUBLISH __keyspace__:k forged", "-ERR "+pubsub.ErrReservedChannel.Error()+"\r\n")
+	c.do("PUBLISH __keyevent__:del k", "-ERR "+pubsub.ErrReservedChannel.Error()+"\r\n")
+	c.do("PUBLISH news hello", ":0\r\n")
+}
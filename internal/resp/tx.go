@@ -0,0 +1,161 @@
+package resp
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/mrpurushotam/mini_db/internal/store"
+)
+
+// txState is the per-connection MULTI/WATCH state
+type txState struct {
+	multi   bool
+	dirty   bool // a command failed to queue, EXEC must abort
+	queued  []store.Command
+	watched map[string]uint64
+}
+
+func (t *txState) reset() {
+	t.multi = false
+	t.dirty = false
+	t.queued = nil
+	t.watched = nil
+}
+
+// commands that run immediately even while a transaction is open
+var txControl = map[string]bool{
+	"multi":   true,
+	"exec":    true,
+	"discard": true,
+	"watch":   true,
+	"unwatch": true,
+	"quit":    true,
+}
+
+// queueCommand is called instead of the handler while MULTI is active
+func (s *Server) queueCommand(c *conn, args []string) {
+	cmd := store.Command{Name: args[0], Args: args[1:]}
+	if err := store.ValidateCommand(cmd); err != nil {
+		c.tx.dirty = true
+		c.w.WriteError("ERR " + err.Error())
+		return
+	}
+	c.tx.queued = append(c.tx.queued, cmd)
+	c.w.WriteSimple("QUEUED")
+}
+
+func (s *Server) cmdMulti(c *conn, args []string) {
+	if c.tx.multi {
+		c.w.WriteError("ERR MULTI calls can not be nested")
+		return
+	}
+	c.tx.multi = true
+	c.w.WriteOK()
+}
+
+func (s *Server) cmdDiscard(c *conn, args []string) {
+	if !c.tx.multi {
+		c.w.WriteError("ERR DISCARD without MULTI")
+		return
+	}
+	c.tx.reset()
+	c.w.WriteOK()
+}
+
+func (s *Server) cmdWatch(c *conn, args []string) {
+	if c.tx.multi {
+		c.w.WriteError("ERR WATCH inside MULTI is not allowed")
+		return
+	}
+	if c.tx.watched == nil {
+		c.tx.watched = make(map[string]uint64)
+	}
+	for _, key := range args[1:] {
+		// keep the first observed version if a key is watched twice
+		if _, ok := c.tx.watched[key]; !ok {
+			c.tx.watched[key] = s.store.Version(key)
+		}
+	}
+	c.w.WriteOK()
+}
+
+func (s *Server) cmdUnwatch(c *conn, args []string) {
+	c.tx.watched = nil
+	c.w.WriteOK()
+}
+
+func (s *Server) cmdExec(c *conn, args []string) {
+	if !c.tx.multi {
+		c.w.WriteError("ERR EXEC without MULTI")
+		return
+	}
+	defer c.tx.reset()
+
+	if c.tx.dirty {
+		c.w.WriteError("EXECABORT Transaction discarded because of previous errors.")
+		return
+	}
+
+	results, err := s.store.Exec(c.tx.watched, c.tx.queued)
+	if errors.Is(err, store.ErrTxAborted) {
+		c.w.WriteNullArray()
+		return
+	}
+	if err != nil && results == nil {
+		writeStoreError(c, err)
+		return
+	}
+
+	c.w.WriteArray(len(results))
+	for _, r := range results {
+		writeResult(c, r.Value, r.Err)
+	}
+}
+
+// writeResult encodes a generic store result
+func writeResult(c *conn, value any, err error) {
+	if err != nil {
+		writeStoreError(c, err)
+		return
+	}
+	switch v := value.(type) {
+	case nil:
+		c.w.WriteNull()
+	case store.Status:
+		c.w.WriteSimple(string(v))
+	case string:
+		c.w.WriteBulk(v)
+	case int64:
+		c.w.WriteInt(v)
+	case int:
+		c.w.WriteInt(int64(v))
+	case float64:
+		c.w.WriteDouble(v)
+	case []string:
+		c.w.WriteStrings(v)
+	case []int64:
+		c.w.WriteArray(len(v))
+		for _, n := range v {
+			c.w.WriteInt(n)
+		}
+	case []*string:
+		writeOptionalStrings(c, v)
+	case map[string]string:
+		c.w.WriteStringMap(v)
+	case []any:
+		if v == nil {
+			c.w.WriteNullArray()
+			return
+		}
+		c.w.WriteArray(len(v))
+		for _, item := range v {
+			writeResult(c, item, nil)
+		}
+	default:
+		c.w.WriteError("ERR unsupported reply type")
+	}
+}
+
+func isTxControl(name string) bool {
+	return txControl[strings.ToLower(name)]
+}
@@ -0,0 +1,126 @@
+package resp
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+)
+
+// Writer encodes replies. Protocol 2 is the default; after HELLO 3 the
+// writer emits RESP3 types (null, map, double, push) instead of their
+// RESP2 approximations.
+type Writer struct {
+	wr    *bufio.Writer
+	Proto int
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{wr: bufio.NewWriter(w), Proto: 2}
+}
+
+func (w *Writer) Flush() error {
+	return w.wr.Flush()
+}
+
+func (w *Writer) WriteSimple(s string) {
+	w.wr.WriteByte('+')
+	w.wr.WriteString(s)
+	w.wr.WriteString("\r\n")
+}
+
+func (w *Writer) WriteOK() {
+	w.WriteSimple("OK")
+}
+
+// WriteError writes msg as an error reply; msg should start with an error code such as ERR or WRONGTYPE
+func (w *Writer) WriteError(msg string) {
+	w.wr.WriteByte('-')
+	w.wr.WriteString(msg)
+	w.wr.WriteString("\r\n")
+}
+
+func (w *Writer) WriteInt(n int64) {
+	w.wr.WriteByte(':')
+	w.wr.WriteString(strconv.FormatInt(n, 10))
+	w.wr.WriteString("\r\n")
+}
+
+func (w *Writer) WriteBulk(s string) {
+	w.wr.WriteByte('$')
+	w.wr.WriteString(strconv.Itoa(len(s)))
+	w.wr.WriteString("\r\n")
+	w.wr.WriteString(s)
+	w.wr.WriteString("\r\n")
+}
+
+func (w *Writer) WriteNull() {
+	if w.Proto >= 3 {
+		w.wr.WriteString("_\r\n")
+		return
+	}
+	w.wr.WriteString("$-1\r\n")
+}
+
+// WriteNullArray is the RESP2 nil multi-bulk, used e.g. by an aborted EXEC or a timed out BLPOP
+func (w *Writer) WriteNullArray() {
+	if w.Proto >= 3 {
+		w.wr.WriteString("_\r\n")
+		return
+	}
+	w.wr.WriteString("*-1\r\n")
+}
+
+func (w *Writer) WriteArray(n int) {
+	w.wr.WriteByte('*')
+	w.wr.WriteString(strconv.Itoa(n))
+	w.wr.WriteString("\r\n")
+}
+
+// WriteMap writes a map header with n pairs; RESP2 clients get a flat array of 2n elements
+func (w *Writer) WriteMap(n int) {
+	if w.Proto >= 3 {
+		w.wr.WriteByte('%')
+		w.wr.WriteString(strconv.Itoa(n))
+		w.wr.WriteString("\r\n")
+		return
+	}
+	w.WriteArray(n * 2)
+}
+
+// WritePush writes an out-of-band push header (pub/sub messages); RESP2 clients get a plain array
+func (w *Writer) WritePush(n int) {
+	if w.Proto >= 3 {
+		w.wr.WriteByte('>')
+		w.wr.WriteString(strconv.Itoa(n))
+		w.wr.WriteString("\r\n")
+		return
+	}
+	w.WriteArray(n)
+}
+
+// WriteDouble writes a float; RESP2 clients get it as a bulk string
+func (w *Writer) WriteDouble(f float64) {
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if w.Proto >= 3 {
+		w.wr.WriteByte(',')
+		w.wr.WriteString(s)
+		w.wr.WriteString("\r\n")
+		return
+	}
+	w.WriteBulk(s)
+}
+
+func (w *Writer) WriteStrings(items []string) {
+	w.WriteArray(len(items))
+	for _, item := range items {
+		w.WriteBulk(item)
+	}
+}
+
+func (w *Writer) WriteStringMap(m map[string]string) {
+	w.WriteMap(len(m))
+	for k, v := range m {
+		w.WriteBulk(k)
+		w.WriteBulk(v)
+	}
+}
@@ -30,6 +30,74 @@ func Register(router fiber.Router, h *handler.Handler) {
 		return h.GetAllValues(c)
 	})
 
+	router.Post("/INCR", func(c *fiber.Ctx) error {
+		return h.Incr(c)
+	})
+
+	router.Post("/DECR", func(c *fiber.Ctx) error {
+		return h.Decr(c)
+	})
+
+	router.Post("/INCRBY", func(c *fiber.Ctx) error {
+		return h.IncrBy(c)
+	})
+
+	router.Post("/DECRBY", func(c *fiber.Ctx) error {
+		return h.DecrBy(c)
+	})
+
+	router.Post("/INCRBYFLOAT", func(c *fiber.Ctx) error {
+		return h.IncrByFloat(c)
+	})
+
+	router.Post("/APPEND", func(c *fiber.Ctx) error {
+		return h.Append(c)
+	})
+
+	router.Get("/STRLEN", func(c *fiber.Ctx) error {
+		return h.StrLen(c)
+	})
+
+	router.Get("/GETRANGE", func(c *fiber.Ctx) error {
+		return h.GetRange(c)
+	})
+
+	router.Post("/SETRANGE", func(c *fiber.Ctx) error {
+		return h.SetRange(c)
+	})
+
+	router.Post("/SETBIT", func(c *fiber.Ctx) error {
+		return h.SetBit(c)
+	})
+
+	router.Get("/GETBIT", func(c *fiber.Ctx) error {
+		return h.GetBit(c)
+	})
+
+	router.Get("/BITCOUNT", func(c *fiber.Ctx) error {
+		return h.BitCount(c)
+	})
+
+	router.Get("/BITPOS", func(c *fiber.Ctx) error {
+		return h.BitPos(c)
+	})
+
+	router.Post("/BITOP", func(c *fiber.Ctx) error {
+		return h.BitOp(c)
+	})
+
+	router.Get("/SCAN", func(c *fiber.Ctx) error {
+		return h.Scan(c)
+	})
+
+	router.Get("/SSCAN", func(c *fiber.Ctx) error {
+		return h.SScan(c)
+	})
+
+	router.Get("/HSCAN", func(c *fiber.Ctx) error {
+		return h.HScan(c)
+	})
+
 	router.Post("/SADD", func(c *fiber.Ctx) error {
 		return h.SAdd(c)
 	})
@@ -42,6 +110,50 @@ func Register(router fiber.Router, h *handler.Handler) {
 		return h.SPop(c)
 	})
 
+	router.Get("/SINTER", func(c *fiber.Ctx) error {
+		return h.SInter(c)
+	})
+
+	router.Get("/SUNION", func(c *fiber.Ctx) error {
+		return h.SUnion(c)
+	})
+
+	router.Get("/SDIFF", func(c *fiber.Ctx) error {
+		return h.SDiff(c)
+	})
+
+	router.Post("/SINTERSTORE", func(c *fiber.Ctx) error {
+		return h.SInterStore(c)
+	})
+
+	router.Post("/SUNIONSTORE", func(c *fiber.Ctx) error {
+		return h.SUnionStore(c)
+	})
+
+	router.Post("/SDIFFSTORE", func(c *fiber.Ctx) error {
+		return h.SDiffStore(c)
+	})
+
+	router.Get("/SISMEMBER", func(c *fiber.Ctx) error {
+		return h.SIsMember(c)
+	})
+
+	router.Get("/SMISMEMBER", func(c *fiber.Ctx) error {
+		return h.SMIsMember(c)
+	})
+
+	router.Get("/SCARD", func(c *fiber.Ctx) error {
+		return h.SCard(c)
+	})
+
+	router.Get("/SRANDMEMBER", func(c *fiber.Ctx) error {
+		return h.SRandMember(c)
+	})
+
+	router.Post("/SMOVE", func(c *fiber.Ctx) error {
+		return h.SMove(c)
+	})
+
 	router.Post("/LPUSH", func(c *fiber.Ctx) error {
 		return h.LPush(c)
 	})
@@ -54,6 +166,42 @@ func Register(router fiber.Router, h *handler.Handler) {
 		return h.LRange(c)
 	})
 
+	router.Patch("/LPOP", func(c *fiber.Ctx) error {
+		return h.LPop(c)
+	})
+
+	router.Patch("/RPOP", func(c *fiber.Ctx) error {
+		return h.RPop(c)
+	})
+
+	router.Get("/LLEN", func(c *fiber.Ctx) error {
+		return h.LLen(c)
+	})
+
+	router.Get("/LINDEX", func(c *fiber.Ctx) error {
+		return h.LIndex(c)
+	})
+
+	router.Post("/LSET", func(c *fiber.Ctx) error {
+		return h.LSet(c)
+	})
+
+	router.Post("/LINSERT", func(c *fiber.Ctx) error {
+		return h.LInsert(c)
+	})
+
+	router.Patch("/LREM", func(c *fiber.Ctx) error {
+		return h.LRem(c)
+	})
+
+	router.Post("/LTRIM", func(c *fiber.Ctx) error {
+		return h.LTrim(c)
+	})
+
+	router.Post("/LMOVE", func(c *fiber.Ctx) error {
+		return h.LMove(c)
+	})
+
 	router.Post("/ENQUEUE", func(c *fiber.Ctx) error {
 		return h.Enqueue(c)
 	})
@@ -62,6 +210,18 @@ func Register(router fiber.Router, h *handler.Handler) {
 		return h.Dequeue(c)
 	})
 
+	router.Patch("/RDEQUEUE", func(c *fiber.Ctx) error {
+		return h.RDequeue(c)
+	})
+
+	router.Post("/ACK", func(c *fiber.Ctx) error {
+		return h.Ack(c)
+	})
+
+	router.Post("/NACK", func(c *fiber.Ctx) error {
+		return h.Nack(c)
+	})
+
 	router.Post("/PUSH", func(c *fiber.Ctx) error {
 		return h.Push(c)
 	})
@@ -70,6 +230,22 @@ func Register(router fiber.Router, h *handler.Handler) {
 		return h.Pop(c)
 	})
 
+	router.Post("/BLPOP", func(c *fiber.Ctx) error {
+		return h.BLPop(c)
+	})
+
+	router.Post("/BRPOP", func(c *fiber.Ctx) error {
+		return h.BRPop(c)
+	})
+
+	router.Post("/BDEQUEUE", func(c *fiber.Ctx) error {
+		return h.BDequeue(c)
+	})
+
+	router.Post("/BPOP", func(c *fiber.Ctx) error {
+		return h.BPop(c)
+	})
+
 	router.Post("/HSET", func(c *fiber.Ctx) error {
 		return h.HSet(c)
 	})
@@ -82,10 +258,198 @@ func Register(router fiber.Router, h *handler.Handler) {
 		return h.HGetAll(c)
 	})
 
+	router.Post("/HMSET", func(c *fiber.Ctx) error {
+		return h.HMSet(c)
+	})
+
+	router.Post("/HSETNX", func(c *fiber.Ctx) error {
+		return h.HSetNX(c)
+	})
+
+	router.Patch("/HDEL", func(c *fiber.Ctx) error {
+		return h.HDel(c)
+	})
+
+	router.Get("/HEXISTS", func(c *fiber.Ctx) error {
+		return h.HExists(c)
+	})
+
+	router.Get("/HLEN", func(c *fiber.Ctx) error {
+		return h.HLen(c)
+	})
+
+	router.Get("/HKEYS", func(c *fiber.Ctx) error {
+		return h.HKeys(c)
+	})
+
+	router.Get("/HVALS", func(c *fiber.Ctx) error {
+		return h.HVals(c)
+	})
+
+	router.Get("/HMGET", func(c *fiber.Ctx) error {
+		return h.HMGet(c)
+	})
+
+	router.Post("/HEXPIRE", func(c *fiber.Ctx) error {
+		return h.HExpire(c)
+	})
+
+	router.Get("/HTTL", func(c *fiber.Ctx) error {
+		return h.HTTL(c)
+	})
+
+	router.Patch("/HPERSIST", func(c *fiber.Ctx) error {
+		return h.HPersist(c)
+	})
+
+	router.Post("/HINCRBY", func(c *fiber.Ctx) error {
+		return h.HIncrBy(c)
+	})
+
+	router.Post("/HINCRBYFLOAT", func(c *fiber.Ctx) error {
+		return h.HIncrByFloat(c)
+	})
+
+	router.Post("/ZADD", func(c *fiber.Ctx) error {
+		return h.ZAdd(c)
+	})
+
+	router.Post("/ZINCRBY", func(c *fiber.Ctx) error {
+		return h.ZIncrBy(c)
+	})
+
+	router.Patch("/ZREM", func(c *fiber.Ctx) error {
+		return h.ZRem(c)
+	})
+
+	router.Get("/ZSCORE", func(c *fiber.Ctx) error {
+		return h.ZScore(c)
+	})
+
+	router.Get("/ZCARD", func(c *fiber.Ctx) error {
+		return h.ZCard(c)
+	})
+
+	router.Get("/ZRANK", func(c *fiber.Ctx) error {
+		return h.ZRank(c)
+	})
+
+	router.Get("/ZRANGE", func(c *fiber.Ctx) error {
+		return h.ZRange(c)
+	})
+
+	router.Get("/ZRANGEBYSCORE", func(c *fiber.Ctx) error {
+		return h.ZRangeByScore(c)
+	})
+
+	router.Post("/PQPUSH", func(c *fiber.Ctx) error {
+		return h.PQPush(c)
+	})
+
+	router.Patch("/PQPOP", func(c *fiber.Ctx) error {
+		return h.PQPop(c)
+	})
+
+	router.Get("/PQPEEK", func(c *fiber.Ctx) error {
+		return h.PQPeek(c)
+	})
+
+	router.Get("/PQLEN", func(c *fiber.Ctx) error {
+		return h.PQLen(c)
+	})
+
+	router.Post("/PUBLISH", func(c *fiber.Ctx) error {
+		return h.Publish(c)
+	})
+
+	router.Get("/SUBSCRIBE", func(c *fiber.Ctx) error {
+		return h.Subscribe(c)
+	})
+
+	router.Get("/ws/pubsub", func(c *fiber.Ctx) error {
+		return h.PubSubSocket(c)
+	})
+
+	router.Get("/events", func(c *fiber.Ctx) error {
+		return h.Events(c)
+	})
+
+	router.Post("/EXPIRE", func(c *fiber.Ctx) error {
+		return h.Expire(c)
+	})
+
+	router.Get("/TTL", func(c *fiber.Ctx) error {
+		return h.TTL(c)
+	})
+
+	router.Patch("/PERSIST", func(c *fiber.Ctx) error {
+		return h.Persist(c)
+	})
+
+	router.Post("/tx", func(c *fiber.Ctx) error {
+		return h.Tx(c)
+	})
+
+	router.Get("/version", func(c *fiber.Ctx) error {
+		return h.Version(c)
+	})
+
+	router.Get("/stats", func(c *fiber.Ctx) error {
+		return h.Stats(c)
+	})
+
 	router.Get("/snapshot", func(c *fiber.Ctx) error {
 		return h.Snapshot(c)
 	})
 
+	router.Get("/replication", func(c *fiber.Ctx) error {
+		return h.ReplicationInfo(c)
+	})
+
+	router.Get("/replication/sync", func(c *fiber.Ctx) error {
+		return h.ReplicationSync(c)
+	})
+
+	router.Get("/replication/stream", func(c *fiber.Ctx) error {
+		return h.ReplicationStream(c)
+	})
+
+	router.Get("/cluster", func(c *fiber.Ctx) error {
+		return h.Cluster(c)
+	})
+
+	router.Post("/raft/vote", func(c *fiber.Ctx) error {
+		return h.RaftVote(c)
+	})
+
+	router.Post("/raft/append", func(c *fiber.Ctx) error {
+		return h.RaftAppend(c)
+	})
+
+	router.Post("/raft/snapshot", func(c *fiber.Ctx) error {
+		return h.RaftSnapshot(c)
+	})
+
+	router.Get("/DUMP", func(c *fiber.Ctx) error {
+		return h.Dump(c)
+	})
+
+	router.Post("/RESTORE", func(c *fiber.Ctx) error {
+		return h.Restore(c)
+	})
+
+	router.Get("/slots/keys", func(c *fiber.Ctx) error {
+		return h.SlotKeys(c)
+	})
+
+	router.Post("/slots/release", func(c *fiber.Ctx) error {
+		return h.ReleaseSlots(c)
+	})
+
+	router.Post("/slots/claim", func(c *fiber.Ctx) error {
+		return h.ClaimSlots(c)
+	})
+
 	router.Get("/", func(c *fiber.Ctx) error {
 		return c.JSON(map[string]string{"message": "Api is running"})
 	})
@@ -0,0 +1,239 @@
+// Package slots maps keys onto the fixed hash slots a sharded deployment splits
+// its keyspace into, and keeps the table of which node owns each slot. Keys hash
+// the same way as in redis cluster, including {hash tags}.
+package slots
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Count is the number of hash slots
+const Count = 16384
+
+// ForKey returns the slot of key. When the key contains a non-empty {tag} only
+// the tag is hashed, which lets related keys be placed in the same slot.
+func ForKey(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % Count
+}
+
+// crc16 is CRC-16/XMODEM, the checksum redis cluster hashes keys with
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// Range is an inclusive range of slots
+type Range struct {
+	Start int
+	End   int
+}
+
+func (r Range) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// ParseRanges reads a list of slots and slot ranges such as "0-5460,8000"
+func ParseRanges(s string) ([]Range, error) {
+	var ranges []Range
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slot %q", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(hi); err != nil {
+				return nil, fmt.Errorf("invalid slot range %q", part)
+			}
+		}
+		if start < 0 || end >= Count || start > end {
+			return nil, fmt.Errorf("slot range %q is outside 0-%d", part, Count-1)
+		}
+		ranges = append(ranges, Range{Start: start, End: end})
+	}
+	if len(ranges) == 0 {
+		return nil, errors.New("no slots given")
+	}
+	return ranges, nil
+}
+
+// FormatRanges is the inverse of ParseRanges, collapsing runs of consecutive slots
+func FormatRanges(slots []int) string {
+	sorted := append([]int(nil), slots...)
+	sort.Ints(sorted)
+	parts := make([]string, 0)
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] <= sorted[j]+1 {
+			j++
+		}
+		parts = append(parts, Range{Start: sorted[i], End: sorted[j]}.String())
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// Expand lists every slot covered by ranges
+func Expand(ranges []Range) []int {
+	seen := make(map[int]bool)
+	out := make([]int, 0)
+	for _, r := range ranges {
+		for slot := r.Start; slot <= r.End; slot++ {
+			if !seen[slot] {
+				seen[slot] = true
+				out = append(out, slot)
+			}
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// Table records the owner of every slot. When it has a path, every change is
+// saved there so the assignment survives restarts.
+type Table struct {
+	mu     sync.RWMutex
+	owners [Count]string
+	path   string
+}
+
+// NewTable splits the slots evenly over nodes in order of their ids
+func NewTable(nodes []string) *Table {
+	ids := append([]string(nil), nodes...)
+	sort.Strings(ids)
+	t := &Table{}
+	for slot := 0; slot < Count; slot++ {
+		t.owners[slot] = ids[slot*len(ids)/Count]
+	}
+	return t
+}
+
+// LoadTable reads the table saved at path. If there is none, the slots are split
+// evenly over nodes and the result is saved. Every slot must be owned by one of nodes.
+func LoadTable(path string, nodes []string) (*Table, error) {
+	known := make(map[string]bool, len(nodes))
+	for _, id := range nodes {
+		known[id] = true
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t := NewTable(nodes)
+		t.path = path
+		return t, t.save()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// saved as node id -> slot ranges, which keeps the file readable
+	var saved map[string]string
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse slot table %s: %w", path, err)
+	}
+	t := &Table{path: path}
+	for id, spec := range saved {
+		if !known[id] {
+			return nil, fmt.Errorf("slot table %s assigns slots to unknown node %s", path, id)
+		}
+		ranges, err := ParseRanges(spec)
+		if err != nil {
+			return nil, fmt.Errorf("slot table %s: %w", path, err)
+		}
+		for _, slot := range Expand(ranges) {
+			t.owners[slot] = id
+		}
+	}
+	for slot, owner := range t.owners {
+		if owner == "" {
+			return nil, fmt.Errorf("slot table %s leaves slot %d unassigned", path, slot)
+		}
+	}
+	return t, nil
+}
+
+// Owner returns the node that serves slot
+func (t *Table) Owner(slot int) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.owners[slot]
+}
+
+// Assign moves slots to node and saves the table
+func (t *Table) Assign(node string, slots ...int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, slot := range slots {
+		t.owners[slot] = node
+	}
+	return t.save()
+}
+
+// Ranges returns the slots of every node as range lists, e.g. "0-8191"
+func (t *Table) Ranges() map[string]string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.ranges()
+}
+
+func (t *Table) ranges() map[string]string {
+	byNode := make(map[string][]int)
+	for slot, owner := range t.owners {
+		byNode[owner] = append(byNode[owner], slot)
+	}
+	out := make(map[string]string, len(byNode))
+	for id, owned := range byNode {
+		out[id] = FormatRanges(owned)
+	}
+	return out
+}
+
+// save writes the table to its path. Requires t.mu.
+func (t *Table) save() error {
+	if t.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(t.ranges(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("failed to save slot table: %w", err)
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save slot table: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+// PopKind selects the end a blocking pop takes from and the type of key it serves
+type PopKind int
+
+const (
+	PopListLeft  PopKind = iota // BLPOP
+	PopListRight                // BRPOP
+	PopQueue                    // blocking DEQUEUE
+	PopStack                    // blocking POP
+)
+
+func (k PopKind) dataType() domain.DataType {
+	switch k {
+	case PopQueue:
+		return domain.Queue
+	case PopStack:
+		return domain.Stack
+	}
+	return domain.List
+}
+
+// waiter is a client parked in BlockingPop. It is queued on every key it waits
+// for and handed an element by the first push to any of them.
+type waiter struct {
+	kind PopKind
+	keys []string
+	// ch receives exactly one result; done is set under the store lock once one is
+	// taken for it, which commit may send after unlocking
+	ch   chan popResult
+	done bool
+}
+
+type popResult struct {
+	key   string
+	value string
+	err   error
+	// the AOF record of the pop, which the waiter waits on like commit would
+	aofSeq uint64
+}
+
+// delivery is a pop made for a waiter, sent to it by commit once the write is committed
+type delivery struct {
+	w   *waiter
+	res popResult
+}
+
+// BlockingPop pops an element from the first of keys that has one. If all are
+// empty or missing, the caller is parked until an element is pushed to any of
+// them, timeout elapses (0 waits forever) or ctx is done. Waiters are served in
+// the order they arrived, per key, and a pushed element goes straight to the
+// waiter, so a client that did not wait cannot take it first. ok is false when
+// nothing was popped before the timeout. Keys whose slot was handed to another
+// node fail with ErrMoved, see ReleaseSlots.
+func (s *Store) BlockingPop(ctx context.Context, kind PopKind, keys []string, timeout time.Duration) (key, value string, ok bool, err error) {
+	s.mu.Lock()
+	if s.readOnly {
+		s.mu.Unlock()
+		return "", "", false, ErrReadOnly
+	}
+	if s.movedAway(keys) {
+		s.mu.Unlock()
+		return "", "", false, ErrMoved
+	}
+	for _, key := range keys {
+		value, popped, err := s.tryPop(kind, key)
+		if err != nil || popped {
+			return key, value, popped, s.commit(err)
+		}
+	}
+
+	w := &waiter{kind: kind, keys: keys, ch: make(chan popResult, 1)}
+	for _, key := range keys {
+		s.blocked[key] = append(s.blocked[key], w)
+	}
+	// tryPop may have expired keys, whose DELETEs are not this caller's to wait for
+	s.commit(nil)
+	logger.Debug("Client blocked", "keys", keys, "timeout", timeout)
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case res := <-w.ch:
+		return s.delivered(res)
+	case <-expired:
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	if w.done {
+		// served between the timeout firing and taking the lock
+		s.mu.Unlock()
+		return s.delivered(<-w.ch)
+	}
+	s.unblock(w)
+	s.mu.Unlock()
+	return "", "", false, ctx.Err()
+}
+
+// Unpop puts value back at the end of key a pop of kind took it from, for a
+// client that went away before the element reached it
+func (s *Store) Unpop(kind PopKind, key, value string) error {
+	s.mu.Lock()
+	return s.commit(s.unpop(kind, key, value))
+}
+
+func (s *Store) unpop(kind PopKind, key, value string) error {
+	var err error
+	switch kind {
+	case PopListLeft:
+		_, err = s.lPush(key, value)
+	case PopListRight:
+		_, err = s.rPush(key, value)
+	case PopQueue:
+		err = s.requeue(key, value)
+	case PopStack:
+		err = s.push(key, value)
+	}
+	return err
+}
+
+// delivered waits until the pop made on behalf of a waiter is durable, as commit
+// does for the client's own writes. In cluster mode commit has already waited
+// for the log entry before handing the pop over.
+func (s *Store) delivered(res popResult) (string, string, bool, error) {
+	err := res.err
+	if res.aofSeq > 0 {
+		if syncErr := s.aof.Durable(res.aofSeq); syncErr != nil && err == nil {
+			err = syncErr
+		}
+	}
+	return res.key, res.value, true, err
+}
+
+// tryPop pops from key if it holds an element of the kind's type. popped is
+// false for a missing or empty key, and for a key of another type, which is
+// reported as ErrWrongType. Requires the write lock.
+func (s *Store) tryPop(kind PopKind, key string) (value string, popped bool, err error) {
+	s.expireIfNeeded(key)
+	s.own(key)
+	val, exists := s.data[key]
+	if !exists {
+		return "", false, nil
+	}
+	if val.Type() != kind.dataType() {
+		return "", false, fmt.Errorf("%w: expected %s, got %s", ErrWrongType, kind.dataType(), val.Type())
+	}
+
+	var length int
+	switch v := val.(type) {
+	case *DataTypeValue.ListValue:
+		length = len(v.Data)
+	case *DataTypeValue.QueueValue:
+		length = len(v.Data)
+	case *DataTypeValue.StackValue:
+		length = len(v.Data)
+	}
+	if length == 0 {
+		return "", false, nil
+	}
+
+	switch kind {
+	case PopListLeft:
+		value, err = s.lPop(key)
+	case PopListRight:
+		value, err = s.rPop(key)
+	case PopQueue:
+		value, err = s.dequeue(key)
+	case PopStack:
+		value, err = s.pop(key)
+	}
+	return value, true, err
+}
+
+// signalReady marks key as having received elements, so commit hands them to
+// the clients blocked on it. Requires the write lock.
+func (s *Store) signalReady(key string) {
+	if len(s.blocked[key]) > 0 {
+		s.ready = append(s.ready, key)
+	}
+}
+
+// serveBlocked pops the elements of ready keys for their waiters, oldest first,
+// and returns the pops for commit to send once they are logged. A waiter
+// expecting another type than the key holds is skipped and keeps waiting.
+// Requires the write lock; called by commit before unlocking.
+func (s *Store) serveBlocked() []delivery {
+	var served []delivery
+	for len(s.ready) > 0 {
+		key := s.ready[0]
+		s.ready = s.ready[1:]
+
+		for i := 0; i < len(s.blocked[key]); {
+			w := s.blocked[key][i]
+			value, popped, err := s.tryPop(w.kind, key)
+			if !popped {
+				if err == nil {
+					// nothing left in the key
+					break
+				}
+				i++
+				continue
+			}
+			s.unblock(w)
+			w.done = true
+			served = append(served, delivery{w: w, res: popResult{key: key, value: value, err: err}})
+		}
+	}
+	s.ready = nil
+	return served
+}
+
+// unblock removes w from the queues of all its keys. Requires the write lock.
+func (s *Store) unblock(w *waiter) {
+	for _, key := range w.keys {
+		queue := s.blocked[key]
+		kept := queue[:0]
+		for _, other := range queue {
+			if other != w {
+				kept = append(kept, other)
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.blocked, key)
+		} else {
+			s.blocked[key] = kept
+		}
+	}
+}
+
+// failBlocked wakes every waiter with err. Requires the write lock.
+func (s *Store) failBlocked(err error) {
+	for _, queue := range s.blocked {
+		for _, w := range queue {
+			if !w.done {
+				w.done = true
+				w.ch <- popResult{err: err}
+			}
+		}
+	}
+	s.blocked = make(map[string][]*waiter)
+	s.ready = nil
+}
@@ -0,0 +1,1316 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/glob"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+var (
+	ErrUnknownCommand = errors.New("unknown command")
+	ErrSyntax         = errors.New("syntax error")
+	ErrNotInteger     = errors.New("value is not an integer or out of range")
+	ErrInvalidExpire  = errors.New("invalid expire time in 'set' command")
+	ErrNoSuchKey      = errors.New("no such key")
+	ErrInvalidCursor  = errors.New("invalid cursor")
+)
+
+// Command is one store command using redis command names and argument order
+type Command struct {
+	Name string   `json:"cmd"`
+	Args []string `json:"args"`
+}
+
+// Status is a simple status reply such as OK, as opposed to a string value read from the store
+type Status string
+
+const OK Status = "OK"
+
+// Result is the outcome of one command; a failing command does not abort the rest of the transaction
+type Result struct {
+	Value any
+	Err   error
+}
+
+type command struct {
+	// number of arguments after the command name; negative means at least -arity
+	arity int
+	// readOnly commands run under the read lock when they are not part of a transaction
+	readOnly bool
+	run      func(s *Store, args []string) (any, error)
+}
+
+const (
+	reads  = true
+	writes = false
+)
+
+// commands is the one table of store commands: the RESP server runs them
+// through Run, and MULTI and the HTTP transaction endpoint queue them for Exec.
+// Replies are plain values (nil, Status, string, int64, float64, []string,
+// []int64, []*string, map[string]string) or []any of those; a nil []any is a
+// null array.
+var commands = map[string]command{
+	// keyspace
+	"del":     {-1, writes, cmdDel},
+	"exists":  {-1, reads, cmdExists},
+	"type":    {1, reads, cmdType},
+	"keys":    {1, reads, cmdKeys},
+	"scan":    {-1, reads, cmdScan},
+	"dbsize":  {0, reads, cmdDBSize},
+	"expire":  {2, writes, cmdExpire},
+	"pexpire": {2, writes, cmdPExpire},
+	"ttl":     {1, reads, cmdTTL},
+	"pttl":    {1, reads, cmdPTTL},
+	"persist": {1, writes, cmdPersist},
+
+	// strings
+	"get":         {1, reads, cmdGet},
+	"set":         {-2, writes, cmdSet},
+	"incr":        {1, writes, cmdIncr},
+	"decr":        {1, writes, cmdDecr},
+	"incrby":      {2, writes, cmdIncrBy},
+	"decrby":      {2, writes, cmdDecrBy},
+	"incrbyfloat": {2, writes, cmdIncrByFloat},
+	"append":      {2, writes, cmdAppend},
+	"strlen":      {1, reads, cmdStrLen},
+	"getrange":    {3, reads, cmdGetRange},
+	"setrange":    {3, writes, cmdSetRange},
+	"setbit":      {3, writes, cmdSetBit},
+	"getbit":      {2, reads, cmdGetBit},
+	"bitcount":    {-1, reads, cmdBitCount},
+	"bitpos":      {-2, reads, cmdBitPos},
+	"bitop":       {-3, writes, cmdBitOp},
+
+	// sets
+	"sadd":        {-2, writes, cmdSAdd},
+	"srem":        {-2, writes, cmdSRem},
+	"smembers":    {1, reads, cmdSMembers},
+	"sscan":       {-2, reads, cmdSScan},
+	"sismember":   {2, reads, cmdSIsMember},
+	"smismember":  {-2, reads, cmdSMIsMember},
+	"scard":       {1, reads, cmdSCard},
+	"srandmember": {-1, reads, cmdSRandMember},
+	"smove":       {3, writes, cmdSMove},
+	"sinter":      {-1, reads, cmdSInter},
+	"sunion":      {-1, reads, cmdSUnion},
+	"sdiff":       {-1, reads, cmdSDiff},
+	"sinterstore": {-2, writes, cmdSInterStore},
+	"sunionstore": {-2, writes, cmdSUnionStore},
+	"sdiffstore":  {-2, writes, cmdSDiffStore},
+
+	// lists
+	"lpush":   {-2, writes, cmdLPush},
+	"rpush":   {-2, writes, cmdRPush},
+	"lrange":  {3, reads, cmdLRange},
+	"lpop":    {1, writes, cmdLPop},
+	"rpop":    {1, writes, cmdRPop},
+	"llen":    {1, reads, cmdLLen},
+	"lindex":  {2, reads, cmdLIndex},
+	"lset":    {3, writes, cmdLSet},
+	"linsert": {4, writes, cmdLInsert},
+	"lrem":    {3, writes, cmdLRem},
+	"ltrim":   {3, writes, cmdLTrim},
+	"lmove":   {4, writes, cmdLMove},
+
+	// queues and stacks (mini_db specific)
+	"enqueue":  {-2, writes, cmdEnqueue},
+	"dequeue":  {1, writes, cmdDequeue},
+	"rdequeue": {-2, writes, cmdRDequeue},
+	"ack":      {2, writes, cmdAck},
+	"nack":     {2, writes, cmdNack},
+	"push":     {2, writes, cmdPush},
+	"pop":      {1, writes, cmdPop},
+
+	// hashmaps
+	"hset":         {-3, writes, cmdHSet},
+	"hget":         {2, reads, cmdHGet},
+	"hgetall":      {1, reads, cmdHGetAll},
+	"hmset":        {-3, writes, cmdHMSet},
+	"hsetnx":       {3, writes, cmdHSetNX},
+	"hdel":         {-2, writes, cmdHDel},
+	"hexists":      {2, reads, cmdHExists},
+	"hlen":         {1, reads, cmdHLen},
+	"hkeys":        {1, reads, cmdHKeys},
+	"hvals":        {1, reads, cmdHVals},
+	"hmget":        {-2, reads, cmdHMGet},
+	"hexpire":      {-5, writes, cmdHExpire},
+	"httl":         {-4, reads, cmdHTTL},
+	"hpersist":     {-4, writes, cmdHPersist},
+	"hincrby":      {3, writes, cmdHIncrBy},
+	"hincrbyfloat": {3, writes, cmdHIncrByFloat},
+	"hscan":        {-2, reads, cmdHScan},
+
+	// sorted sets
+	"zadd":             {-3, writes, cmdZAdd},
+	"zincrby":          {3, writes, cmdZIncrBy},
+	"zrem":             {-2, writes, cmdZRem},
+	"zscore":           {2, reads, cmdZScore},
+	"zcard":            {1, reads, cmdZCard},
+	"zrank":            {2, reads, cmdZRank},
+	"zrevrank":         {2, reads, cmdZRevRank},
+	"zrange":           {-3, reads, cmdZRange},
+	"zrevrange":        {-3, reads, cmdZRevRange},
+	"zrangebyscore":    {-3, reads, cmdZRangeByScore},
+	"zrevrangebyscore": {-3, reads, cmdZRevRangeByScore},
+
+	// priority queues (mini_db specific)
+	"pqpush": {3, writes, cmdPQPush},
+	"pqpop":  {1, writes, cmdPQPop},
+	"pqpeek": {1, reads, cmdPQPeek},
+	"pqlen":  {1, reads, cmdPQLen},
+}
+
+// ValidateCommand checks that cmd is known and has a valid number of arguments,
+// so bad commands can be rejected when queued rather than at EXEC time
+func ValidateCommand(cmd Command) error {
+	c, ok := commands[strings.ToLower(cmd.Name)]
+	if !ok {
+		return fmt.Errorf("%w '%s'", ErrUnknownCommand, cmd.Name)
+	}
+	if (c.arity >= 0 && len(cmd.Args) != c.arity) || (c.arity < 0 && len(cmd.Args) < -c.arity) {
+		return fmt.Errorf("wrong number of arguments for '%s' command", strings.ToLower(cmd.Name))
+	}
+	return nil
+}
+
+// CommandCount returns the number of commands Run knows
+func CommandCount() int {
+	return len(commands)
+}
+
+// Run executes a single command on its own, as if it were a transaction of one
+func (s *Store) Run(cmd Command) (any, error) {
+	if err := ValidateCommand(cmd); err != nil {
+		return nil, err
+	}
+	c := commands[strings.ToLower(cmd.Name)]
+	if c.readOnly {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return c.run(s, cmd.Args)
+	}
+	s.mu.Lock()
+	value, err := c.run(s, cmd.Args)
+	return value, s.commit(err)
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func cmdGet(s *Store, args []string) (any, error) {
+	if t, ok := s.typeOf(args[0]); ok && t != domain.String {
+		return nil, fmt.Errorf("%w: expected string, got %s", ErrWrongType, t)
+	}
+	value, ok := s.get(args[0])
+	if !ok {
+		return nil, nil
+	}
+	return value, nil
+}
+
+// cmdSet handles SET key value [EX seconds | PX milliseconds]
+func cmdSet(s *Store, args []string) (any, error) {
+	var ttl time.Duration
+	opts := args[2:]
+	for i := 0; i < len(opts); i += 2 {
+		opt := strings.ToLower(opts[i])
+		if (opt != "ex" && opt != "px") || i+1 >= len(opts) {
+			return nil, ErrSyntax
+		}
+		n, err := strconv.ParseInt(opts[i+1], 10, 64)
+		if err != nil {
+			return nil, ErrNotInteger
+		}
+		if n <= 0 {
+			return nil, ErrInvalidExpire
+		}
+		if opt == "ex" {
+			ttl = time.Duration(n) * time.Second
+		} else {
+			ttl = time.Duration(n) * time.Millisecond
+		}
+	}
+	if err := s.setWithTTL(args[0], args[1], ttl); err != nil {
+		return nil, err
+	}
+	return OK, nil
+}
+
+func cmdDel(s *Store, args []string) (any, error) {
+	var deleted int64
+	for _, key := range args {
+		ok, err := s.deleteKey(key)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += boolInt(ok)
+	}
+	return deleted, nil
+}
+
+func cmdExists(s *Store, args []string) (any, error) {
+	var count int64
+	for _, key := range args {
+		_, ok := s.typeOf(key)
+		count += boolInt(ok)
+	}
+	return count, nil
+}
+
+func cmdType(s *Store, args []string) (any, error) {
+	t, ok := s.typeOf(args[0])
+	if !ok {
+		return Status("none"), nil
+	}
+	return Status(t), nil
+}
+
+func cmdKeys(s *Store, args []string) (any, error) {
+	now := time.Now()
+	keys := make([]string, 0)
+	for key := range s.data {
+		if !s.isExpired(key, now) && glob.Match(args[0], key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// cmdScan handles SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+func cmdScan(s *Store, args []string) (any, error) {
+	cursor, opts, err := parseScanArgs(args[0], args[1:], true)
+	if err != nil {
+		return nil, err
+	}
+	keys, next := s.scanKeys(cursor, opts)
+	return []any{strconv.FormatUint(next, 10), keys}, nil
+}
+
+// parseScanArgs reads the cursor and the MATCH, COUNT and (for SCAN only) TYPE options
+func parseScanArgs(cursorArg string, args []string, allowType bool) (uint64, ScanOptions, error) {
+	var opts ScanOptions
+	cursor, err := strconv.ParseUint(cursorArg, 10, 64)
+	if err != nil {
+		return 0, opts, ErrInvalidCursor
+	}
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return 0, opts, ErrSyntax
+		}
+		switch strings.ToLower(args[i]) {
+		case "match":
+			opts.Match = args[i+1]
+		case "count":
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return 0, opts, ErrNotInteger
+			}
+			if n < 1 {
+				return 0, opts, ErrSyntax
+			}
+			opts.Count = int(n)
+		case "type":
+			if !allowType {
+				return 0, opts, ErrSyntax
+			}
+			opts.Type = domain.DataType(strings.ToLower(args[i+1]))
+		default:
+			return 0, opts, ErrSyntax
+		}
+	}
+	return cursor, opts, nil
+}
+
+func cmdDBSize(s *Store, args []string) (any, error) {
+	return int64(len(s.data)), nil
+}
+
+func cmdExpire(s *Store, args []string) (any, error) {
+	return expireBy(s, args, time.Second)
+}
+
+func cmdPExpire(s *Store, args []string) (any, error) {
+	return expireBy(s, args, time.Millisecond)
+}
+
+func expireBy(s *Store, args []string, unit time.Duration) (any, error) {
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	ok, err := s.expire(args[0], time.Duration(n)*unit)
+	return boolInt(ok), err
+}
+
+func cmdPersist(s *Store, args []string) (any, error) {
+	ok, err := s.persist(args[0])
+	return boolInt(ok), err
+}
+
+func cmdTTL(s *Store, args []string) (any, error) {
+	remaining, ok := ttlReply(s, args[0])
+	if !ok {
+		return remaining, nil
+	}
+	return (remaining + 999) / 1000, nil
+}
+
+func cmdPTTL(s *Store, args []string) (any, error) {
+	remaining, _ := ttlReply(s, args[0])
+	return remaining, nil
+}
+
+// ttlReply returns the milliseconds key has left, or false and -2 for a
+// missing key and -1 for a key without expiry
+func ttlReply(s *Store, key string) (int64, bool) {
+	remaining, hasExpiry, exists := s.ttl(key)
+	switch {
+	case !exists:
+		return -2, false
+	case !hasExpiry:
+		return -1, false
+	}
+	return remaining.Milliseconds(), true
+}
+
+func cmdSAdd(s *Store, args []string) (any, error) {
+	added, err := s.sAdd(args[0], args[1:]...)
+	return int64(added), err
+}
+
+func cmdSRem(s *Store, args []string) (any, error) {
+	removed, err := s.sPop(args[0], args[1:]...)
+	if errors.Is(err, ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	return int64(removed), err
+}
+
+func cmdSMembers(s *Store, args []string) (any, error) {
+	members, err := s.sMembers(args[0])
+	if errors.Is(err, ErrKeyNotFound) {
+		return []string{}, nil
+	}
+	return members, err
+}
+
+func cmdSIsMember(s *Store, args []string) (any, error) {
+	found, err := s.sMIsMember(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return boolInt(found[0]), nil
+}
+
+// cmdSScan handles SSCAN key cursor [MATCH pattern] [COUNT count]
+func cmdSScan(s *Store, args []string) (any, error) {
+	cursor, opts, err := parseScanArgs(args[1], args[2:], false)
+	if err != nil {
+		return nil, err
+	}
+	members, next, err := s.sScan(args[0], cursor, opts)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+	if members == nil {
+		members = []string{}
+	}
+	return []any{strconv.FormatUint(next, 10), members}, nil
+}
+
+func cmdSMIsMember(s *Store, args []string) (any, error) {
+	found, err := s.sMIsMember(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	ints := make([]int64, len(found))
+	for i, f := range found {
+		ints[i] = boolInt(f)
+	}
+	return ints, nil
+}
+
+func cmdSCard(s *Store, args []string) (any, error) {
+	count, err := s.sCard(args[0])
+	if errors.Is(err, ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	return int64(count), err
+}
+
+// cmdSRandMember handles SRANDMEMBER key [count]
+func cmdSRandMember(s *Store, args []string) (any, error) {
+	if len(args) > 2 {
+		return nil, ErrSyntax
+	}
+	count := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return nil, ErrNotInteger
+		}
+		count = n
+	}
+	members, err := s.sRandMember(args[0], count)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+	if len(args) == 2 {
+		return append([]string{}, members...), nil
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+	return members[0], nil
+}
+
+func cmdSMove(s *Store, args []string) (any, error) {
+	moved, err := s.sMove(args[0], args[1], args[2])
+	if errors.Is(err, ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	return boolInt(moved), err
+}
+
+func cmdSInter(s *Store, args []string) (any, error) {
+	return cmdSetAlgebra(s, SetInter, args)
+}
+
+func cmdSUnion(s *Store, args []string) (any, error) {
+	return cmdSetAlgebra(s, SetUnion, args)
+}
+
+func cmdSDiff(s *Store, args []string) (any, error) {
+	return cmdSetAlgebra(s, SetDiff, args)
+}
+
+func cmdSetAlgebra(s *Store, op SetOp, keys []string) (any, error) {
+	result, err := s.combineSets(op, keys)
+	if err != nil {
+		return nil, err
+	}
+	return setMembers(result), nil
+}
+
+func cmdSInterStore(s *Store, args []string) (any, error) {
+	count, err := s.setStore(SetInter, args[0], args[1:])
+	return int64(count), err
+}
+
+func cmdSUnionStore(s *Store, args []string) (any, error) {
+	count, err := s.setStore(SetUnion, args[0], args[1:])
+	return int64(count), err
+}
+
+func cmdSDiffStore(s *Store, args []string) (any, error) {
+	count, err := s.setStore(SetDiff, args[0], args[1:])
+	return int64(count), err
+}
+
+func cmdLPush(s *Store, args []string) (any, error) {
+	// redis pushes each element to the head in turn, so the last argument ends up first
+	values := make([]string, 0, len(args)-1)
+	for i := len(args) - 1; i >= 1; i-- {
+		values = append(values, args[i])
+	}
+	n, err := s.lPush(args[0], values...)
+	if err != nil {
+		return nil, err
+	}
+	return int64(n), nil
+}
+
+func cmdRPush(s *Store, args []string) (any, error) {
+	n, err := s.rPush(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return int64(n), nil
+}
+
+func cmdLRange(s *Store, args []string) (any, error) {
+	start, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	stop, err := strconv.Atoi(args[2])
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	items, err := s.lRange(args[0], start, stop)
+	if errors.Is(err, ErrKeyNotFound) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// copy, the result outlives the lock
+	return append([]string(nil), items...), nil
+}
+
+func cmdIncr(s *Store, args []string) (any, error) {
+	return s.incrBy(args[0], 1)
+}
+
+func cmdDecr(s *Store, args []string) (any, error) {
+	return s.incrBy(args[0], -1)
+}
+
+func cmdIncrBy(s *Store, args []string) (any, error) {
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	return s.incrBy(args[0], delta)
+}
+
+func cmdDecrBy(s *Store, args []string) (any, error) {
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	if delta == math.MinInt64 {
+		return nil, ErrOverflow
+	}
+	return s.incrBy(args[0], -delta)
+}
+
+// cmdIncrByFloat replies with the new value as a string, like redis
+func cmdIncrByFloat(s *Store, args []string) (any, error) {
+	delta, err := parseFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.incrByFloat(args[0], delta)
+	if err != nil {
+		return nil, err
+	}
+	return formatCounterFloat(f), nil
+}
+
+func cmdAppend(s *Store, args []string) (any, error) {
+	n, err := s.appendString(args[0], args[1])
+	return int64(n), err
+}
+
+func cmdStrLen(s *Store, args []string) (any, error) {
+	n, err := s.strLen(args[0])
+	return int64(n), err
+}
+
+func cmdGetRange(s *Store, args []string) (any, error) {
+	start, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	end, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	value, err := s.getRange(args[0], start, end)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func cmdSetRange(s *Store, args []string) (any, error) {
+	offset, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	if offset < 0 || offset > math.MaxInt32 {
+		return nil, ErrOffset
+	}
+	n, err := s.setRangeString(args[0], int(offset), args[2])
+	return int64(n), err
+}
+
+func cmdSetBit(s *Store, args []string) (any, error) {
+	offset, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, ErrBitOffset
+	}
+	bit, err := strconv.Atoi(args[2])
+	if err != nil {
+		return nil, ErrBitValue
+	}
+	old, err := s.setBit(args[0], offset, bit)
+	return int64(old), err
+}
+
+func cmdGetBit(s *Store, args []string) (any, error) {
+	offset, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, ErrBitOffset
+	}
+	bit, err := s.getBit(args[0], offset)
+	return int64(bit), err
+}
+
+// cmdBitCount handles BITCOUNT key [start end [BYTE|BIT]]
+func cmdBitCount(s *Store, args []string) (any, error) {
+	r, err := ParseBitRange(args[1:], true)
+	if err != nil {
+		return nil, err
+	}
+	return s.bitCount(args[0], r)
+}
+
+// cmdBitPos handles BITPOS key bit [start [end [BYTE|BIT]]]
+func cmdBitPos(s *Store, args []string) (any, error) {
+	bit, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, ErrBitValue
+	}
+	r, err := ParseBitRange(args[2:], false)
+	if err != nil {
+		return nil, err
+	}
+	return s.bitPos(args[0], bit, r)
+}
+
+// cmdBitOp handles BITOP AND|OR|XOR|NOT destination key [key ...]
+func cmdBitOp(s *Store, args []string) (any, error) {
+	op, ok := ParseBitOp(args[0])
+	if !ok {
+		return nil, ErrSyntax
+	}
+	n, err := s.bitOpStore(op, args[1], args[2:])
+	return int64(n), err
+}
+
+func cmdLPop(s *Store, args []string) (any, error) {
+	value, err := s.lPop(args[0])
+	return poppedResult(value, err)
+}
+
+func cmdRPop(s *Store, args []string) (any, error) {
+	value, err := s.rPop(args[0])
+	return poppedResult(value, err)
+}
+
+func cmdLLen(s *Store, args []string) (any, error) {
+	length, err := s.lLen(args[0])
+	if errors.Is(err, ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	return int64(length), err
+}
+
+func cmdLIndex(s *Store, args []string) (any, error) {
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	value, err := s.lIndex(args[0], index)
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrIndexOutOfRange) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func cmdLSet(s *Store, args []string) (any, error) {
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	if err := s.lSet(args[0], index, args[2]); err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			return nil, ErrNoSuchKey
+		}
+		return nil, err
+	}
+	return OK, nil
+}
+
+// cmdLInsert handles LINSERT key BEFORE|AFTER pivot element
+func cmdLInsert(s *Store, args []string) (any, error) {
+	where := strings.ToLower(args[1])
+	if where != "before" && where != "after" {
+		return nil, ErrSyntax
+	}
+	length, err := s.lInsert(args[0], args[2], args[3], where == "before")
+	if errors.Is(err, ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	return int64(length), err
+}
+
+func cmdLRem(s *Store, args []string) (any, error) {
+	count, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	removed, err := s.lRem(args[0], count, args[2])
+	if errors.Is(err, ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	return int64(removed), err
+}
+
+func cmdLTrim(s *Store, args []string) (any, error) {
+	start, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	stop, err := strconv.Atoi(args[2])
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	if err := s.lTrim(args[0], start, stop); err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+	return OK, nil
+}
+
+// cmdLMove handles LMOVE source destination LEFT|RIGHT LEFT|RIGHT
+func cmdLMove(s *Store, args []string) (any, error) {
+	from, to := strings.ToLower(args[2]), strings.ToLower(args[3])
+	if (from != "left" && from != "right") || (to != "left" && to != "right") {
+		return nil, ErrSyntax
+	}
+	value, err := s.lMove(args[0], args[1], from == "left", to == "left")
+	return poppedResult(value, err)
+}
+
+// cmdEnqueue handles ENQUEUE key value [DELAY milliseconds | AT unix-time-milliseconds]
+func cmdEnqueue(s *Store, args []string) (any, error) {
+	var at time.Time
+	opts := args[2:]
+	for i := 0; i < len(opts); i += 2 {
+		opt := strings.ToLower(opts[i])
+		if (opt != "delay" && opt != "at") || i+1 >= len(opts) || !at.IsZero() {
+			return nil, ErrSyntax
+		}
+		n, err := strconv.ParseInt(opts[i+1], 10, 64)
+		if err != nil {
+			return nil, ErrNotInteger
+		}
+		if opt == "delay" {
+			if n < 0 {
+				return nil, errors.New("delay is negative")
+			}
+			at = time.Now().Add(time.Duration(n) * time.Millisecond)
+		} else {
+			at = time.UnixMilli(n)
+		}
+	}
+
+	var err error
+	if at.IsZero() {
+		err = s.enqueue(args[0], args[1])
+	} else {
+		err = s.enqueueAt(args[0], args[1], at)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return OK, nil
+}
+
+func cmdDequeue(s *Store, args []string) (any, error) {
+	value, err := s.dequeue(args[0])
+	return poppedResult(value, err)
+}
+
+// cmdRDequeue handles RDEQUEUE key visibility-seconds [MAXATTEMPTS n] [DEADLETTER key],
+// replying [id, value, attempts] or a null array for an empty queue
+func cmdRDequeue(s *Store, args []string) (any, error) {
+	seconds, err := parseFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if seconds <= 0 {
+		return nil, errors.New("visibility timeout must be positive")
+	}
+	opts := LeaseOptions{Visibility: time.Duration(seconds * float64(time.Second))}
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return nil, ErrSyntax
+		}
+		switch strings.ToLower(args[i]) {
+		case "maxattempts":
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return nil, ErrNotInteger
+			}
+			if n <= 0 {
+				return nil, errors.New("MAXATTEMPTS must be positive")
+			}
+			opts.MaxAttempts = int(n)
+		case "deadletter":
+			opts.DeadLetter = args[i+1]
+		default:
+			return nil, ErrSyntax
+		}
+	}
+
+	lease, err := s.lease(args[0], opts)
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrEmpty) {
+		return []any(nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []any{lease.ID, lease.Value, int64(lease.Attempts)}, nil
+}
+
+func cmdAck(s *Store, args []string) (any, error) {
+	ok, err := s.ack(args[0], args[1])
+	if errors.Is(err, ErrLeaseNotFound) {
+		return int64(0), nil
+	}
+	return boolInt(ok), err
+}
+
+func cmdNack(s *Store, args []string) (any, error) {
+	ok, err := s.nack(args[0], args[1])
+	if errors.Is(err, ErrLeaseNotFound) {
+		return int64(0), nil
+	}
+	return boolInt(ok), err
+}
+
+func cmdPush(s *Store, args []string) (any, error) {
+	if err := s.push(args[0], args[1]); err != nil {
+		return nil, err
+	}
+	return OK, nil
+}
+
+func cmdPop(s *Store, args []string) (any, error) {
+	value, err := s.pop(args[0])
+	return poppedResult(value, err)
+}
+
+// poppedResult turns a missing or empty container into a nil reply
+func poppedResult(value string, err error) (any, error) {
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrEmpty) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func cmdHSet(s *Store, args []string) (any, error) {
+	fields, err := hashPairs("hset", args[1:])
+	if err != nil {
+		return nil, err
+	}
+	added, err := s.hMSet(args[0], fields)
+	return int64(added), err
+}
+
+func cmdHMSet(s *Store, args []string) (any, error) {
+	fields, err := hashPairs("hmset", args[1:])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.hMSet(args[0], fields); err != nil {
+		return nil, err
+	}
+	return OK, nil
+}
+
+// hashPairs turns field value [field value ...] into a map, later pairs win
+func hashPairs(name string, args []string) (map[string]string, error) {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' command", name)
+	}
+	fields := make(map[string]string, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		fields[args[i]] = args[i+1]
+	}
+	return fields, nil
+}
+
+func cmdHSetNX(s *Store, args []string) (any, error) {
+	set, err := s.hSetNX(args[0], args[1], args[2])
+	return boolInt(set), err
+}
+
+func cmdHDel(s *Store, args []string) (any, error) {
+	removed, err := s.hDel(args[0], args[1:]...)
+	if errors.Is(err, ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	return int64(removed), err
+}
+
+func cmdHExists(s *Store, args []string) (any, error) {
+	found, err := s.hExists(args[0], args[1])
+	return boolInt(found), err
+}
+
+func cmdHLen(s *Store, args []string) (any, error) {
+	n, err := s.hLen(args[0])
+	if errors.Is(err, ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	return int64(n), err
+}
+
+func cmdHKeys(s *Store, args []string) (any, error) {
+	fields, err := s.hKeys(args[0])
+	if errors.Is(err, ErrKeyNotFound) {
+		return []string{}, nil
+	}
+	return fields, err
+}
+
+func cmdHVals(s *Store, args []string) (any, error) {
+	values, err := s.hVals(args[0])
+	if errors.Is(err, ErrKeyNotFound) {
+		return []string{}, nil
+	}
+	return values, err
+}
+
+func cmdHMGet(s *Store, args []string) (any, error) {
+	values, err := s.hMGet(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func cmdHGet(s *Store, args []string) (any, error) {
+	value, err := s.hGet(args[0], args[1])
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrFieldNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func cmdHGetAll(s *Store, args []string) (any, error) {
+	m, err := s.hGetAll(args[0])
+	if errors.Is(err, ErrKeyNotFound) {
+		return map[string]string{}, nil
+	}
+	return m, err
+}
+
+// cmdHExpire handles HEXPIRE key seconds FIELDS numfields field [field ...]
+func cmdHExpire(s *Store, args []string) (any, error) {
+	n, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	fields, err := cmdFields(args[2:])
+	if err != nil {
+		return nil, err
+	}
+	return s.hExpire(args[0], time.Duration(n)*time.Second, fields...)
+}
+
+func cmdHTTL(s *Store, args []string) (any, error) {
+	fields, err := cmdFields(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return s.hTTL(args[0], fields...)
+}
+
+func cmdHPersist(s *Store, args []string) (any, error) {
+	fields, err := cmdFields(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return s.hPersist(args[0], fields...)
+}
+
+// cmdFields reads the FIELDS numfields field [field ...] block of the field expiry commands
+func cmdFields(args []string) ([]string, error) {
+	if strings.ToUpper(args[0]) != "FIELDS" {
+		return nil, ErrSyntax
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	if n <= 0 || n != len(args)-2 {
+		return nil, fmt.Errorf("numfields must match the number of fields")
+	}
+	return args[2:], nil
+}
+
+// cmdHScan handles HSCAN key cursor [MATCH pattern] [COUNT count], replying
+// with a flat field/value array as redis does under both protocols
+func cmdHScan(s *Store, args []string) (any, error) {
+	cursor, opts, err := parseScanArgs(args[1], args[2:], false)
+	if err != nil {
+		return nil, err
+	}
+	fields, next, err := s.hScan(args[0], cursor, opts)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+	flat := make([]string, 0, 2*len(fields))
+	for field, v := range fields {
+		flat = append(flat, field, v)
+	}
+	return []any{strconv.FormatUint(next, 10), flat}, nil
+}
+
+func cmdHIncrBy(s *Store, args []string) (any, error) {
+	delta, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	return s.hIncrBy(args[0], args[1], delta)
+}
+
+func cmdHIncrByFloat(s *Store, args []string) (any, error) {
+	delta, err := parseFloat(args[2])
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.hIncrByFloat(args[0], args[1], delta)
+	if err != nil {
+		return nil, err
+	}
+	return formatCounterFloat(f), nil
+}
+
+// cmdZAdd handles ZADD key score member [score member ...]
+func cmdZAdd(s *Store, args []string) (any, error) {
+	if len(args)%2 != 1 {
+		return nil, ErrSyntax
+	}
+	members := make([]DataTypeValue.ZMember, 0, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		score, err := parseFloat(args[i])
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, DataTypeValue.ZMember{Member: args[i+1], Score: score})
+	}
+	added, err := s.zAdd(args[0], members...)
+	return int64(added), err
+}
+
+func cmdZIncrBy(s *Store, args []string) (any, error) {
+	delta, err := parseFloat(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return s.zIncrBy(args[0], args[2], delta)
+}
+
+func cmdZRem(s *Store, args []string) (any, error) {
+	removed, err := s.zRem(args[0], args[1:]...)
+	if errors.Is(err, ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	return int64(removed), err
+}
+
+func cmdZScore(s *Store, args []string) (any, error) {
+	score, err := s.zScore(args[0], args[1])
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrMemberNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return score, nil
+}
+
+func cmdZCard(s *Store, args []string) (any, error) {
+	count, err := s.zCard(args[0])
+	if errors.Is(err, ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	return int64(count), err
+}
+
+func cmdZRank(s *Store, args []string) (any, error) {
+	return zRank(s, args, false)
+}
+
+func cmdZRevRank(s *Store, args []string) (any, error) {
+	return zRank(s, args, true)
+}
+
+func zRank(s *Store, args []string, reverse bool) (any, error) {
+	rank, err := s.zRank(args[0], args[1], reverse)
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrMemberNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return int64(rank), nil
+}
+
+func cmdZRange(s *Store, args []string) (any, error) {
+	return zRange(s, args, false)
+}
+
+func cmdZRevRange(s *Store, args []string) (any, error) {
+	return zRange(s, args, true)
+}
+
+// zRange handles ZRANGE key start stop [REV] [WITHSCORES] and ZREVRANGE
+func zRange(s *Store, args []string, reverse bool) (any, error) {
+	start, err := strconv.Atoi(args[1])
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	stop, err := strconv.Atoi(args[2])
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	withScores := false
+	for _, opt := range args[3:] {
+		switch strings.ToLower(opt) {
+		case "withscores":
+			withScores = true
+		case "rev":
+			reverse = true
+		default:
+			return nil, ErrSyntax
+		}
+	}
+	members, err := s.zRange(args[0], start, stop, reverse)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+	return zMembersResult(members, withScores), nil
+}
+
+func cmdZRangeByScore(s *Store, args []string) (any, error) {
+	return zRangeByScore(s, args, false)
+}
+
+func cmdZRevRangeByScore(s *Store, args []string) (any, error) {
+	return zRangeByScore(s, args, true)
+}
+
+// zRangeByScore handles ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+// and ZREVRANGEBYSCORE key max min ...
+func zRangeByScore(s *Store, args []string, reverse bool) (any, error) {
+	minArg, maxArg := args[1], args[2]
+	if reverse {
+		minArg, maxArg = maxArg, minArg
+	}
+	min, err := DataTypeValue.ParseScoreBound(minArg)
+	if err != nil {
+		return nil, err
+	}
+	max, err := DataTypeValue.ParseScoreBound(maxArg)
+	if err != nil {
+		return nil, err
+	}
+
+	withScores := false
+	offset, limit := 0, -1
+	opts := args[3:]
+	for i := 0; i < len(opts); i++ {
+		switch strings.ToLower(opts[i]) {
+		case "withscores":
+			withScores = true
+		case "limit":
+			if i+2 >= len(opts) {
+				return nil, ErrSyntax
+			}
+			if offset, err = strconv.Atoi(opts[i+1]); err != nil {
+				return nil, ErrNotInteger
+			}
+			if limit, err = strconv.Atoi(opts[i+2]); err != nil {
+				return nil, ErrNotInteger
+			}
+			i += 2
+		default:
+			return nil, ErrSyntax
+		}
+	}
+	members, err := s.zRangeByScore(args[0], min, max, offset, limit, reverse)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+	return zMembersResult(members, withScores), nil
+}
+
+// zMembersResult is the member list of a range reply, interleaved with the scores when asked to
+func zMembersResult(members []DataTypeValue.ZMember, withScores bool) any {
+	if !withScores {
+		names := make([]string, len(members))
+		for i, m := range members {
+			names[i] = m.Member
+		}
+		return names
+	}
+	out := make([]any, 0, 2*len(members))
+	for _, m := range members {
+		out = append(out, m.Member, m.Score)
+	}
+	return out
+}
+
+// cmdPQPush handles PQPUSH key priority value
+func cmdPQPush(s *Store, args []string) (any, error) {
+	priority, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, ErrNotInteger
+	}
+	length, err := s.pqPush(args[0], args[2], priority)
+	return int64(length), err
+}
+
+func cmdPQPop(s *Store, args []string) (any, error) {
+	return pqItemResult(s.pqPop(args[0]))
+}
+
+func cmdPQPeek(s *Store, args []string) (any, error) {
+	return pqItemResult(s.pqPeek(args[0]))
+}
+
+func cmdPQLen(s *Store, args []string) (any, error) {
+	length, err := s.pqLen(args[0])
+	if errors.Is(err, ErrKeyNotFound) {
+		return int64(0), nil
+	}
+	return int64(length), err
+}
+
+// pqItemResult replies [value, priority], or a null array for a missing or empty queue
+func pqItemResult(item DataTypeValue.PQItem, err error) (any, error) {
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrEmpty) {
+		return []any(nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []any{item.Value, item.Priority}, nil
+}
+
+// parseFloat parses a float argument, NaN is not a valid score or increment
+func parseFloat(arg string) (float64, error) {
+	f, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, ErrNotFloat
+	}
+	return f, nil
+}
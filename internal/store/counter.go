@@ -0,0 +1,226 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+var (
+	ErrOverflow  = errors.New("increment or decrement would overflow")
+	ErrNotFinite = errors.New("increment would produce NaN or Infinity")
+)
+
+// ===== COUNTER OPERATIONS =====
+
+// IncrBy adds delta to the integer stored at key, a missing key counting as 0,
+// and returns the new value. The AOF records the resulting value so replay is
+// idempotent, and an existing expiry is kept.
+func (s *Store) IncrBy(key string, delta int64) (int64, error) {
+	s.mu.Lock()
+	n, err := s.incrBy(key, delta)
+	return n, s.commit(err)
+}
+
+func (s *Store) incrBy(key string, delta int64) (int64, error) {
+	str, err := s.counterString(key)
+	if err != nil {
+		return 0, err
+	}
+	var current int64
+	if str != nil {
+		if current, err = strconv.ParseInt(str.String(), 10, 64); err != nil {
+			return 0, ErrNotInteger
+		}
+	}
+	n, err := addInt(current, delta)
+	if err != nil {
+		return 0, err
+	}
+	return n, s.storeCounter(key, str, strconv.FormatInt(n, 10), "incrby")
+}
+
+// IncrByFloat adds delta to the number stored at key, a missing key counting as 0,
+// and returns the new value. Like IncrBy it logs the result and keeps the expiry.
+func (s *Store) IncrByFloat(key string, delta float64) (float64, error) {
+	s.mu.Lock()
+	f, err := s.incrByFloat(key, delta)
+	return f, s.commit(err)
+}
+
+func (s *Store) incrByFloat(key string, delta float64) (float64, error) {
+	str, err := s.counterString(key)
+	if err != nil {
+		return 0, err
+	}
+	var current float64
+	if str != nil {
+		if current, err = parseCounterFloat(str.String()); err != nil {
+			return 0, err
+		}
+	}
+	f := current + delta
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, ErrNotFinite
+	}
+	return f, s.storeCounter(key, str, formatCounterFloat(f), "incrbyfloat")
+}
+
+// counterString returns the string at key, or nil when it is missing. Requires the write lock.
+func (s *Store) counterString(key string) (*DataTypeValue.StringValue, error) {
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return nil, err
+	}
+	val, exists := s.data[key]
+	if !exists {
+		return nil, nil
+	}
+	str, ok := val.(*DataTypeValue.StringValue)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected string", ErrWrongType)
+	}
+	return str, nil
+}
+
+// storeCounter writes the new counter value in place, so a deadline on the key
+// survives, and logs it as an INCR record holding the result
+func (s *Store) storeCounter(key string, str *DataTypeValue.StringValue, value, event string) error {
+	if str == nil {
+		s.data[key] = &DataTypeValue.StringValue{Data: []byte(value)}
+	} else {
+		str.Data = append(str.Data[:0], value...)
+	}
+	s.trackKey(key)
+	s.notify(event, key, domain.String)
+
+	if s.oplog {
+		if err := s.writeAOF("INCR", key, "string", value); err != nil {
+			return err
+		}
+	}
+	logger.Debug("INCR operation", "key", key, "value", value)
+	return nil
+}
+
+// HIncrBy adds delta to the integer stored in a hashmap field, creating the
+// key and field as needed, and returns the new value. The AOF records the
+// resulting value as an HSET.
+func (s *Store) HIncrBy(key, field string, delta int64) (int64, error) {
+	s.mu.Lock()
+	n, err := s.hIncrBy(key, field, delta)
+	return n, s.commit(err)
+}
+
+func (s *Store) hIncrBy(key, field string, delta int64) (int64, error) {
+	hashVal, err := s.counterHashmap(key)
+	if err != nil {
+		return 0, err
+	}
+	var current int64
+	if str, ok := hashVal.Data[field]; ok {
+		if current, err = strconv.ParseInt(str, 10, 64); err != nil {
+			return 0, fmt.Errorf("hash %w", ErrNotInteger)
+		}
+	}
+	n, err := addInt(current, delta)
+	if err != nil {
+		return 0, err
+	}
+	return n, s.storeHashCounter(key, hashVal, field, strconv.FormatInt(n, 10), "hincrby")
+}
+
+// HIncrByFloat is HIncrBy for floating point values
+func (s *Store) HIncrByFloat(key, field string, delta float64) (float64, error) {
+	s.mu.Lock()
+	f, err := s.hIncrByFloat(key, field, delta)
+	return f, s.commit(err)
+}
+
+func (s *Store) hIncrByFloat(key, field string, delta float64) (float64, error) {
+	hashVal, err := s.counterHashmap(key)
+	if err != nil {
+		return 0, err
+	}
+	var current float64
+	if str, ok := hashVal.Data[field]; ok {
+		if current, err = parseCounterFloat(str); err != nil {
+			return 0, fmt.Errorf("hash %w", err)
+		}
+	}
+	f := current + delta
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, ErrNotFinite
+	}
+	return f, s.storeHashCounter(key, hashVal, field, formatCounterFloat(f), "hincrbyfloat")
+}
+
+// counterHashmap returns the hashmap at key, creating an empty one if it is
+// missing. The caller must store a field before releasing the write lock.
+func (s *Store) counterHashmap(key string) (*DataTypeValue.HashmapValue, error) {
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return nil, err
+	}
+	val, exists := s.data[key]
+	if !exists {
+		return DataTypeValue.NewHashmapValue(), nil
+	}
+	hashVal, ok := val.(*DataTypeValue.HashmapValue)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected hashmap", ErrWrongType)
+	}
+	return hashVal, nil
+}
+
+func (s *Store) storeHashCounter(key string, hashVal *DataTypeValue.HashmapValue, field, value, event string) error {
+	hashVal.Set(field, value)
+	s.data[key] = hashVal
+	s.trackKey(key)
+	s.notify(event, key, domain.Hashmap)
+
+	if s.oplog {
+		data, err := json.Marshal(HSetPayload{Field: field, Value: value})
+		if err != nil {
+			return err
+		}
+		if err := s.writeAOF("HSET", key, "hashmap", string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addInt(current, delta int64) (int64, error) {
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+	return current + delta, nil
+}
+
+func parseCounterFloat(str string) (float64, error) {
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, ErrNotFloat
+	}
+	return f, nil
+}
+
+// formatCounterFloat writes f without an exponent, like redis' INCRBYFLOAT
+func formatCounterFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
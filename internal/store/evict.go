@@ -0,0 +1,283 @@
+package store
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+type EvictionPolicy string
+
+const (
+	NoEviction    EvictionPolicy = "noeviction"
+	AllKeysLRU    EvictionPolicy = "allkeys-lru"
+	AllKeysLFU    EvictionPolicy = "allkeys-lfu"
+	VolatileLRU   EvictionPolicy = "volatile-lru"
+	VolatileTTL   EvictionPolicy = "volatile-ttl"
+	AllKeysRandom EvictionPolicy = "allkeys-random"
+)
+
+const (
+	// candidates inspected per eviction, same default as redis' maxmemory-samples
+	evictionSamples = 5
+	// fixed per-key cost on top of the value estimate (map entry + metadata)
+	keyOverhead = 64
+
+	lfuInitCounter = 5
+	lfuLogFactor   = 10
+	lfuDecayPeriod = time.Minute
+)
+
+func ParseEvictionPolicy(policy string) (EvictionPolicy, error) {
+	switch p := EvictionPolicy(policy); p {
+	case NoEviction, AllKeysLRU, AllKeysLFU, VolatileLRU, VolatileTTL, AllKeysRandom:
+		return p, nil
+	}
+	return NoEviction, fmt.Errorf("unknown eviction policy: %s", policy)
+}
+
+// keyMeta holds per-key bookkeeping for memory accounting and eviction.
+// Access fields are atomic because reads update them under the read lock.
+type keyMeta struct {
+	size    int64
+	version uint64
+	// owned is the Fork count when the value was created or last cloned, see own
+	owned      uint64
+	lastAccess atomic.Int64 // unix nanoseconds
+	freq       atomic.Uint32
+}
+
+type Stats struct {
+	Keys            int    `json:"keys"`
+	Expires         int    `json:"expires"`
+	UsedMemory      int64  `json:"used_memory"`
+	MaxMemory       int64  `json:"maxmemory"`
+	MaxMemoryPolicy string `json:"maxmemory_policy"`
+	EvictedKeys     int64  `json:"evicted_keys"`
+	ExpiredKeys     int64  `json:"expired_keys"`
+	// DroppedEvents counts keyspace events lost because the publisher fell behind
+	DroppedEvents int64 `json:"dropped_events"`
+}
+
+// SetMaxMemory bounds the approximate memory used by the store. A limit of 0 disables it.
+func (s *Store) SetMaxMemory(limit int64, policy EvictionPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxMemory = limit
+	s.policy = policy
+	logger.Info("Memory limit configured", "maxmemory", limit, "policy", policy)
+}
+
+func (s *Store) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var dropped int64
+	if s.publisher != nil {
+		dropped = s.publisher.dropped.Load()
+	}
+	return Stats{
+		Keys:            len(s.data),
+		Expires:         len(s.expires),
+		UsedMemory:      s.usedMemory,
+		MaxMemory:       s.maxMemory,
+		MaxMemoryPolicy: string(s.policy),
+		EvictedKeys:     s.evictedKeys,
+		ExpiredKeys:     s.expiredKeys,
+		DroppedEvents:   dropped,
+	}
+}
+
+// trackKey refreshes the size estimate of key after a mutation. Requires the write lock.
+func (s *Store) trackKey(key string) {
+	val, exists := s.data[key]
+	meta, tracked := s.meta[key]
+	if !exists {
+		if tracked {
+			s.usedMemory -= meta.size
+			delete(s.meta, key)
+			s.index.Remove(keyPosition(key), key)
+			s.markRemoved(key)
+		}
+		return
+	}
+	if !tracked {
+		s.index.Add(keyPosition(key), key)
+		meta = &keyMeta{owned: s.forks.Load()}
+		meta.freq.Store(lfuInitCounter)
+		meta.lastAccess.Store(time.Now().UnixNano())
+		s.meta[key] = meta
+	}
+	size := keyOverhead + int64(len(key)) + val.Size()
+	s.usedMemory += size - meta.size
+	meta.size = size
+	s.writeSeq++
+	meta.version = s.writeSeq
+	s.touch(key)
+}
+
+// touch records an access for LRU/LFU. Safe under the read lock.
+func (s *Store) touch(key string) {
+	meta, ok := s.meta[key]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	counter := lfuDecay(meta, now)
+	if counter < 255 {
+		// logarithmic counter: the more hits a key has, the less likely another one counts
+		base := float64(counter) - lfuInitCounter
+		if base < 0 {
+			base = 0
+		}
+		if rand.Float64() < 1.0/(base*lfuLogFactor+1) {
+			counter++
+		}
+	}
+	meta.freq.Store(counter)
+	meta.lastAccess.Store(now.UnixNano())
+}
+
+// lfuDecay returns the access counter reduced by one per idle decay period
+func lfuDecay(meta *keyMeta, now time.Time) uint32 {
+	counter := meta.freq.Load()
+	idle := now.Sub(time.Unix(0, meta.lastAccess.Load()))
+	periods := uint32(idle / lfuDecayPeriod)
+	if periods >= counter {
+		return 0
+	}
+	return counter - periods
+}
+
+// removeKey drops key and all of its metadata. Requires the write lock.
+func (s *Store) removeKey(key string) {
+	if meta, ok := s.meta[key]; ok {
+		s.usedMemory -= meta.size
+		delete(s.meta, key)
+		s.index.Remove(keyPosition(key), key)
+		s.markRemoved(key)
+	}
+	delete(s.data, key)
+	delete(s.expires, key)
+}
+
+// markRemoved changes the WATCH version of key, which is now missing, so a key
+// that was created and removed again after WATCH does not look untouched. Only
+// the latest maxTombstones removals are kept per key; dropping an older one
+// changes the version of every missing key instead.
+func (s *Store) markRemoved(key string) {
+	s.writeSeq++
+	s.removed[key] = s.writeSeq
+	s.tombstones = append(s.tombstones, tombstone{key: key, seq: s.writeSeq})
+	for len(s.tombstones) > maxTombstones {
+		oldest := s.tombstones[0]
+		s.tombstones = s.tombstones[1:]
+		// a key removed again since has a newer tombstone further on
+		if s.removed[oldest.key] == oldest.seq {
+			delete(s.removed, oldest.key)
+			s.removedSeq = oldest.seq
+		}
+	}
+}
+
+// rebuildMemory recomputes the accounting from scratch, used after AOF replay
+func (s *Store) rebuildMemory() {
+	s.meta = make(map[string]*keyMeta, len(s.data))
+	// any key may have been removed by the replaced data
+	s.writeSeq++
+	s.removedSeq = s.writeSeq
+	s.removed = make(map[string]uint64)
+	s.tombstones = nil
+	s.index = DataTypeValue.NewScanIndex()
+	s.usedMemory = 0
+	for key := range s.data {
+		s.trackKey(key)
+	}
+}
+
+// ensureMemory evicts keys according to the policy until usage is back under
+// the limit. Called before commands that may grow memory; requires the write lock.
+func (s *Store) ensureMemory() error {
+	if s.maxMemory <= 0 {
+		return nil
+	}
+	for s.usedMemory > s.maxMemory {
+		if s.policy == NoEviction || s.policy == "" {
+			return ErrOutOfMemory
+		}
+		victim, ok := s.pickVictim()
+		if !ok {
+			return ErrOutOfMemory
+		}
+		s.evict(victim)
+	}
+	return nil
+}
+
+func (s *Store) evict(key string) {
+	s.notify("evicted", key, s.keyType(key))
+	s.removeKey(key)
+	s.evictedKeys++
+	logger.Debug("Evicted key", "key", key, "policy", s.policy, "usedMemory", s.usedMemory)
+
+	if s.oplog {
+		if err := s.writeAOF("DELETE", key, "", ""); err != nil {
+			logger.Error("Failed to write evicted key to AOF", "key", key, "error", err)
+		}
+	}
+}
+
+// pickVictim samples a few candidate keys and returns the best one for the policy
+func (s *Store) pickVictim() (string, bool) {
+	volatile := s.policy == VolatileLRU || s.policy == VolatileTTL
+	candidates := make([]string, 0, evictionSamples)
+	if volatile {
+		for key := range s.expires {
+			if len(candidates) == evictionSamples {
+				break
+			}
+			candidates = append(candidates, key)
+		}
+	} else {
+		for key := range s.data {
+			if len(candidates) == evictionSamples {
+				break
+			}
+			candidates = append(candidates, key)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	if s.policy == AllKeysRandom {
+		return candidates[rand.Intn(len(candidates))], true
+	}
+
+	now := time.Now()
+	best, bestScore := "", int64(0)
+	for _, key := range candidates {
+		// higher score = better eviction candidate
+		var score int64
+		switch s.policy {
+		case VolatileTTL:
+			score = -s.expires[key].UnixNano()
+		case AllKeysLFU:
+			if meta, ok := s.meta[key]; ok {
+				score = -int64(lfuDecay(meta, now))
+			}
+		default:
+			if meta, ok := s.meta[key]; ok {
+				score = now.UnixNano() - meta.lastAccess.Load()
+			}
+		}
+		if best == "" || score > bestScore {
+			best, bestScore = key, score
+		}
+	}
+	return best, true
+}
@@ -0,0 +1,208 @@
+package store
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+const (
+	// keys sampled per sweeper round, mirrors redis' active expire cycle
+	expireSampleSize = 20
+	// sweeper keeps going while more than this share of a sample was expired
+	expireRepeatRatio = 0.25
+)
+
+// lookup returns the value for key, treating an expired key, or a hashmap
+// whose fields have all expired, as absent. Safe to call under the read lock
+// since it never mutates the store.
+func (s *Store) lookup(key string) (domain.Value, bool) {
+	val, exists := s.data[key]
+	now := time.Now()
+	if !exists || s.isExpired(key, now) {
+		return nil, false
+	}
+	if hashVal, ok := val.(*DataTypeValue.HashmapValue); ok && hashVal.Expires != nil && hashVal.Len(now) == 0 {
+		return nil, false
+	}
+	s.touch(key)
+	return val, true
+}
+
+func (s *Store) isExpired(key string, now time.Time) bool {
+	deadline, ok := s.expires[key]
+	return ok && !now.Before(deadline)
+}
+
+// expireIfNeeded drops key if its deadline has passed, or the fields of a
+// hashmap whose deadline has. Requires the write lock.
+func (s *Store) expireIfNeeded(key string) bool {
+	now := time.Now()
+	if !s.isExpired(key, now) {
+		return s.expireFields(key, now)
+	}
+	s.removeExpired(key)
+	return true
+}
+
+// removeExpired deletes key and logs a DELETE so replay does not depend on wall-clock time
+func (s *Store) removeExpired(key string) {
+	s.notify("expired", key, s.keyType(key))
+	s.removeKey(key)
+	s.expiredKeys++
+	logger.Debug("Key expired", "key", key)
+
+	if s.oplog {
+		if err := s.writeAOF("DELETE", key, "", ""); err != nil {
+			logger.Error("Failed to write expired key to AOF", "key", key, "error", err)
+		}
+	}
+}
+
+// setExpiry records an absolute deadline for key and logs it as EXPIREAT (unix ms)
+func (s *Store) setExpiry(key string, deadline time.Time) error {
+	s.expires[key] = deadline
+	s.bumpVersion(key)
+
+	if s.oplog {
+		ms := strconv.FormatInt(deadline.UnixMilli(), 10)
+		if err := s.writeAOF("EXPIREAT", key, "", ms); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Expire sets a time to live on an existing key. A non-positive ttl deletes the key.
+func (s *Store) Expire(key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	ok, err := s.expire(key, ttl)
+	return ok, s.commit(err)
+}
+
+func (s *Store) expire(key string, ttl time.Duration) (bool, error) {
+	if s.readOnly {
+		return false, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+
+	if _, exists := s.data[key]; !exists {
+		return false, nil
+	}
+
+	if ttl <= 0 {
+		s.notify("del", key, s.keyType(key))
+		s.removeKey(key)
+		if s.oplog {
+			if err := s.writeAOF("DELETE", key, "", ""); err != nil {
+				return true, err
+			}
+		}
+		return true, nil
+	}
+
+	if err := s.setExpiry(key, time.Now().Add(ttl)); err != nil {
+		return true, err
+	}
+	s.notify("expire", key, s.keyType(key))
+	logger.Debug("EXPIRE operation", "key", key, "ttl", ttl)
+	return true, nil
+}
+
+// TTL reports the remaining time to live of key. hasExpiry is false for
+// persistent keys and exists is false for missing or already expired keys.
+func (s *Store) TTL(key string) (remaining time.Duration, hasExpiry bool, exists bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ttl(key)
+}
+
+func (s *Store) ttl(key string) (remaining time.Duration, hasExpiry bool, exists bool) {
+	if _, ok := s.lookup(key); !ok {
+		return 0, false, false
+	}
+	deadline, ok := s.expires[key]
+	if !ok {
+		return 0, false, true
+	}
+	return time.Until(deadline), true, true
+}
+
+// Persist removes the expiry from key. Returns false if the key is missing or had no expiry.
+func (s *Store) Persist(key string) (bool, error) {
+	s.mu.Lock()
+	ok, err := s.persist(key)
+	return ok, s.commit(err)
+}
+
+func (s *Store) persist(key string) (bool, error) {
+	if s.readOnly {
+		return false, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+
+	if _, ok := s.expires[key]; !ok {
+		return false, nil
+	}
+	delete(s.expires, key)
+	s.bumpVersion(key)
+	s.notify("persist", key, s.keyType(key))
+
+	if s.oplog {
+		if err := s.writeAOF("PERSIST", key, "", ""); err != nil {
+			return true, err
+		}
+	}
+	logger.Debug("PERSIST operation", "key", key)
+	return true, nil
+}
+
+// SweepExpired actively removes expired keys by sampling the expiry table,
+// repeating while a large share of each sample turned out to be expired.
+// Returns the number of keys removed.
+func (s *Store) SweepExpired() int {
+	s.mu.Lock()
+	if s.readOnly {
+		// deletions on a read only store come from whoever it follows
+		s.mu.Unlock()
+		return 0
+	}
+	defer s.commit(nil)
+
+	// leased queue items whose visibility timeout passed go back to their queue,
+	// and delayed items that are due join theirs
+	s.runQueueTimers(time.Now())
+	// hashmap fields whose time to live ran out are dropped
+	s.runFieldTimers(time.Now())
+
+	removed := 0
+	for {
+		sampled, expired := 0, 0
+		now := time.Now()
+
+		// map iteration order is randomised, which gives us the sample
+		for key := range s.expires {
+			if sampled == expireSampleSize {
+				break
+			}
+			sampled++
+			if s.isExpired(key, now) {
+				s.removeExpired(key)
+				expired++
+			}
+		}
+		removed += expired
+
+		if sampled == 0 || float64(expired)/float64(sampled) <= expireRepeatRatio {
+			break
+		}
+	}
+
+	if removed > 0 {
+		logger.Debug("Expiry sweep completed", "removed", removed)
+	}
+	return removed
+}
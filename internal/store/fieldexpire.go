@@ -0,0 +1,252 @@
+package store
+
+import (
+	"container/heap"
+	"errors"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+// HExpirePayload is the AOF value of an HEXPIREAT record, the absolute deadline
+// (unix ms) shared by Fields
+type HExpirePayload struct {
+	Fields []string `json:"f"`
+	At     int64    `json:"at"`
+}
+
+// ===== HASHMAP FIELD EXPIRY =====
+
+// HExpire sets a time to live on fields of the hashmap at key and returns a
+// code per field, as redis does: 1 when it was set, 2 when a non-positive ttl
+// deleted the field and -2 when the field or the key does not exist.
+// Setting a field again with HSET, HMSET or a counter drops its time to live.
+func (s *Store) HExpire(key string, ttl time.Duration, fields ...string) ([]int64, error) {
+	s.mu.Lock()
+	codes, err := s.hExpire(key, ttl, fields...)
+	return codes, s.commit(err)
+}
+
+func (s *Store) hExpire(key string, ttl time.Duration, fields ...string) ([]int64, error) {
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+
+	codes := make([]int64, len(fields))
+	hashVal, err := s.fieldsOf(key, codes)
+	if err != nil || hashVal == nil {
+		return codes, err
+	}
+
+	var present []string
+	for i, field := range fields {
+		if _, exists := hashVal.Data[field]; !exists {
+			codes[i] = -2
+			continue
+		}
+		codes[i] = 1
+		present = append(present, field)
+	}
+	if len(present) == 0 {
+		return codes, nil
+	}
+
+	if ttl <= 0 {
+		for i, code := range codes {
+			if code == 1 {
+				codes[i] = 2
+			}
+		}
+		_, err := s.hDel(key, present...)
+		return codes, err
+	}
+
+	deadline := time.Now().Add(ttl)
+	for _, field := range present {
+		hashVal.ExpireAt(field, deadline)
+	}
+	heap.Push(&s.fieldTimers, fieldTimer{at: deadline, key: key})
+	s.trackKey(key)
+	s.notify("hexpire", key, domain.Hashmap)
+
+	payload := HExpirePayload{Fields: present, At: deadline.UnixMilli()}
+	if err := s.writeHashAOF("HEXPIREAT", key, payload); err != nil {
+		return codes, err
+	}
+	logger.Debug("HEXPIRE operation", "key", key, "fields", len(present), "ttl", ttl)
+	return codes, nil
+}
+
+// HTTL returns the remaining time to live of each field in seconds, rounded
+// up, -1 for fields without one and -2 for missing fields or a missing key
+func (s *Store) HTTL(key string, fields ...string) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hTTL(key, fields...)
+}
+
+func (s *Store) hTTL(key string, fields ...string) ([]int64, error) {
+	ttls := make([]int64, len(fields))
+	hashVal, err := s.fieldsOf(key, ttls)
+	if err != nil || hashVal == nil {
+		return ttls, err
+	}
+	now := time.Now()
+	for i, field := range fields {
+		if _, exists := hashVal.Lookup(field, now); !exists {
+			ttls[i] = -2
+			continue
+		}
+		deadline, ok := hashVal.Expires[field]
+		if !ok {
+			ttls[i] = -1
+			continue
+		}
+		ttls[i] = (deadline.Sub(now).Milliseconds() + 999) / 1000
+	}
+	return ttls, nil
+}
+
+// HPersist removes the time to live of fields and returns a code per field:
+// 1 when it was removed, -1 when the field had none and -2 when it does not exist
+func (s *Store) HPersist(key string, fields ...string) ([]int64, error) {
+	s.mu.Lock()
+	codes, err := s.hPersist(key, fields...)
+	return codes, s.commit(err)
+}
+
+func (s *Store) hPersist(key string, fields ...string) ([]int64, error) {
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+
+	codes := make([]int64, len(fields))
+	hashVal, err := s.fieldsOf(key, codes)
+	if err != nil || hashVal == nil {
+		return codes, err
+	}
+	var persisted []string
+	for i, field := range fields {
+		_, exists := hashVal.Data[field]
+		switch {
+		case !exists:
+			codes[i] = -2
+		case hashVal.Persist(field):
+			codes[i] = 1
+			persisted = append(persisted, field)
+		default:
+			codes[i] = -1
+		}
+	}
+	if len(persisted) == 0 {
+		return codes, nil
+	}
+	s.trackKey(key)
+	s.notify("hpersist", key, domain.Hashmap)
+
+	return codes, s.writeHashAOF("HPERSIST", key, persisted)
+}
+
+// fieldsOf returns the hashmap at key, or nil with every code set to -2 when the key is missing
+func (s *Store) fieldsOf(key string, codes []int64) (*DataTypeValue.HashmapValue, error) {
+	val, err := s.checkType(key, domain.Hashmap)
+	if err == nil {
+		return val.(*DataTypeValue.HashmapValue), nil
+	}
+	if !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+	for i := range codes {
+		codes[i] = -2
+	}
+	return nil, nil
+}
+
+// expireFields drops the fields of the hashmap at key whose deadline has passed,
+// and the key once no field is left, and reports whether the key went away.
+// The fields are logged as an HDEL so replay does not depend on wall-clock time.
+// Requires the write lock.
+func (s *Store) expireFields(key string, now time.Time) bool {
+	hashVal, ok := s.data[key].(*DataTypeValue.HashmapValue)
+	if !ok || hashVal.Expires == nil {
+		return false
+	}
+	fields := hashVal.ExpiredFields(now)
+	if len(fields) == 0 {
+		return false
+	}
+	s.own(key)
+	hashVal = s.data[key].(*DataTypeValue.HashmapValue)
+	for _, field := range fields {
+		hashVal.Delete(field)
+	}
+	s.notify("hexpired", key, domain.Hashmap)
+	logger.Debug("Hashmap fields expired", "key", key, "count", len(fields))
+
+	if err := s.writeHashAOF("HDEL", key, fields); err != nil {
+		logger.Error("Failed to write expired fields to AOF", "key", key, "error", err)
+	}
+	if len(hashVal.Data) == 0 {
+		s.notify("del", key, domain.Hashmap)
+		s.removeKey(key)
+		return true
+	}
+	s.trackKey(key)
+	return false
+}
+
+// runFieldTimers expires the hashmap fields whose deadline has passed. Requires the write lock.
+func (s *Store) runFieldTimers(now time.Time) {
+	for len(s.fieldTimers) > 0 && !s.fieldTimers[0].at.After(now) {
+		timer := heap.Pop(&s.fieldTimers).(fieldTimer)
+		// timers of persisted or deleted fields find nothing to expire
+		s.expireFields(timer.key, now)
+	}
+}
+
+// rebuildFieldTimers schedules the field deadlines of every hashmap, used after
+// the whole keyspace was replaced by a snapshot or an AOF replay
+func (s *Store) rebuildFieldTimers() {
+	s.fieldTimers = nil
+	for key := range s.data {
+		s.scheduleFieldTimers(key)
+	}
+}
+
+// scheduleFieldTimers adds a timer for every field deadline of the hashmap at
+// key, used when a hashmap is restored as a whole
+func (s *Store) scheduleFieldTimers(key string) {
+	hashVal, ok := s.data[key].(*DataTypeValue.HashmapValue)
+	if !ok {
+		return
+	}
+	for _, deadline := range hashVal.Expires {
+		heap.Push(&s.fieldTimers, fieldTimer{at: deadline, key: key})
+	}
+}
+
+// fieldTimer is a deadline of one or more fields of the hashmap at key. Like
+// queue timers they are not removed when the fields go away early.
+type fieldTimer struct {
+	at  time.Time
+	key string
+}
+
+type fieldTimerHeap []fieldTimer
+
+func (h fieldTimerHeap) Len() int           { return len(h) }
+func (h fieldTimerHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h fieldTimerHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *fieldTimerHeap) Push(x any)        { *h = append(*h, x.(fieldTimer)) }
+func (h *fieldTimerHeap) Pop() any {
+	old := *h
+	timer := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return timer
+}
@@ -0,0 +1,216 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+// ===== HASHMAP FIELDS =====
+
+// HMSet sets every field of fields in the hashmap at key as one write, and
+// returns how many of them are new. The AOF records a single HMSET with all of them.
+func (s *Store) HMSet(key string, fields map[string]string) (int, error) {
+	s.mu.Lock()
+	added, err := s.hMSet(key, fields)
+	return added, s.commit(err)
+}
+
+func (s *Store) hMSet(key string, fields map[string]string) (int, error) {
+	hashVal, err := s.counterHashmap(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	added := 0
+	for field, value := range fields {
+		if _, exists := hashVal.Data[field]; !exists {
+			added++
+		}
+		hashVal.Set(field, value)
+	}
+	s.data[key] = hashVal
+	s.trackKey(key)
+	s.notify("hset", key, domain.Hashmap)
+
+	return added, s.writeHashAOF("HMSET", key, fields)
+}
+
+// HSetNX sets field only when it is not in the hashmap yet and reports whether it did
+func (s *Store) HSetNX(key, field, value string) (bool, error) {
+	s.mu.Lock()
+	set, err := s.hSetNX(key, field, value)
+	return set, s.commit(err)
+}
+
+func (s *Store) hSetNX(key, field, value string) (bool, error) {
+	hashVal, err := s.counterHashmap(key)
+	if err != nil {
+		return false, err
+	}
+	if _, exists := hashVal.Data[field]; exists {
+		return false, nil
+	}
+	hashVal.Set(field, value)
+	s.data[key] = hashVal
+	s.trackKey(key)
+	s.notify("hset", key, domain.Hashmap)
+
+	return true, s.writeHashAOF("HSET", key, HSetPayload{Field: field, Value: value})
+}
+
+// HDel removes fields from the hashmap at key and returns how many existed.
+// Removing the last field deletes the key.
+func (s *Store) HDel(key string, fields ...string) (int, error) {
+	s.mu.Lock()
+	removed, err := s.hDel(key, fields...)
+	return removed, s.commit(err)
+}
+
+func (s *Store) hDel(key string, fields ...string) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+
+	val, err := s.checkType(key, domain.Hashmap)
+	if err != nil {
+		return 0, err
+	}
+	hashVal := val.(*DataTypeValue.HashmapValue)
+	var deleted []string
+	for _, field := range fields {
+		if hashVal.Delete(field) {
+			deleted = append(deleted, field)
+		}
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+	s.notify("hdel", key, domain.Hashmap)
+	if len(hashVal.Data) == 0 {
+		s.notify("del", key, domain.Hashmap)
+		s.removeKey(key)
+	} else {
+		s.trackKey(key)
+	}
+
+	if err := s.writeHashAOF("HDEL", key, deleted); err != nil {
+		return len(deleted), err
+	}
+	logger.Debug("HDEL operation", "key", key, "count", len(deleted))
+	return len(deleted), nil
+}
+
+func (s *Store) HExists(key, field string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hExists(key, field)
+}
+
+func (s *Store) hExists(key, field string) (bool, error) {
+	_, err := s.hGet(key, field)
+	if errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrFieldNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Store) HLen(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hLen(key)
+}
+
+func (s *Store) hLen(key string) (int, error) {
+	val, err := s.checkType(key, domain.Hashmap)
+	if err != nil {
+		return 0, err
+	}
+	return val.(*DataTypeValue.HashmapValue).Len(time.Now()), nil
+}
+
+// HKeys returns the field names of the hashmap at key in no particular order
+func (s *Store) HKeys(key string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hKeys(key)
+}
+
+func (s *Store) hKeys(key string) ([]string, error) {
+	val, err := s.checkType(key, domain.Hashmap)
+	if err != nil {
+		return nil, err
+	}
+	live := val.(*DataTypeValue.HashmapValue).Live(time.Now())
+	fields := make([]string, 0, len(live))
+	for field := range live {
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// HVals returns the values of the hashmap at key in no particular order
+func (s *Store) HVals(key string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hVals(key)
+}
+
+func (s *Store) hVals(key string) ([]string, error) {
+	val, err := s.checkType(key, domain.Hashmap)
+	if err != nil {
+		return nil, err
+	}
+	live := val.(*DataTypeValue.HashmapValue).Live(time.Now())
+	values := make([]string, 0, len(live))
+	for _, value := range live {
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// HMGet returns the value of each field, nil for the ones that are not set.
+// A missing key has no fields.
+func (s *Store) HMGet(key string, fields ...string) ([]*string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hMGet(key, fields...)
+}
+
+func (s *Store) hMGet(key string, fields ...string) ([]*string, error) {
+	values := make([]*string, len(fields))
+	val, err := s.checkType(key, domain.Hashmap)
+	if errors.Is(err, ErrKeyNotFound) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	hashVal := val.(*DataTypeValue.HashmapValue)
+	now := time.Now()
+	for i, field := range fields {
+		if value, exists := hashVal.Lookup(field, now); exists {
+			values[i] = &value
+		}
+	}
+	return values, nil
+}
+
+func (s *Store) writeHashAOF(op, key string, payload any) error {
+	if !s.oplog {
+		return nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return s.writeAOF(op, key, "hashmap", string(data))
+}
@@ -0,0 +1,311 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mrpurushotam/mini_db/internal/aof"
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+var ErrIndexOutOfRange = errors.New("index out of range")
+
+// ListIndexPayload is the AOF value of LSET and LINSERT records. Index is the
+// resolved position from the head, so replay does not depend on the pivot.
+type ListIndexPayload struct {
+	Index int    `json:"i"`
+	Value string `json:"v"`
+}
+
+// LRemPayload is the AOF value of an LREM record
+type LRemPayload struct {
+	Count int    `json:"n"`
+	Value string `json:"v"`
+}
+
+// LTrimPayload is the AOF value of an LTRIM record, the kept slice bounds [Start, End)
+type LTrimPayload struct {
+	Start int `json:"s"`
+	End   int `json:"e"`
+}
+
+// ===== LIST OPERATIONS =====
+
+// LPop removes and returns the first element of the list; popping the last one deletes the key
+func (s *Store) LPop(key string) (string, error) {
+	s.mu.Lock()
+	value, err := s.lPop(key)
+	return value, s.commit(err)
+}
+
+// RPop removes and returns the last element of the list; popping the last one deletes the key
+func (s *Store) RPop(key string) (string, error) {
+	s.mu.Lock()
+	value, err := s.rPop(key)
+	return value, s.commit(err)
+}
+
+func (s *Store) LLen(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lLen(key)
+}
+
+func (s *Store) lLen(key string) (int, error) {
+	val, err := s.checkType(key, domain.List)
+	if err != nil {
+		return 0, err
+	}
+	return len(val.(*DataTypeValue.ListValue).Data), nil
+}
+
+// LIndex returns the element at index; negative indexes count from the tail
+func (s *Store) LIndex(key string, index int) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lIndex(key, index)
+}
+
+func (s *Store) lIndex(key string, index int) (string, error) {
+	val, err := s.checkType(key, domain.List)
+	if err != nil {
+		return "", err
+	}
+	listVal := val.(*DataTypeValue.ListValue)
+	pos, ok := listVal.Position(index)
+	if !ok {
+		return "", ErrIndexOutOfRange
+	}
+	return listVal.Data[pos], nil
+}
+
+// LSet replaces the element at index; negative indexes count from the tail
+func (s *Store) LSet(key string, index int, value string) error {
+	s.mu.Lock()
+	return s.commit(s.lSet(key, index, value))
+}
+
+func (s *Store) lSet(key string, index int, value string) error {
+	listVal, err := s.writableList(key)
+	if err != nil {
+		return err
+	}
+	pos, ok := listVal.Position(index)
+	if !ok {
+		return ErrIndexOutOfRange
+	}
+	listVal.Data[pos] = value
+	s.trackKey(key)
+	s.notify("lset", key, domain.List)
+
+	return s.writeListAOF("LSET", key, ListIndexPayload{Index: pos, Value: value})
+}
+
+// LInsert puts value before or after the first occurrence of pivot and returns
+// the new length of the list, or -1 when pivot is not in it
+func (s *Store) LInsert(key, pivot, value string, before bool) (int, error) {
+	s.mu.Lock()
+	length, err := s.lInsert(key, pivot, value, before)
+	return length, s.commit(err)
+}
+
+func (s *Store) lInsert(key, pivot, value string, before bool) (int, error) {
+	listVal, err := s.writableList(key)
+	if err != nil {
+		return 0, err
+	}
+	pos := listVal.Find(pivot)
+	if pos < 0 {
+		return -1, nil
+	}
+	if !before {
+		pos++
+	}
+	listVal.InsertAt(pos, value)
+	s.trackKey(key)
+	s.notify("linsert", key, domain.List)
+
+	length := len(listVal.Data)
+	return length, s.writeListAOF("LINSERT", key, ListIndexPayload{Index: pos, Value: value})
+}
+
+// LRem removes the first count occurrences of value, the last -count ones for a
+// negative count or all of them for 0, and returns how many were removed
+func (s *Store) LRem(key string, count int, value string) (int, error) {
+	s.mu.Lock()
+	removed, err := s.lRem(key, count, value)
+	return removed, s.commit(err)
+}
+
+func (s *Store) lRem(key string, count int, value string) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+
+	val, err := s.checkType(key, domain.List)
+	if err != nil {
+		return 0, err
+	}
+	listVal := val.(*DataTypeValue.ListValue)
+	removed := listVal.Remove(value, count)
+	if removed == 0 {
+		return 0, nil
+	}
+	s.notify("lrem", key, domain.List)
+	s.dropIfEmpty(key, listVal)
+
+	return removed, s.writeListAOF("LREM", key, LRemPayload{Count: count, Value: value})
+}
+
+// LTrim keeps only the elements between start and stop inclusive; negative
+// indexes count from the tail. Trimming every element deletes the key.
+func (s *Store) LTrim(key string, start, stop int) error {
+	s.mu.Lock()
+	return s.commit(s.lTrim(key, start, stop))
+}
+
+func (s *Store) lTrim(key string, start, stop int) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+
+	val, err := s.checkType(key, domain.List)
+	if err != nil {
+		return err
+	}
+	listVal := val.(*DataTypeValue.ListValue)
+	from, to := listVal.Bounds(start, stop)
+	if from == 0 && to == len(listVal.Data) {
+		return nil
+	}
+	listVal.Data = append([]string(nil), listVal.Data[from:to]...)
+	s.notify("ltrim", key, domain.List)
+	s.dropIfEmpty(key, listVal)
+
+	return s.writeListAOF("LTRIM", key, LTrimPayload{Start: from, End: to})
+}
+
+// LMove pops an element from one end of source and pushes it onto one end of
+// destination in a single step, and returns it. Source and destination may be
+// the same list, which rotates it.
+func (s *Store) LMove(source, destination string, fromLeft, toLeft bool) (string, error) {
+	s.mu.Lock()
+	value, err := s.lMove(source, destination, fromLeft, toLeft)
+	return value, s.commit(err)
+}
+
+func (s *Store) lMove(source, destination string, fromLeft, toLeft bool) (string, error) {
+	if s.readOnly {
+		return "", ErrReadOnly
+	}
+	s.expireIfNeeded(source)
+	s.own(source)
+	s.expireIfNeeded(destination)
+	s.own(destination)
+	if err := s.ensureMemory(); err != nil {
+		return "", err
+	}
+
+	val, err := s.checkType(source, domain.List)
+	if err != nil {
+		return "", err
+	}
+	srcVal := val.(*DataTypeValue.ListValue)
+	if len(srcVal.Data) == 0 {
+		return "", fmt.Errorf("list is %w", ErrEmpty)
+	}
+	dstVal := srcVal
+	if destination != source {
+		if val, exists := s.data[destination]; exists {
+			var ok bool
+			if dstVal, ok = val.(*DataTypeValue.ListValue); !ok {
+				return "", fmt.Errorf("%w: expected list", ErrWrongType)
+			}
+		} else {
+			dstVal = &DataTypeValue.ListValue{Data: make([]string, 0)}
+			s.data[destination] = dstVal
+		}
+	}
+
+	popOp, pushOp := "RPOP", "RPUSH"
+	var value string
+	if fromLeft {
+		popOp = "LPOP"
+		value = srcVal.Data[0]
+		srcVal.Data = srcVal.Data[1:]
+	} else {
+		value = srcVal.Data[len(srcVal.Data)-1]
+		srcVal.Data = srcVal.Data[:len(srcVal.Data)-1]
+	}
+	if toLeft {
+		pushOp = "LPUSH"
+		dstVal.Data = append([]string{value}, dstVal.Data...)
+	} else {
+		dstVal.Data = append(dstVal.Data, value)
+	}
+
+	s.notify(strings.ToLower(popOp), source, domain.List)
+	s.dropIfEmpty(source, srcVal)
+	s.trackKey(destination)
+	s.notify(strings.ToLower(pushOp), destination, domain.List)
+	s.signalReady(destination)
+
+	if s.oplog {
+		// both halves are logged as one group, so a torn write cannot lose the element
+		ops := []aof.Operation{
+			{Type: popOp, Key: source, ValueType: "list"},
+			{Type: pushOp, Key: destination, ValueType: "list", Value: value},
+		}
+		if err := s.writeAOFBatch(ops); err != nil {
+			return value, err
+		}
+	}
+	logger.Debug("LMOVE operation", "source", source, "destination", destination)
+	return value, nil
+}
+
+// writableList returns the existing list at key for an in-place edit. Requires the write lock.
+func (s *Store) writableList(key string) (*DataTypeValue.ListValue, error) {
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return nil, err
+	}
+	val, err := s.checkType(key, domain.List)
+	if err != nil {
+		return nil, err
+	}
+	return val.(*DataTypeValue.ListValue), nil
+}
+
+// dropIfEmpty removes key once its list has no elements left, otherwise it updates its size
+func (s *Store) dropIfEmpty(key string, listVal *DataTypeValue.ListValue) {
+	if len(listVal.Data) == 0 {
+		s.notify("del", key, domain.List)
+		s.removeKey(key)
+		return
+	}
+	s.trackKey(key)
+}
+
+func (s *Store) writeListAOF(op, key string, payload any) error {
+	if !s.oplog {
+		return nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return s.writeAOF(op, key, "list", string(data))
+}
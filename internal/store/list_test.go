@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitBlocked waits until a client is parked on key
+func waitBlocked(t *testing.T, s *Store, key string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.RLock()
+		n := len(s.blocked[key])
+		s.mu.RUnlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("no client blocked on %s", key)
+}
+
+func TestRPushReportsLengthWhenWaiterTakesElement(t *testing.T) {
+	s := NewStore()
+	got := make(chan string, 1)
+	go func() {
+		_, value, _, _ := s.BlockingPop(context.Background(), PopListLeft, []string{"q"}, time.Second)
+		got <- value
+	}()
+	waitBlocked(t, s, "q")
+
+	n, err := s.RPush("q", "job")
+	if err != nil || n != 1 {
+		t.Fatalf("RPush = %d, %v; want 1, nil", n, err)
+	}
+	if value := <-got; value != "job" {
+		t.Fatalf("waiter got %q, want job", value)
+	}
+	if _, ok := s.Type("q"); ok {
+		t.Fatal("list handed to the waiter still exists")
+	}
+}
+
+func TestLPushReturnsLength(t *testing.T) {
+	s := NewStore()
+	if n, err := s.LPush("l", "a", "b"); err != nil || n != 2 {
+		t.Fatalf("LPush = %d, %v; want 2, nil", n, err)
+	}
+	if n, err := s.LPush("l", "c"); err != nil || n != 3 {
+		t.Fatalf("LPush = %d, %v; want 3, nil", n, err)
+	}
+}
+
+// A multi-value LPUSH replays to the order it left in memory
+func TestLPushOrderSurvivesRestart(t *testing.T) {
+	s, path := newAOFStore(t)
+	if _, err := s.Run(Command{Name: "LPUSH", Args: []string{"l", "a", "b", "c"}}); err != nil {
+		t.Fatalf("LPUSH: %v", err)
+	}
+	if _, err := s.Run(Command{Name: "LPUSH", Args: []string{"l", "d", "e"}}); err != nil {
+		t.Fatalf("LPUSH: %v", err)
+	}
+	live, _ := s.LRange("l", 0, -1)
+	if want := "e d c b a"; strings.Join(live, " ") != want {
+		t.Fatalf("live list = %v, want [%s]", live, want)
+	}
+
+	replayed, _ := openAOFStore(t, path).LRange("l", 0, -1)
+	if !slices.Equal(replayed, live) {
+		t.Fatalf("replayed list = %v, want %v", replayed, live)
+	}
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/rdb"
+	"github.com/mrpurushotam/mini_db/internal/slots"
+)
+
+var (
+	ErrKeyExists = errors.New("target key name already exists")
+	ErrMoved     = errors.New("MOVED the slot of the key was handed to another node")
+)
+
+// DumpKey serializes the value at key in the rdb encoding, the way redis' DUMP
+// does, together with its deadline (zero when the key does not expire)
+func (s *Store) DumpKey(key string) ([]byte, time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	val, exists := s.lookup(key)
+	if !exists {
+		return nil, time.Time{}, ErrKeyNotFound
+	}
+	payload, err := rdb.DumpValue(val)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return payload, s.expires[key], nil
+}
+
+// RestoreKey creates key from the output of DumpKey. An existing key is only
+// overwritten when replace is set. A deadline that has already passed leaves
+// nothing behind, like a key that expired in transit.
+func (s *Store) RestoreKey(key string, payload []byte, deadline time.Time, replace bool) error {
+	s.mu.Lock()
+	return s.commit(s.restoreKey(key, payload, deadline, replace))
+}
+
+func (s *Store) restoreKey(key string, payload []byte, deadline time.Time, replace bool) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	if _, exists := s.data[key]; exists && !replace {
+		return ErrKeyExists
+	}
+	val, err := rdb.RestoreValue(payload)
+	if err != nil {
+		return err
+	}
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return nil
+	}
+	if err := s.ensureMemory(); err != nil {
+		return err
+	}
+
+	s.data[key] = val
+	delete(s.expires, key)
+	s.trackKey(key)
+	s.scheduleQueueTimers(key)
+	s.scheduleFieldTimers(key)
+	s.notify("restore", key, val.Type())
+	s.signalReady(key)
+
+	if s.oplog {
+		encoded := base64.StdEncoding.EncodeToString(payload)
+		if err := s.writeAOF("RESTORE", key, string(val.Type()), encoded); err != nil {
+			return err
+		}
+	}
+	if !deadline.IsZero() {
+		if err := s.setExpiry(key, deadline); err != nil {
+			return err
+		}
+	}
+	logger.Debug("RESTORE operation", "key", key, "type", val.Type())
+	return nil
+}
+
+// ReleaseSlots records that slotList were handed to another node and wakes the
+// clients blocked on their keys with ErrMoved, so whoever routed them can retry
+// against the new owner. Blocking pops on those keys fail the same way until
+// ClaimSlots takes the slots back.
+func (s *Store) ReleaseSlots(slotList []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, slot := range slotList {
+		s.released[slot] = true
+	}
+	var moved []*waiter
+	for key, queue := range s.blocked {
+		if s.released[slots.ForKey(key)] {
+			moved = append(moved, queue...)
+		}
+	}
+	for _, w := range moved {
+		// a waiter on several moved keys is queued on each of them
+		if !w.done {
+			w.done = true
+			s.unblock(w)
+			w.ch <- popResult{err: ErrMoved}
+		}
+	}
+	logger.Info("Slots released", "slots", len(slotList), "woken", len(moved))
+}
+
+// ClaimSlots undoes ReleaseSlots for slotList, before they are handed back to this node
+func (s *Store) ClaimSlots(slotList []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, slot := range slotList {
+		delete(s.released, slot)
+	}
+}
+
+// movedAway reports whether any of keys hashes to a released slot. Requires the lock.
+func (s *Store) movedAway(keys []string) bool {
+	if len(s.released) == 0 {
+		return false
+	}
+	for _, key := range keys {
+		if s.released[slots.ForKey(key)] {
+			return true
+		}
+	}
+	return false
+}
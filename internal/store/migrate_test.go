@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/slots"
+)
+
+func TestReleaseSlotsWakesWaitersWithMoved(t *testing.T) {
+	s := NewStore()
+	errs := make(chan error, 1)
+	go func() {
+		_, _, _, err := s.BlockingPop(context.Background(), PopListLeft, []string{"q"}, 0)
+		errs <- err
+	}()
+	waitBlocked(t, s, "q")
+
+	s.ReleaseSlots([]int{slots.ForKey("q")})
+	if err := <-errs; !errors.Is(err, ErrMoved) {
+		t.Fatalf("blocked pop = %v, want ErrMoved", err)
+	}
+	if _, _, _, err := s.BlockingPop(context.Background(), PopListLeft, []string{"q"}, time.Millisecond); !errors.Is(err, ErrMoved) {
+		t.Fatalf("pop on a released slot = %v, want ErrMoved", err)
+	}
+
+	s.ClaimSlots([]int{slots.ForKey("q")})
+	if _, _, ok, err := s.BlockingPop(context.Background(), PopListLeft, []string{"q"}, time.Millisecond); ok || err != nil {
+		t.Fatalf("pop on a claimed slot = %v, %v; want a timeout", ok, err)
+	}
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/pubsub"
+)
+
+// KeyspaceChannelPrefix is prepended to the key to form the channel an event
+// is published on; user patterns only match it when they start with it too
+const KeyspaceChannelPrefix = pubsub.KeyspacePrefix
+
+// maxQueuedEvents bounds the events waiting for the publisher; events made
+// while it is that far behind are dropped and counted, see Stats
+const maxQueuedEvents = 65536
+
+// Event classes besides the data type names, used to filter notifications
+const (
+	ClassGeneric = "generic" // del, expire, persist
+	ClassExpired = "expired"
+	ClassEvicted = "evicted"
+)
+
+// Event describes one change to the keyspace
+type Event struct {
+	Key   string          `json:"key"`
+	Op    string          `json:"op"`
+	Type  domain.DataType `json:"type"`
+	Class string          `json:"class"`
+	Time  int64           `json:"ts"` // unix ms
+}
+
+// Notifier receives keyspace events as JSON messages; pubsub.Broker satisfies it
+type Notifier interface {
+	Publish(channel, message string) int
+}
+
+// SetNotifier routes keyspace events to n; nil turns notifications off
+func (s *Store) SetNotifier(n Notifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n != nil && s.publisher == nil {
+		s.publisher = &eventQueue{wake: make(chan struct{}, 1)}
+		go s.publisher.run()
+	}
+	if s.publisher != nil {
+		s.publisher.setNotifier(n)
+	}
+	s.notifier = n
+}
+
+// notify records an event for key, which commit hands to the publisher. It is
+// called with the write lock held, which keeps events in the same order as
+// the changes.
+func (s *Store) notify(op, key string, t domain.DataType) {
+	if s.notifier == nil {
+		return
+	}
+
+	class := string(t)
+	switch op {
+	case "del", "expire", "persist":
+		class = ClassGeneric
+	case "expired":
+		class = ClassExpired
+	case "evicted":
+		class = ClassEvicted
+	}
+	s.events = append(s.events, Event{Key: key, Op: op, Type: t, Class: class, Time: time.Now().UnixMilli()})
+}
+
+// eventQueue publishes events on its own goroutine, in the order commit
+// pushed them, so encoding them and matching subscriber patterns does not
+// hold up the store. Like a slow subscriber, a publisher that falls behind
+// loses events rather than holding memory for them.
+type eventQueue struct {
+	mu       sync.Mutex
+	notifier Notifier
+	events   []Event
+	wake     chan struct{}
+	// overflowing is set once events are dropped, until the queue is taken
+	overflowing bool
+	dropped     atomic.Int64
+}
+
+func (q *eventQueue) setNotifier(n Notifier) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.notifier = n
+}
+
+func (q *eventQueue) push(events []Event) {
+	q.mu.Lock()
+	if room := maxQueuedEvents - len(q.events); len(events) > room {
+		q.dropped.Add(int64(len(events) - room))
+		if !q.overflowing {
+			q.overflowing = true
+			logger.Warn("Keyspace event queue full, dropping events", "queued", len(q.events))
+		}
+		events = events[:room]
+	}
+	q.events = append(q.events, events...)
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+		// the publisher has not taken the previous batch yet and will see these with it
+	}
+}
+
+func (q *eventQueue) run() {
+	for range q.wake {
+		q.mu.Lock()
+		events, n := q.events, q.notifier
+		q.events = nil
+		q.overflowing = false
+		q.mu.Unlock()
+		if n == nil {
+			continue
+		}
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			n.Publish(KeyspaceChannelPrefix+event.Key, string(data))
+		}
+	}
+}
+
+// keyType returns the type of the value at key, ignoring expiry; empty if missing
+func (s *Store) keyType(key string) domain.DataType {
+	if val, exists := s.data[key]; exists {
+		return val.Type()
+	}
+	return ""
+}
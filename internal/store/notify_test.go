@@ -0,0 +1,110 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/pubsub"
+)
+
+// next returns the next message of sub, failing after a second
+func next(t *testing.T, sub *pubsub.Subscriber) pubsub.Message {
+	t.Helper()
+	select {
+	case msg := <-sub.Messages():
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+	return pubsub.Message{}
+}
+
+// Keyspace events reach patterns on their prefix but not catch-all user patterns
+func TestKeyspaceEventsSkipUserPatterns(t *testing.T) {
+	broker := pubsub.NewBroker(16)
+	s := NewStore()
+	s.SetNotifier(broker)
+
+	all := broker.NewSubscriber()
+	defer all.Close()
+	all.PSubscribe("*")
+	events := broker.NewSubscriber()
+	defer events.Close()
+	events.PSubscribe(KeyspaceChannelPrefix + "*")
+
+	if err := s.Set("k", "v"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if msg := next(t, events); msg.Channel != KeyspaceChannelPrefix+"k" {
+		t.Fatalf("event on %q, want %q", msg.Channel, KeyspaceChannelPrefix+"k")
+	}
+
+	broker.Publish("news", "hello")
+	if msg := next(t, all); msg.Channel != "news" {
+		t.Fatalf("catch-all pattern got a message on %q, want only news", msg.Channel)
+	}
+}
+
+// lockingNotifier reads the store from Publish, which deadlocks if events are
+// published with the store lock held
+type lockingNotifier struct {
+	s    *Store
+	seen chan string
+}
+
+func (n *lockingNotifier) Publish(channel, message string) int {
+	n.s.Type(channel)
+	n.seen <- channel
+	return 1
+}
+
+func TestKeyspaceEventsArePublishedWithoutTheLock(t *testing.T) {
+	s := NewStore()
+	n := &lockingNotifier{s: s, seen: make(chan string, 4)}
+	s.SetNotifier(n)
+
+	if err := s.Set("k", "v"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	select {
+	case channel := <-n.seen:
+		if channel != KeyspaceChannelPrefix+"k" {
+			t.Fatalf("event on %q, want %q", channel, KeyspaceChannelPrefix+"k")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event not published")
+	}
+}
+
+// stalledNotifier blocks in Publish until release is closed
+type stalledNotifier struct {
+	publishing chan struct{}
+	release    chan struct{}
+}
+
+func (n *stalledNotifier) Publish(channel, message string) int {
+	select {
+	case n.publishing <- struct{}{}:
+	default:
+	}
+	<-n.release
+	return 1
+}
+
+// Events beyond the queue bound are dropped and counted while the publisher is stuck
+func TestKeyspaceEventsAreDroppedWhenThePublisherFallsBehind(t *testing.T) {
+	s := NewStore()
+	n := &stalledNotifier{publishing: make(chan struct{}, 1), release: make(chan struct{})}
+	defer close(n.release)
+	s.SetNotifier(n)
+
+	if err := s.Set("k", "v"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	<-n.publishing
+	s.publisher.push(make([]Event, maxQueuedEvents+5))
+
+	if got := s.Stats().DroppedEvents; got != 5 {
+		t.Fatalf("DroppedEvents = %d, want 5", got)
+	}
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+// PQPushPayload is the AOF value of a PQPUSH record
+type PQPushPayload struct {
+	Value    string `json:"v"`
+	Priority int64  `json:"p"`
+}
+
+// ===== PRIORITY QUEUE OPERATIONS =====
+
+// PQPush adds value with priority and returns the new length of the queue
+func (s *Store) PQPush(key, value string, priority int64) (int, error) {
+	s.mu.Lock()
+	length, err := s.pqPush(key, value, priority)
+	return length, s.commit(err)
+}
+
+func (s *Store) pqPush(key, value string, priority int64) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return 0, err
+	}
+
+	val, exists := s.data[key]
+	if !exists {
+		val = DataTypeValue.NewPriorityQueueValue()
+		s.data[key] = val
+	}
+	pqVal, ok := val.(*DataTypeValue.PriorityQueueValue)
+	if !ok {
+		return 0, fmt.Errorf("%w: expected priorityqueue", ErrWrongType)
+	}
+	pqVal.Push(value, priority)
+	s.trackKey(key)
+	s.notify("pqpush", key, domain.PriorityQueue)
+
+	if s.oplog {
+		data, err := json.Marshal(PQPushPayload{Value: value, Priority: priority})
+		if err != nil {
+			return pqVal.Len(), err
+		}
+		if err := s.writeAOF("PQPUSH", key, "priorityqueue", string(data)); err != nil {
+			return pqVal.Len(), err
+		}
+	}
+	logger.Debug("PQPUSH operation", "key", key, "priority", priority)
+	return pqVal.Len(), nil
+}
+
+// PQPop removes and returns the item with the highest priority, the oldest of
+// them on a tie. Popping the last item deletes the key.
+func (s *Store) PQPop(key string) (DataTypeValue.PQItem, error) {
+	s.mu.Lock()
+	item, err := s.pqPop(key)
+	return item, s.commit(err)
+}
+
+func (s *Store) pqPop(key string) (DataTypeValue.PQItem, error) {
+	if s.readOnly {
+		return DataTypeValue.PQItem{}, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+
+	val, err := s.checkType(key, domain.PriorityQueue)
+	if err != nil {
+		return DataTypeValue.PQItem{}, err
+	}
+	pqVal := val.(*DataTypeValue.PriorityQueueValue)
+	item, ok := pqVal.Pop()
+	if !ok {
+		return DataTypeValue.PQItem{}, fmt.Errorf("priority queue is %w", ErrEmpty)
+	}
+	s.notify("pqpop", key, domain.PriorityQueue)
+	if pqVal.Len() == 0 {
+		s.notify("del", key, domain.PriorityQueue)
+		s.removeKey(key)
+	} else {
+		s.trackKey(key)
+	}
+
+	if s.oplog {
+		if err := s.writeAOF("PQPOP", key, "priorityqueue", ""); err != nil {
+			return item, err
+		}
+	}
+	return item, nil
+}
+
+// PQPeek returns the item PQPop would return without removing it
+func (s *Store) PQPeek(key string) (DataTypeValue.PQItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pqPeek(key)
+}
+
+func (s *Store) pqPeek(key string) (DataTypeValue.PQItem, error) {
+	val, err := s.checkType(key, domain.PriorityQueue)
+	if err != nil {
+		return DataTypeValue.PQItem{}, err
+	}
+	item, ok := val.(*DataTypeValue.PriorityQueueValue).Peek()
+	if !ok {
+		return DataTypeValue.PQItem{}, fmt.Errorf("priority queue is %w", ErrEmpty)
+	}
+	return item, nil
+}
+
+func (s *Store) PQLen(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pqLen(key)
+}
+
+func (s *Store) pqLen(key string) (int, error) {
+	val, err := s.checkType(key, domain.PriorityQueue)
+	if err != nil {
+		return 0, err
+	}
+	return val.(*DataTypeValue.PriorityQueueValue).Len(), nil
+}
@@ -0,0 +1,493 @@
+package store
+
+import (
+	"container/heap"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+// DefaultMaxAttempts is how often a leased item is delivered before it is dead-lettered
+const DefaultMaxAttempts = 5
+
+var ErrLeaseNotFound = errors.New("no such lease, it was acked or has expired")
+
+// LeaseOptions configure a reliable dequeue
+type LeaseOptions struct {
+	// Visibility is how long the item stays hidden from other consumers without an ACK
+	Visibility time.Duration
+	// MaxAttempts defaults to DefaultMaxAttempts
+	MaxAttempts int
+	// DeadLetter is the queue exhausted items move to, key + ":dead" by default
+	DeadLetter string
+}
+
+// LeasePayload is the AOF record of a reliable dequeue. The lease ID and the
+// absolute deadline are recorded so replay recreates the exact same lease.
+type LeasePayload struct {
+	ID          string `json:"id"`
+	Deadline    int64  `json:"d"` // unix ms
+	MaxAttempts int    `json:"n"`
+	DeadLetter  string `json:"dl"`
+}
+
+// Lease takes the head of the queue at key and hides it from other consumers
+// until it is acked, nacked or opts.Visibility passes.
+func (s *Store) Lease(key string, opts LeaseOptions) (DataTypeValue.QueueLease, error) {
+	s.mu.Lock()
+	lease, err := s.lease(key, opts)
+	return lease, s.commit(err)
+}
+
+func (s *Store) lease(key string, opts LeaseOptions) (DataTypeValue.QueueLease, error) {
+	if s.readOnly {
+		return DataTypeValue.QueueLease{}, ErrReadOnly
+	}
+	if opts.Visibility <= 0 {
+		return DataTypeValue.QueueLease{}, errors.New("visibility timeout must be positive")
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.DeadLetter == "" {
+		opts.DeadLetter = key + ":dead"
+	}
+	if opts.DeadLetter == key {
+		return DataTypeValue.QueueLease{}, errors.New("dead-letter queue must differ from the queue")
+	}
+	s.runQueueTimers(time.Now())
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return DataTypeValue.QueueLease{}, err
+	}
+
+	val, err := s.checkType(key, domain.Queue)
+	if err != nil {
+		return DataTypeValue.QueueLease{}, err
+	}
+	if _, err := s.checkType(opts.DeadLetter, domain.Queue); err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return DataTypeValue.QueueLease{}, fmt.Errorf("dead-letter %w", err)
+	}
+	queueVal := val.(*DataTypeValue.QueueValue)
+	if len(queueVal.Data) == 0 {
+		return DataTypeValue.QueueLease{}, fmt.Errorf("queue is %w", ErrEmpty)
+	}
+
+	payload := LeasePayload{
+		ID:          newLeaseID(),
+		Deadline:    time.Now().Add(opts.Visibility).UnixMilli(),
+		MaxAttempts: opts.MaxAttempts,
+		DeadLetter:  opts.DeadLetter,
+	}
+	lease := s.applyLease(key, queueVal, payload)
+	s.trackKey(key)
+	s.notify("lease", key, domain.Queue)
+
+	if s.oplog {
+		data, _ := json.Marshal(payload)
+		if err := s.writeAOF("LEASE", key, "queue", string(data)); err != nil {
+			return *lease, err
+		}
+	}
+	logger.Debug("LEASE operation", "key", key, "id", lease.ID, "attempts", lease.Attempts)
+	return *lease, nil
+}
+
+// applyLease moves the head of queueVal into a lease, shared with AOF replay
+func (s *Store) applyLease(key string, queueVal *DataTypeValue.QueueValue, payload LeasePayload) *DataTypeValue.QueueLease {
+	item, attempts, _ := queueVal.Shift()
+	lease := &DataTypeValue.QueueLease{
+		ID:          payload.ID,
+		Value:       item,
+		Attempts:    attempts + 1,
+		Deadline:    time.UnixMilli(payload.Deadline),
+		MaxAttempts: payload.MaxAttempts,
+		DeadLetter:  payload.DeadLetter,
+	}
+	if queueVal.Leases == nil {
+		queueVal.Leases = make(map[string]*DataTypeValue.QueueLease)
+	}
+	queueVal.Leases[lease.ID] = lease
+	heap.Push(&s.queueTimers, queueTimer{at: lease.Deadline, key: key, lease: lease.ID})
+	return lease
+}
+
+// Ack deletes a leased item for good. Returns false if the lease is unknown.
+func (s *Store) Ack(key, id string) (bool, error) {
+	s.mu.Lock()
+	ok, err := s.ack(key, id)
+	return ok, s.commit(err)
+}
+
+func (s *Store) ack(key, id string) (bool, error) {
+	if s.readOnly {
+		return false, ErrReadOnly
+	}
+	s.runQueueTimers(time.Now())
+	queueVal, err := s.leasedQueue(key, id)
+	if err != nil {
+		return false, err
+	}
+
+	delete(queueVal.Leases, id)
+	s.trackKey(key)
+	s.notify("ack", key, domain.Queue)
+
+	if s.oplog {
+		if err := s.writeAOF("ACK", key, "queue", id); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// Nack gives a leased item back before its deadline, to the head of the queue or
+// to the dead-letter queue if it ran out of attempts. Returns false if the lease is unknown.
+func (s *Store) Nack(key, id string) (bool, error) {
+	s.mu.Lock()
+	ok, err := s.nack(key, id)
+	return ok, s.commit(err)
+}
+
+func (s *Store) nack(key, id string) (bool, error) {
+	if s.readOnly {
+		return false, ErrReadOnly
+	}
+	s.runQueueTimers(time.Now())
+	if _, err := s.leasedQueue(key, id); err != nil {
+		return false, err
+	}
+	return true, s.releaseLease(key, id)
+}
+
+// leasedQueue returns the queue at key if it holds lease id
+func (s *Store) leasedQueue(key, id string) (*DataTypeValue.QueueValue, error) {
+	s.expireIfNeeded(key)
+	s.own(key)
+	val, err := s.checkType(key, domain.Queue)
+	if err != nil {
+		if errors.Is(err, ErrKeyNotFound) {
+			return nil, ErrLeaseNotFound
+		}
+		return nil, err
+	}
+	queueVal := val.(*DataTypeValue.QueueValue)
+	if _, ok := queueVal.Leases[id]; !ok {
+		return nil, ErrLeaseNotFound
+	}
+	return queueVal, nil
+}
+
+// releaseLease puts a leased item back and logs it as a NACK, whether a client
+// or the deadline caused it. Requires the write lock.
+func (s *Store) releaseLease(key, id string) error {
+	if queueVal, ok := s.data[key].(*DataTypeValue.QueueValue); ok {
+		if lease, ok := queueVal.Leases[id]; ok {
+			s.expireIfNeeded(lease.DeadLetter)
+		}
+	}
+	target := s.applyRelease(key, id)
+	s.trackKey(key)
+	if target != key {
+		s.trackKey(target)
+		s.notify("nack", key, domain.Queue)
+		s.notify("enqueue", target, domain.Queue)
+		logger.Info("Leased item dead-lettered", "key", key, "id", id, "deadLetter", target)
+	} else {
+		s.notify("nack", key, domain.Queue)
+	}
+	s.signalReady(target)
+
+	if s.oplog {
+		return s.writeAOF("NACK", key, "queue", id)
+	}
+	return nil
+}
+
+// applyRelease removes lease id from the queue at key and puts its item back
+// at the head, or at the tail of the dead-letter queue once it has been
+// delivered MaxAttempts times. A dead-letter key that no longer holds a queue
+// keeps the item where it was. Returns the key the item went to; shared with
+// AOF replay.
+func (s *Store) applyRelease(key, id string) string {
+	s.own(key)
+	queueVal, ok := s.data[key].(*DataTypeValue.QueueValue)
+	if !ok {
+		return key
+	}
+	lease, ok := queueVal.Leases[id]
+	if !ok {
+		return key
+	}
+	delete(queueVal.Leases, id)
+
+	if lease.Attempts >= lease.MaxAttempts {
+		s.own(lease.DeadLetter)
+		dead, exists := s.data[lease.DeadLetter]
+		if !exists {
+			dead = &DataTypeValue.QueueValue{Data: make([]string, 0)}
+			s.data[lease.DeadLetter] = dead
+		}
+		if deadQueue, ok := dead.(*DataTypeValue.QueueValue); ok {
+			deadQueue.Append(lease.Value)
+			return lease.DeadLetter
+		}
+	}
+	queueVal.Unshift(lease.Value, lease.Attempts)
+	return key
+}
+
+// requeue puts value back at the head of the queue at key, creating it when
+// missing, and logs it as a REQUEUE
+func (s *Store) requeue(key, value string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return err
+	}
+
+	val, exists := s.data[key]
+	if !exists {
+		val = &DataTypeValue.QueueValue{Data: make([]string, 0)}
+		s.data[key] = val
+	}
+	queueVal, ok := val.(*DataTypeValue.QueueValue)
+	if !ok {
+		return fmt.Errorf("%w: expected queue", ErrWrongType)
+	}
+	queueVal.Unshift(value, 0)
+	s.trackKey(key)
+	s.notify("enqueue", key, domain.Queue)
+	s.signalReady(key)
+
+	if s.oplog {
+		return s.writeAOF("REQUEUE", key, "queue", value)
+	}
+	return nil
+}
+
+// SchedulePayload is the AOF record of a delayed item
+type SchedulePayload struct {
+	Value string `json:"v"`
+	At    int64  `json:"at"` // unix ms
+}
+
+// EnqueueAt adds value to the queue at key once at has passed. Dequeues do not
+// see it until then; a time that already passed enqueues it right away.
+func (s *Store) EnqueueAt(key, value string, at time.Time) error {
+	s.mu.Lock()
+	return s.commit(s.enqueueAt(key, value, at))
+}
+
+func (s *Store) enqueueAt(key, value string, at time.Time) error {
+	// deadlines are kept in milliseconds, the precision of the AOF record
+	at = time.UnixMilli(at.UnixMilli())
+	if !at.After(time.Now()) {
+		return s.enqueue(key, value)
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return err
+	}
+
+	val, exists := s.data[key]
+	if !exists {
+		val = &DataTypeValue.QueueValue{Data: make([]string, 0)}
+		s.data[key] = val
+	}
+	queueVal, ok := val.(*DataTypeValue.QueueValue)
+	if !ok {
+		return fmt.Errorf("%w: expected queue", ErrWrongType)
+	}
+	s.applySchedule(key, queueVal, value, at)
+	s.trackKey(key)
+	s.notify("schedule", key, domain.Queue)
+
+	if s.oplog {
+		data, _ := json.Marshal(SchedulePayload{Value: value, At: at.UnixMilli()})
+		if err := s.writeAOF("SCHEDULE", key, "queue", string(data)); err != nil {
+			return err
+		}
+	}
+	logger.Debug("SCHEDULE operation", "key", key, "at", at)
+	return nil
+}
+
+// applySchedule holds value back in queueVal, shared with AOF replay. A timer
+// is only needed when the item is the queue's next one due; otherwise the
+// timer of an earlier item covers it.
+func (s *Store) applySchedule(key string, queueVal *DataTypeValue.QueueValue, value string, at time.Time) {
+	queueVal.Schedule(value, at)
+	if next, _ := queueVal.NextDue(); next.Equal(at) {
+		heap.Push(&s.queueTimers, queueTimer{at: at, key: key})
+	}
+}
+
+// promoteDue moves the delayed items of the queue at key that are due by now
+// to its tail and logs a PROMOTE with the cutoff, which replay applies the same way.
+// Requires the write lock.
+func (s *Store) promoteDue(key string, queueVal *DataTypeValue.QueueValue, now time.Time) {
+	cutoff := time.UnixMilli(now.UnixMilli())
+	// a timer finding nothing due fired after an earlier one that already
+	// promoted its items and scheduled the next, so it must not add another
+	if queueVal.PromoteDue(cutoff) == 0 {
+		return
+	}
+	if next, ok := queueVal.NextDue(); ok {
+		heap.Push(&s.queueTimers, queueTimer{at: next, key: key})
+	}
+	s.trackKey(key)
+	s.notify("promote", key, domain.Queue)
+	s.signalReady(key)
+	logger.Debug("Delayed queue items promoted", "key", key)
+
+	if s.oplog {
+		if err := s.writeAOF("PROMOTE", key, "queue", strconv.FormatInt(cutoff.UnixMilli(), 10)); err != nil {
+			logger.Error("Failed to write PROMOTE to AOF", "key", key, "error", err)
+		}
+	}
+}
+
+// runQueueTimers releases the leases whose deadline has passed by now and
+// promotes the scheduled items that are due. Requires the write lock.
+func (s *Store) runQueueTimers(now time.Time) {
+	for len(s.queueTimers) > 0 && !s.queueTimers[0].at.After(now) {
+		timer := heap.Pop(&s.queueTimers).(queueTimer)
+		// timers of settled leases, promoted items or deleted queues are dropped here
+		s.own(timer.key)
+		queueVal, ok := s.data[timer.key].(*DataTypeValue.QueueValue)
+		if !ok {
+			continue
+		}
+		if timer.lease == "" {
+			s.promoteDue(timer.key, queueVal, now)
+			continue
+		}
+		if lease, ok := queueVal.Leases[timer.lease]; !ok || !lease.Deadline.Equal(timer.at) {
+			continue
+		}
+		if err := s.releaseLease(timer.key, timer.lease); err != nil {
+			logger.Error("Failed to write expired lease to AOF", "key", timer.key, "id", timer.lease, "error", err)
+		}
+	}
+}
+
+// rebuildQueueTimers schedules the leases and delayed items of every queue,
+// used after the whole keyspace was replaced by a snapshot or an AOF replay
+func (s *Store) rebuildQueueTimers() {
+	s.queueTimers = nil
+	for key := range s.data {
+		s.scheduleQueueTimers(key)
+	}
+}
+
+// scheduleQueueTimers adds timers for the leases and the next delayed item of
+// the queue at key, used when a queue is restored as a whole
+func (s *Store) scheduleQueueTimers(key string) {
+	queueVal, ok := s.data[key].(*DataTypeValue.QueueValue)
+	if !ok {
+		return
+	}
+	for id, lease := range queueVal.Leases {
+		heap.Push(&s.queueTimers, queueTimer{at: lease.Deadline, key: key, lease: id})
+	}
+	if at, ok := queueVal.NextDue(); ok {
+		heap.Push(&s.queueTimers, queueTimer{at: at, key: key})
+	}
+}
+
+func newLeaseID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// queueTimer is a lease deadline, or for an empty lease the time a delayed item
+// of the queue is due. Timers are not removed when a lease ends early or an item
+// is promoted by an earlier timer; runQueueTimers skips them once they come due.
+type queueTimer struct {
+	at    time.Time
+	key   string
+	lease string
+}
+
+type queueTimerHeap []queueTimer
+
+func (h queueTimerHeap) Len() int           { return len(h) }
+func (h queueTimerHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h queueTimerHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *queueTimerHeap) Push(x any)        { *h = append(*h, x.(queueTimer)) }
+func (h *queueTimerHeap) Pop() any {
+	old := *h
+	timer := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return timer
+}
+
+// replayLease, replayRelease, replaySchedule and replayPromote apply LEASE,
+// NACK, SCHEDULE and PROMOTE records
+func (s *Store) replayLease(key, value string) {
+	queueVal, ok := s.data[key].(*DataTypeValue.QueueValue)
+	if !ok || len(queueVal.Data) == 0 {
+		return
+	}
+	var payload LeasePayload
+	if err := json.Unmarshal([]byte(value), &payload); err != nil {
+		logger.Warn("Skipping malformed LEASE record", "key", key, "error", err)
+		return
+	}
+	s.applyLease(key, queueVal, payload)
+}
+
+func (s *Store) replayRelease(key, id string) {
+	if target := s.applyRelease(key, id); target != key {
+		s.trackKey(target)
+	}
+}
+
+func (s *Store) replaySchedule(key, value string) {
+	if _, exists := s.data[key]; !exists {
+		s.data[key] = &DataTypeValue.QueueValue{Data: make([]string, 0)}
+	}
+	queueVal, ok := s.data[key].(*DataTypeValue.QueueValue)
+	if !ok {
+		return
+	}
+	var payload SchedulePayload
+	if err := json.Unmarshal([]byte(value), &payload); err != nil {
+		logger.Warn("Skipping malformed SCHEDULE record", "key", key, "error", err)
+		return
+	}
+	s.applySchedule(key, queueVal, payload.Value, time.UnixMilli(payload.At))
+}
+
+func (s *Store) replayPromote(key, value string) {
+	queueVal, ok := s.data[key].(*DataTypeValue.QueueValue)
+	if !ok {
+		return
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if queueVal.PromoteDue(time.UnixMilli(ms)) == 0 {
+			return
+		}
+		if next, ok := queueVal.NextDue(); ok {
+			heap.Push(&s.queueTimers, queueTimer{at: next, key: key})
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"github.com/mrpurushotam/mini_db/internal/aof"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+)
+
+// Feed receives every operation the store logs, in the order they are applied;
+// replication.Backlog satisfies it. Transactions arrive as one call wrapped in
+// MULTI/EXEC markers. It is called with the write lock held and must not block.
+type Feed interface {
+	Append(ops ...aof.Operation)
+}
+
+// Consensus orders writes through a replicated log; raft.Node satisfies it.
+type Consensus interface {
+	// Propose appends a group of operations to the log and returns its index.
+	// It is called with the write lock held and must not block.
+	Propose(ops []aof.Operation) (uint64, error)
+	// Wait blocks until the entry at index is committed. It is called after
+	// the write lock is released.
+	Wait(index uint64) error
+}
+
+// SetFeed routes logged operations to f as well as the AOF; nil turns it off
+func (s *Store) SetFeed(f Feed) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.feed = f
+	s.updateOplog()
+}
+
+// SetConsensus proposes every write to c, and commands wait for it to be
+// committed before they return. Operations applied through Apply are not proposed.
+func (s *Store) SetConsensus(c Consensus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.consensus = c
+	s.updateOplog()
+}
+
+func (s *Store) updateOplog() {
+	s.oplog = s.enableAof || s.feed != nil || s.consensus != nil
+}
+
+// SetReadOnly makes every command that modifies the store fail with ErrReadOnly.
+// Operations applied through Apply are not affected.
+func (s *Store) SetReadOnly(readOnly bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readOnly = readOnly
+	if readOnly {
+		s.failBlocked(ErrReadOnly)
+	}
+}
+
+func (s *Store) ReadOnly() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readOnly
+}
+
+// Apply replays operations received from a replication leader as one atomic
+// group. They are logged to the local AOF and feed like the store's own writes.
+func (s *Store) Apply(ops []aof.Operation) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	s.mu.Lock()
+
+	for _, op := range ops {
+		s.applyOp(op)
+		s.trackKey(op.Key)
+	}
+
+	if s.feed != nil {
+		if len(ops) == 1 {
+			s.feed.Append(ops[0])
+		} else {
+			s.feed.Append(wrapTx(ops)...)
+		}
+	}
+
+	var err error
+	if s.enableAof {
+		var seq uint64
+		if len(ops) == 1 {
+			op := ops[0]
+			seq, err = s.aof.Write(op.Type, op.Key, op.ValueType, op.Value)
+		} else {
+			seq, err = s.aof.WriteBatch(ops)
+		}
+		if err == nil {
+			s.aofSeq = seq
+		}
+	}
+	logger.Debug("Applied replicated operations", "count", len(ops))
+	return s.commit(err)
+}
+
+// wrapTx surrounds the operations of a transaction with MULTI/EXEC markers
+func wrapTx(ops []aof.Operation) []aof.Operation {
+	group := make([]aof.Operation, 0, len(ops)+2)
+	group = append(group, aof.Operation{Type: "MULTI"})
+	group = append(group, ops...)
+	return append(group, aof.Operation{Type: "EXEC"})
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/aof"
+)
+
+// heldConsensus records proposed entries and keeps them uncommitted until release is closed
+type heldConsensus struct {
+	mu       sync.Mutex
+	entries  [][]aof.Operation
+	proposed chan struct{}
+	release  chan struct{}
+}
+
+func newHeldConsensus() *heldConsensus {
+	return &heldConsensus{proposed: make(chan struct{}, 16), release: make(chan struct{})}
+}
+
+func (c *heldConsensus) Propose(ops []aof.Operation) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, append([]aof.Operation(nil), ops...))
+	c.proposed <- struct{}{}
+	return uint64(len(c.entries)), nil
+}
+
+func (c *heldConsensus) Wait(index uint64) error {
+	<-c.release
+	return nil
+}
+
+func TestConsensusProposesOneEntryPerCommand(t *testing.T) {
+	s := NewStore()
+	c := newHeldConsensus()
+	close(c.release)
+	s.SetConsensus(c)
+
+	if err := s.SetWithTTL("k", "v", time.Minute); err != nil {
+		t.Fatalf("SetWithTTL: %v", err)
+	}
+	if len(c.entries) != 1 {
+		t.Fatalf("proposed %d entries, want 1", len(c.entries))
+	}
+	if ops := c.entries[0]; len(ops) != 2 || ops[0].Type != "SET" || ops[1].Type != "EXPIREAT" {
+		t.Fatalf("entry = %+v, want SET and EXPIREAT", ops)
+	}
+}
+
+// The writer's reply waits for the commit, but the store is not held meanwhile
+func TestConsensusReplyWaitsForCommitWithoutTheLock(t *testing.T) {
+	s := NewStore()
+	c := newHeldConsensus()
+	s.SetConsensus(c)
+
+	done := make(chan error, 1)
+	go func() { done <- s.Set("k", "v") }()
+	<-c.proposed
+
+	read := make(chan bool, 1)
+	go func() {
+		_, ok := s.Get("k")
+		read <- ok
+	}()
+	select {
+	case <-read:
+	case <-time.After(time.Second):
+		t.Fatal("read blocked while a write was being committed")
+	}
+	go func() { s.Set("other", "v") }()
+	select {
+	case <-c.proposed:
+	case <-time.After(time.Second):
+		t.Fatal("second write blocked while the first was being committed")
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Set returned %v before its entry was committed", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(c.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+}
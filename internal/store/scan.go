@@ -0,0 +1,156 @@
+package store
+
+import (
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/glob"
+	"github.com/mrpurushotam/mini_db/internal/slots"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+// DefaultScanCount is the page size hint used when a scan does not give one, as in redis
+const DefaultScanCount = 10
+
+type ScanOptions struct {
+	// Match is a glob pattern keys (or members and fields) must match, empty for all
+	Match string
+	// Count is a hint for the amount of work per call, see Scan
+	Count int
+	// Type restricts a keyspace scan to keys of one data type
+	Type domain.DataType
+}
+
+func (o ScanOptions) count() int {
+	if o.Count <= 0 {
+		return DefaultScanCount
+	}
+	return o.Count
+}
+
+const (
+	// ScanCursorBits is the width of keyspace cursors, leaving the bits above
+	// free for the proxy to tell its nodes apart
+	ScanCursorBits = 56
+	// a key's position is its hash slot (14 bits) followed by the top of its hash
+	keyHashBits = ScanCursorBits - 14
+)
+
+// keyPosition orders keys by hash slot, then by hash, the order Scan walks the
+// keyspace in. Keys follow the lifetime of their metadata in s.index, see
+// trackKey and removeKey.
+func keyPosition(key string) uint64 {
+	return uint64(slots.ForKey(key))<<keyHashBits | DataTypeValue.NameHash(key)>>(64-keyHashBits)
+}
+
+// Scan returns a page of keys and the cursor to continue from, 0 once the whole
+// keyspace has been walked. Keys are visited in the order of keyPosition and the
+// cursor is the position to resume at, so a key that exists for the whole
+// iteration is returned exactly once however the keyspace changes in between,
+// and a page costs O(log n + Count) even when many keys share a hash slot.
+// Count bounds the keys visited, not returned: Match and Type are applied
+// afterwards, so a page may be empty while the cursor is not yet 0.
+func (s *Store) Scan(cursor uint64, opts ScanOptions) ([]string, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.scanKeys(cursor, opts)
+}
+
+func (s *Store) scanKeys(cursor uint64, opts ScanOptions) ([]string, uint64) {
+	keys := make([]string, 0)
+	next := s.scan(cursor, opts, func(key string, _ domain.Value) {
+		keys = append(keys, key)
+	})
+	return keys, next
+}
+
+// ScanValues is Scan returning copies of the values as well
+func (s *Store) ScanValues(cursor uint64, opts ScanOptions) (map[string]domain.Value, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	values := make(map[string]domain.Value)
+	next := s.scan(cursor, opts, func(key string, val domain.Value) {
+		values[key] = val.Clone()
+	})
+	return values, next
+}
+
+func (s *Store) scan(cursor uint64, opts ScanOptions, fn func(key string, val domain.Value)) uint64 {
+	now := time.Now()
+	return s.index.Page(cursor, opts.count(), func(key string) {
+		if s.isExpired(key, now) || (opts.Match != "" && !glob.Match(opts.Match, key)) {
+			return
+		}
+		val := s.data[key]
+		if opts.Type != "" && val.Type() != opts.Type {
+			return
+		}
+		fn(key, val)
+	})
+}
+
+// KeysInSlots returns the live keys that hash to the given slots
+func (s *Store) KeysInSlots(slotList []int) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	keys := make([]string, 0)
+	for _, slot := range slotList {
+		s.index.Ascend(uint64(slot)<<keyHashBits, func(pos uint64, key string) bool {
+			if pos>>keyHashBits != uint64(slot) {
+				return false
+			}
+			if !s.isExpired(key, now) {
+				keys = append(keys, key)
+			}
+			return true
+		})
+	}
+	return keys
+}
+
+// SScan pages through the members of the set at key in the order of their hash.
+// The cursor is the hash to resume at, which gives the guarantees of Scan.
+func (s *Store) SScan(key string, cursor uint64, opts ScanOptions) ([]string, uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sScan(key, cursor, opts)
+}
+
+func (s *Store) sScan(key string, cursor uint64, opts ScanOptions) ([]string, uint64, error) {
+	val, err := s.checkType(key, domain.Set)
+	if err != nil {
+		return nil, 0, err
+	}
+	members := make([]string, 0)
+	next := val.(*DataTypeValue.SetValue).Scan(cursor, opts.count(), func(member string) {
+		if opts.Match == "" || glob.Match(opts.Match, member) {
+			members = append(members, member)
+		}
+	})
+	return members, next, nil
+}
+
+// HScan pages through the fields of the hashmap at key like SScan
+func (s *Store) HScan(key string, cursor uint64, opts ScanOptions) (map[string]string, uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hScan(key, cursor, opts)
+}
+
+func (s *Store) hScan(key string, cursor uint64, opts ScanOptions) (map[string]string, uint64, error) {
+	val, err := s.checkType(key, domain.Hashmap)
+	if err != nil {
+		return nil, 0, err
+	}
+	hashVal := val.(*DataTypeValue.HashmapValue)
+	page := make(map[string]string)
+	next := hashVal.Scan(cursor, opts.count(), time.Now(), func(field string) {
+		if opts.Match == "" || glob.Match(opts.Match, field) {
+			page[field] = hashVal.Data[field]
+		}
+	})
+	return page, next, nil
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Keys sharing a hash tag live in one slot; pages still hold Count keys
+func TestScanPagesAreBoundedWhenKeysShareASlot(t *testing.T) {
+	s := NewStore()
+	for i := range 1000 {
+		if err := s.Set(fmt.Sprintf("{user}:%d", i), "v"); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+
+	seen := make(map[string]int)
+	cursor, pages := uint64(0), 0
+	for {
+		keys, next := s.Scan(cursor, ScanOptions{Count: 10})
+		if len(keys) > 10 {
+			t.Fatalf("page of %d keys, want at most 10", len(keys))
+		}
+		for _, key := range keys {
+			seen[key]++
+		}
+		pages++
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	if len(seen) != 1000 {
+		t.Fatalf("scan returned %d keys, want 1000", len(seen))
+	}
+	for key, n := range seen {
+		if n != 1 {
+			t.Fatalf("%s returned %d times", key, n)
+		}
+	}
+	if pages < 100 {
+		t.Fatalf("scan took %d pages, want at least 100", pages)
+	}
+}
+
+// Members present for the whole iteration come back exactly once, whatever
+// is added and removed between pages
+func TestSScanReturnsStableMembersOnce(t *testing.T) {
+	s := NewStore()
+	for i := range 500 {
+		if _, err := s.SAdd("set", fmt.Sprintf("stable%d", i)); err != nil {
+			t.Fatalf("SAdd: %v", err)
+		}
+	}
+
+	seen := make(map[string]int)
+	cursor, page := uint64(0), 0
+	for {
+		members, next, err := s.SScan("set", cursor, ScanOptions{Count: 7})
+		if err != nil {
+			t.Fatalf("SScan: %v", err)
+		}
+		for _, member := range members {
+			seen[member]++
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+
+		page++
+		if _, err := s.SAdd("set", fmt.Sprintf("churn%d", page)); err != nil {
+			t.Fatalf("SAdd: %v", err)
+		}
+		if page > 2 {
+			if _, err := s.SPop("set", fmt.Sprintf("churn%d", page-2)); err != nil {
+				t.Fatalf("SPop: %v", err)
+			}
+		}
+	}
+	for i := range 500 {
+		if n := seen[fmt.Sprintf("stable%d", i)]; n != 1 {
+			t.Fatalf("stable%d returned %d times, want once", i, n)
+		}
+	}
+}
+
+func TestHScanAppliesMatch(t *testing.T) {
+	s := NewStore()
+	fields := make(map[string]string)
+	for i := range 50 {
+		fields[fmt.Sprintf("f%d", i)] = "v"
+	}
+	if _, err := s.HMSet("h", fields); err != nil {
+		t.Fatalf("HMSet: %v", err)
+	}
+
+	got := make(map[string]string)
+	cursor := uint64(0)
+	for {
+		page, next, err := s.HScan("h", cursor, ScanOptions{Match: "f1*", Count: 4})
+		if err != nil {
+			t.Fatalf("HScan: %v", err)
+		}
+		for field, v := range page {
+			got[field] = v
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	// f1 and f10 to f19
+	if len(got) != 11 {
+		t.Fatalf("HScan matched %d fields, want 11: %v", len(got), got)
+	}
+}
@@ -0,0 +1,335 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/mrpurushotam/mini_db/internal/aof"
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+// SetOp selects the set algebra operation of SInter, SUnion and SDiff and their STORE variants
+type SetOp int
+
+const (
+	SetInter SetOp = iota
+	SetUnion
+	SetDiff
+)
+
+func (op SetOp) String() string {
+	switch op {
+	case SetInter:
+		return "sinter"
+	case SetUnion:
+		return "sunion"
+	default:
+		return "sdiff"
+	}
+}
+
+// ===== SET ALGEBRA =====
+
+// SInter returns the members present in every set; a missing key is an empty set
+func (s *Store) SInter(keys ...string) ([]string, error) {
+	return s.setAlgebra(SetInter, keys)
+}
+
+// SUnion returns the members present in any of the sets
+func (s *Store) SUnion(keys ...string) ([]string, error) {
+	return s.setAlgebra(SetUnion, keys)
+}
+
+// SDiff returns the members of the first set that are in none of the others
+func (s *Store) SDiff(keys ...string) ([]string, error) {
+	return s.setAlgebra(SetDiff, keys)
+}
+
+func (s *Store) setAlgebra(op SetOp, keys []string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result, err := s.combineSets(op, keys)
+	if err != nil {
+		return nil, err
+	}
+	return setMembers(result), nil
+}
+
+// SetStore computes op over keys and stores the result at destination,
+// replacing whatever was there, and returns its size. An empty result deletes
+// destination. The AOF records the resulting set, not the operation.
+func (s *Store) SetStore(op SetOp, destination string, keys ...string) (int, error) {
+	s.mu.Lock()
+	n, err := s.setStore(op, destination, keys)
+	return n, s.commit(err)
+}
+
+func (s *Store) setStore(op SetOp, destination string, keys []string) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	for _, key := range keys {
+		s.expireIfNeeded(key)
+	}
+	s.expireIfNeeded(destination)
+	if err := s.ensureMemory(); err != nil {
+		return 0, err
+	}
+
+	result, err := s.combineSets(op, keys)
+	if err != nil {
+		return 0, err
+	}
+	if len(result) == 0 {
+		if _, exists := s.data[destination]; exists {
+			s.notify("del", destination, s.keyType(destination))
+			s.removeKey(destination)
+		}
+	} else {
+		setVal := DataTypeValue.NewSetValue()
+		for member := range result {
+			setVal.Add(member)
+		}
+		s.data[destination] = setVal
+		delete(s.expires, destination)
+		s.trackKey(destination)
+		s.notify(op.String()+"store", destination, domain.Set)
+	}
+
+	if s.oplog {
+		data, err := json.Marshal(setMembers(result))
+		if err != nil {
+			return len(result), err
+		}
+		if err := s.writeAOF("SSTORE", destination, "set", string(data)); err != nil {
+			return len(result), err
+		}
+	}
+	logger.Debug("Set store operation", "op", op, "destination", destination, "count", len(result))
+	return len(result), nil
+}
+
+// combineSets applies op to the sets at keys and returns a new set. Works under the read lock.
+func (s *Store) combineSets(op SetOp, keys []string) (map[string]struct{}, error) {
+	sets := make([]map[string]struct{}, len(keys))
+	for i, key := range keys {
+		val, err := s.checkType(key, domain.Set)
+		if errors.Is(err, ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = val.(*DataTypeValue.SetValue).Data
+	}
+
+	result := make(map[string]struct{})
+	if len(sets) == 0 {
+		return result, nil
+	}
+	switch op {
+	case SetUnion:
+		for _, set := range sets {
+			for member := range set {
+				result[member] = struct{}{}
+			}
+		}
+	case SetInter:
+		// walk the smallest set, every member of the result is in it
+		smallest := sets[0]
+		for _, set := range sets[1:] {
+			if len(set) < len(smallest) {
+				smallest = set
+			}
+		}
+	members:
+		for member := range smallest {
+			for _, set := range sets {
+				if _, ok := set[member]; !ok {
+					continue members
+				}
+			}
+			result[member] = struct{}{}
+		}
+	case SetDiff:
+	diff:
+		for member := range sets[0] {
+			for _, set := range sets[1:] {
+				if _, ok := set[member]; ok {
+					continue diff
+				}
+			}
+			result[member] = struct{}{}
+		}
+	}
+	return result, nil
+}
+
+func setMembers(set map[string]struct{}) []string {
+	members := make([]string, 0, len(set))
+	for member := range set {
+		members = append(members, member)
+	}
+	return members
+}
+
+// ===== SET QUERIES =====
+
+func (s *Store) SIsMember(key, member string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found, err := s.sMIsMember(key, member)
+	if err != nil {
+		return false, err
+	}
+	return found[0], nil
+}
+
+// SMIsMember reports for each member whether it is in the set; a missing key contains nothing
+func (s *Store) SMIsMember(key string, members ...string) ([]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sMIsMember(key, members...)
+}
+
+func (s *Store) sMIsMember(key string, members ...string) ([]bool, error) {
+	found := make([]bool, len(members))
+	val, err := s.checkType(key, domain.Set)
+	if errors.Is(err, ErrKeyNotFound) {
+		return found, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	setVal := val.(*DataTypeValue.SetValue)
+	for i, member := range members {
+		_, found[i] = setVal.Data[member]
+	}
+	return found, nil
+}
+
+func (s *Store) SCard(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sCard(key)
+}
+
+func (s *Store) sCard(key string) (int, error) {
+	val, err := s.checkType(key, domain.Set)
+	if err != nil {
+		return 0, err
+	}
+	return len(val.(*DataTypeValue.SetValue).Data), nil
+}
+
+// SRandMember returns up to count distinct random members, or for a negative
+// count exactly -count members that may repeat
+func (s *Store) SRandMember(key string, count int) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sRandMember(key, count)
+}
+
+func (s *Store) sRandMember(key string, count int) ([]string, error) {
+	val, err := s.checkType(key, domain.Set)
+	if err != nil {
+		return nil, err
+	}
+	members := setMembers(val.(*DataTypeValue.SetValue).Data)
+	if len(members) == 0 || count == 0 {
+		return []string{}, nil
+	}
+	if count < 0 {
+		picked := make([]string, -count)
+		for i := range picked {
+			picked[i] = members[rand.IntN(len(members))]
+		}
+		return picked, nil
+	}
+	if count >= len(members) {
+		return members, nil
+	}
+	// partial Fisher-Yates: the first count slots end up a uniform sample
+	for i := 0; i < count; i++ {
+		j := i + rand.IntN(len(members)-i)
+		members[i], members[j] = members[j], members[i]
+	}
+	return members[:count], nil
+}
+
+// SMove moves member from source to destination in one step and reports
+// whether it was in source
+func (s *Store) SMove(source, destination, member string) (bool, error) {
+	s.mu.Lock()
+	moved, err := s.sMove(source, destination, member)
+	return moved, s.commit(err)
+}
+
+func (s *Store) sMove(source, destination, member string) (bool, error) {
+	if s.readOnly {
+		return false, ErrReadOnly
+	}
+	s.expireIfNeeded(source)
+	s.own(source)
+	s.expireIfNeeded(destination)
+	s.own(destination)
+	if err := s.ensureMemory(); err != nil {
+		return false, err
+	}
+
+	val, err := s.checkType(source, domain.Set)
+	if err != nil {
+		return false, err
+	}
+	srcVal := val.(*DataTypeValue.SetValue)
+	var dstVal *DataTypeValue.SetValue
+	if val, exists := s.data[destination]; exists {
+		var ok bool
+		if dstVal, ok = val.(*DataTypeValue.SetValue); !ok {
+			return false, fmt.Errorf("%w: expected set", ErrWrongType)
+		}
+	}
+	if _, ok := srcVal.Data[member]; !ok {
+		return false, nil
+	}
+	if source == destination {
+		return true, nil
+	}
+	if dstVal == nil {
+		dstVal = DataTypeValue.NewSetValue()
+		s.data[destination] = dstVal
+	}
+
+	srcVal.Remove(member)
+	dstVal.Add(member)
+	s.notify("srem", source, domain.Set)
+	emptied := len(srcVal.Data) == 0
+	if emptied {
+		// like the other removals, taking the last member deletes the key
+		s.notify("del", source, domain.Set)
+		s.removeKey(source)
+	} else {
+		s.trackKey(source)
+	}
+	s.trackKey(destination)
+	s.notify("sadd", destination, domain.Set)
+
+	if s.oplog {
+		// logged as one group, so a torn write cannot lose the member
+		ops := []aof.Operation{
+			{Type: "SPOP", Key: source, ValueType: "set", Value: member},
+			{Type: "SADD", Key: destination, ValueType: "set", Value: member},
+		}
+		if emptied {
+			ops = append(ops, aof.Operation{Type: "DELETE", Key: source})
+		}
+		if err := s.writeAOFBatch(ops); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
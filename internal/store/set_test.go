@@ -0,0 +1,41 @@
+package store
+
+import "testing"
+
+func TestSMoveLastMemberDeletesSource(t *testing.T) {
+	s, path := newAOFStore(t)
+	if _, err := s.SAdd("src", "a"); err != nil {
+		t.Fatalf("SAdd: %v", err)
+	}
+	moved, err := s.SMove("src", "dst", "a")
+	if err != nil || !moved {
+		t.Fatalf("SMove = %v, %v; want true, nil", moved, err)
+	}
+	if _, ok := s.Type("src"); ok {
+		t.Fatal("source still exists after its last member moved")
+	}
+
+	s = openAOFStore(t, path)
+	if _, ok := s.Type("src"); ok {
+		t.Fatal("source exists after replay")
+	}
+	if members, err := s.SMembers("dst"); err != nil || len(members) != 1 {
+		t.Fatalf("SMembers(dst) = %v, %v; want [a]", members, err)
+	}
+}
+
+func TestSPopLastMemberDeletesKey(t *testing.T) {
+	s, path := newAOFStore(t)
+	if _, err := s.SAdd("tags", "a", "b"); err != nil {
+		t.Fatalf("SAdd: %v", err)
+	}
+	if n, err := s.SPop("tags", "a", "b"); err != nil || n != 2 {
+		t.Fatalf("SPop = %d, %v; want 2, nil", n, err)
+	}
+	if _, ok := s.Type("tags"); ok {
+		t.Fatal("emptied set still exists")
+	}
+	if _, ok := openAOFStore(t, path).Type("tags"); ok {
+		t.Fatal("emptied set exists after replay")
+	}
+}
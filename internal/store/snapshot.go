@@ -0,0 +1,41 @@
+package store
+
+import (
+	"io"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/rdb"
+)
+
+// Dump writes a binary snapshot of the live keys and their deadlines to w.
+// Only the copy is taken under the lock, encoding runs without it.
+func (s *Store) Dump(w io.Writer) error {
+	data, expires := s.Fork(func() {})
+	return rdb.Write(w, data, expires)
+}
+
+// Load replaces the contents of the store with the binary snapshot read from r.
+// Nothing is logged to the AOF, the caller decides how the new state is persisted.
+func (s *Store) Load(r io.Reader) error {
+	data, expires, err := rdb.Read(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restore(data, expires)
+	s.rebuildMemory()
+	s.rebuildQueueTimers()
+	s.rebuildFieldTimers()
+	logger.Info("Snapshot loaded", "keys", len(data))
+	return nil
+}
+
+// restore installs a decoded snapshot. Requires the write lock; the caller rebuilds the memory accounting.
+func (s *Store) restore(data map[string]domain.Value, expires map[string]time.Time) {
+	s.data = data
+	s.expires = expires
+}
@@ -0,0 +1,254 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+var (
+	ErrMemberNotFound = errors.New("member not found")
+	ErrNotFloat       = errors.New("value is not a valid float")
+)
+
+// ZAddPayload is the AOF value of a ZADD record
+type ZAddPayload struct {
+	Member string  `json:"m"`
+	Score  float64 `json:"s"`
+}
+
+// ===== SORTED SET OPERATIONS =====
+
+// getOrCreateSortedSet returns the sorted set at key, creating it if missing. Requires the write lock.
+func (s *Store) getOrCreateSortedSet(key string) (*DataTypeValue.SortedSetValue, error) {
+	val, exists := s.data[key]
+	if !exists {
+		zsetVal := DataTypeValue.NewSortedSetValue()
+		s.data[key] = zsetVal
+		return zsetVal, nil
+	}
+	zsetVal, ok := val.(*DataTypeValue.SortedSetValue)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected sortedset", ErrWrongType)
+	}
+	return zsetVal, nil
+}
+
+func (s *Store) writeZAdd(key, member string, score float64) error {
+	data, err := json.Marshal(ZAddPayload{Member: member, Score: score})
+	if err != nil {
+		return err
+	}
+	return s.writeAOF("ZADD", key, "sortedset", string(data))
+}
+
+// ZAdd sets the scores of members and returns how many were newly added
+func (s *Store) ZAdd(key string, members ...DataTypeValue.ZMember) (int, error) {
+	s.mu.Lock()
+	added, err := s.zAdd(key, members...)
+	return added, s.commit(err)
+}
+
+func (s *Store) zAdd(key string, members ...DataTypeValue.ZMember) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	for _, m := range members {
+		if math.IsNaN(m.Score) || math.IsInf(m.Score, 0) {
+			return 0, ErrNotFloat
+		}
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return 0, err
+	}
+
+	zsetVal, err := s.getOrCreateSortedSet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, m := range members {
+		if zsetVal.Add(m.Member, m.Score) {
+			added++
+		}
+	}
+	s.trackKey(key)
+	s.notify("zadd", key, domain.SortedSet)
+
+	for _, m := range members {
+		if err := s.writeZAdd(key, m.Member, m.Score); err != nil {
+			return added, err
+		}
+	}
+	logger.Debug("ZADD operation", "key", key, "count", len(members), "added", added)
+	return added, nil
+}
+
+// ZIncrBy adds delta to the score of member (starting from 0) and returns the new score.
+// The AOF records the resulting score so replay is idempotent.
+func (s *Store) ZIncrBy(key, member string, delta float64) (float64, error) {
+	s.mu.Lock()
+	score, err := s.zIncrBy(key, member, delta)
+	return score, s.commit(err)
+}
+
+func (s *Store) zIncrBy(key, member string, delta float64) (float64, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return 0, err
+	}
+
+	zsetVal, err := s.getOrCreateSortedSet(key)
+	if err != nil {
+		return 0, err
+	}
+
+	current, _ := zsetVal.Score(member)
+	score := current + delta
+	if math.IsNaN(score) || math.IsInf(score, 0) {
+		return 0, errors.New("resulting score is not a number (NaN)")
+	}
+	zsetVal.Add(member, score)
+	s.trackKey(key)
+	s.notify("zincrby", key, domain.SortedSet)
+
+	if err := s.writeZAdd(key, member, score); err != nil {
+		return score, err
+	}
+	return score, nil
+}
+
+// ZRem removes members and returns how many existed; removing the last member deletes the key
+func (s *Store) ZRem(key string, members ...string) (int, error) {
+	s.mu.Lock()
+	removed, err := s.zRem(key, members...)
+	return removed, s.commit(err)
+}
+
+func (s *Store) zRem(key string, members ...string) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+
+	val, err := s.checkType(key, domain.SortedSet)
+	if err != nil {
+		return 0, err
+	}
+
+	zsetVal := val.(*DataTypeValue.SortedSetValue)
+	removed := 0
+	for _, member := range members {
+		if zsetVal.Remove(member) {
+			removed++
+			if err := s.writeAOF("ZREM", key, "sortedset", member); err != nil {
+				return removed, err
+			}
+		}
+	}
+	if removed > 0 {
+		s.notify("zrem", key, domain.SortedSet)
+	}
+	// an empty sorted set is removed like in redis
+	if zsetVal.Len() == 0 {
+		s.removeKey(key)
+	} else {
+		s.trackKey(key)
+	}
+	return removed, nil
+}
+
+func (s *Store) ZScore(key, member string) (float64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.zScore(key, member)
+}
+
+func (s *Store) zScore(key, member string) (float64, error) {
+	val, err := s.checkType(key, domain.SortedSet)
+	if err != nil {
+		return 0, err
+	}
+	score, ok := val.(*DataTypeValue.SortedSetValue).Score(member)
+	if !ok {
+		return 0, ErrMemberNotFound
+	}
+	return score, nil
+}
+
+func (s *Store) ZCard(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.zCard(key)
+}
+
+func (s *Store) zCard(key string) (int, error) {
+	val, err := s.checkType(key, domain.SortedSet)
+	if err != nil {
+		return 0, err
+	}
+	return val.(*DataTypeValue.SortedSetValue).Len(), nil
+}
+
+// ZRank returns the 0-based rank of member, from the highest score when reverse is set
+func (s *Store) ZRank(key, member string, reverse bool) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.zRank(key, member, reverse)
+}
+
+func (s *Store) zRank(key, member string, reverse bool) (int, error) {
+	val, err := s.checkType(key, domain.SortedSet)
+	if err != nil {
+		return 0, err
+	}
+	rank, ok := val.(*DataTypeValue.SortedSetValue).Rank(member, reverse)
+	if !ok {
+		return 0, ErrMemberNotFound
+	}
+	return rank, nil
+}
+
+// ZRange returns members by rank, with redis index semantics for start and stop
+func (s *Store) ZRange(key string, start, stop int, reverse bool) ([]DataTypeValue.ZMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.zRange(key, start, stop, reverse)
+}
+
+func (s *Store) zRange(key string, start, stop int, reverse bool) ([]DataTypeValue.ZMember, error) {
+	val, err := s.checkType(key, domain.SortedSet)
+	if err != nil {
+		return nil, err
+	}
+	return val.(*DataTypeValue.SortedSetValue).RangeByRank(start, stop, reverse), nil
+}
+
+// ZRangeByScore returns members with scores in [min, max], skipping offset and returning
+// at most limit results (negative limit = all). Reverse walks from max to min.
+func (s *Store) ZRangeByScore(key string, min, max DataTypeValue.ScoreBound, offset, limit int, reverse bool) ([]DataTypeValue.ZMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.zRangeByScore(key, min, max, offset, limit, reverse)
+}
+
+func (s *Store) zRangeByScore(key string, min, max DataTypeValue.ScoreBound, offset, limit int, reverse bool) ([]DataTypeValue.ZMember, error) {
+	val, err := s.checkType(key, domain.SortedSet)
+	if err != nil {
+		return nil, err
+	}
+	return val.(*DataTypeValue.SortedSetValue).RangeByScore(min, max, offset, limit, reverse), nil
+}
@@ -1,27 +1,102 @@
 package store
 
 import (
+	"container/heap"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/mrpurushotam/mini_db/internal/aof"
 	"github.com/mrpurushotam/mini_db/internal/domain"
 	"github.com/mrpurushotam/mini_db/internal/logger"
+	"github.com/mrpurushotam/mini_db/internal/rdb"
 	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
 )
 
+var (
+	ErrKeyNotFound   = errors.New("key not found")
+	ErrFieldNotFound = errors.New("field not found")
+	ErrWrongType     = errors.New("wrong type")
+	ErrEmpty         = errors.New("empty")
+	ErrOutOfMemory   = errors.New("OOM command not allowed when used memory > 'maxmemory'")
+	ErrReadOnly      = errors.New("can't write against a read only replica")
+)
+
 type Store struct {
 	mu        sync.RWMutex
 	data      map[string]domain.Value
+	expires   map[string]time.Time
+	meta      map[string]*keyMeta
+	index     *DataTypeValue.ScanIndex
 	aof       *aof.AOF
 	enableAof bool
+	// oplog is set when operations are logged anywhere: the AOF, the replication feed or consensus
+	oplog     bool
+	feed      Feed
+	consensus Consensus
+	// readOnly rejects commands from clients, set on replication followers and cluster nodes that do not lead
+	readOnly bool
+	// txOps buffers AOF records while a transaction runs so they are written as one group
+	txOps []aof.Operation
+	// writeSeq is bumped on every key modification and backs WATCH versions
+	writeSeq uint64
+	// removed holds the writeSeq of each recent key removal, the WATCH version of
+	// that key while it is missing; tombstones lists them oldest first, see markRemoved
+	removed    map[string]uint64
+	tombstones []tombstone
+	// removedSeq is the WATCH version of missing keys without a tombstone: the
+	// latest removal whose tombstone was dropped
+	removedSeq uint64
+	// aofSeq is the last AOF record written by the current lock holder, see commit
+	aofSeq uint64
+	// proposal collects the operations of the current lock holder in cluster mode;
+	// commit proposes them as a single log entry
+	proposal []aof.Operation
+
+	usedMemory  int64
+	maxMemory   int64
+	policy      EvictionPolicy
+	evictedKeys int64
+	expiredKeys int64
+
+	notifier Notifier
+	// events collects the keyspace events of the current lock holder; commit
+	// hands them to publisher, which publishes them without the lock
+	events    []Event
+	publisher *eventQueue
+
+	// blocked holds the clients parked in BlockingPop per key, oldest first
+	blocked map[string][]*waiter
+	// released holds the slots handed to another node, whose blocking pops fail with ErrMoved
+	released map[int]bool
+	// ready lists keys pushed to by the current lock holder that have waiters, see commit
+	ready []string
+	// queueTimers orders lease deadlines and delayed queue items, see runQueueTimers
+	queueTimers queueTimerHeap
+	// fieldTimers orders the deadlines of hashmap fields, see runFieldTimers
+	fieldTimers fieldTimerHeap
+	// forks counts the calls to Fork; values that keys last owned at a lower
+	// count may be shared with a fork, see own
+	forks atomic.Uint64
 }
 
 func NewStore() *Store {
 	return &Store{
-		data: make(map[string]domain.Value),
+		data:     make(map[string]domain.Value),
+		expires:  make(map[string]time.Time),
+		meta:     make(map[string]*keyMeta),
+		removed:  make(map[string]uint64),
+		policy:   NoEviction,
+		blocked:  make(map[string][]*waiter),
+		released: make(map[int]bool),
+		index:    DataTypeValue.NewScanIndex(),
 	}
 }
 
@@ -32,42 +107,181 @@ func (s *Store) EnableAOF(aofInstance *aof.AOF) {
 	} else {
 		s.enableAof = false
 	}
+	s.updateOplog()
+}
+
+// AOFEnabled reports whether changes are persisted to an AOF
+func (s *Store) AOFEnabled() bool {
+	return s.enableAof
+}
+
+// writeAOF logs an operation to the AOF and the replication feed, or buffers it
+// when called inside a transaction
+func (s *Store) writeAOF(operation, key, valueType, value string) error {
+	if !s.oplog {
+		return nil
+	}
+	op := aof.Operation{Type: operation, Key: key, ValueType: valueType, Value: value}
+	if s.txOps != nil {
+		s.txOps = append(s.txOps, op)
+		return nil
+	}
+	if s.feed != nil {
+		s.feed.Append(op)
+	}
+	if s.consensus != nil {
+		s.proposal = append(s.proposal, op)
+	}
+	if !s.enableAof {
+		return nil
+	}
+	seq, err := s.aof.Write(operation, key, valueType, value)
+	if err != nil {
+		return err
+	}
+	s.aofSeq = seq
+	return nil
+}
+
+// writeAOFBatch logs ops as one MULTI/EXEC group, so they are replayed all or not at all
+func (s *Store) writeAOFBatch(ops []aof.Operation) error {
+	if !s.oplog || len(ops) == 0 {
+		return nil
+	}
+	if s.txOps != nil {
+		s.txOps = append(s.txOps, ops...)
+		return nil
+	}
+	if s.feed != nil {
+		s.feed.Append(wrapTx(ops)...)
+	}
+	if s.consensus != nil {
+		s.proposal = append(s.proposal, ops...)
+	}
+	if !s.enableAof {
+		return nil
+	}
+	seq, err := s.aof.WriteBatch(ops)
+	if err != nil {
+		return err
+	}
+	if seq > 0 {
+		s.aofSeq = seq
+	}
+	return nil
+}
+
+// commit releases the write lock taken by a mutating command and then waits until
+// the AOF records it wrote are durable under the appendfsync policy. Waiting after
+// the unlock is what lets concurrent writers share a single fsync. Elements pushed
+// to keys that clients are blocked on are taken for them as part of the same write.
+//
+// In cluster mode the operations of the command are proposed as one log entry
+// while the lock is held, which keeps entries in the order the writes were made,
+// and the entry is waited on after the unlock, the same way the AOF is. The reply
+// to the writer, and the elements handed to blocked clients, wait for the commit;
+// other commands do not, so a leader serves reads of writes still being agreed on.
+func (s *Store) commit(err error) error {
+	var served []delivery
+	if len(s.ready) > 0 {
+		served = s.serveBlocked()
+	}
+	index := uint64(0)
+	var logErr error
+	if len(s.proposal) > 0 {
+		index, logErr = s.consensus.Propose(s.proposal)
+		s.proposal = nil
+	}
+	seq := s.aofSeq
+	s.aofSeq = 0
+	if len(s.events) > 0 {
+		// pushed before unlocking, so concurrent writers' events stay in order
+		s.publisher.push(s.events)
+		s.events = nil
+	}
+	s.mu.Unlock()
+
+	if logErr == nil && index > 0 {
+		logErr = s.consensus.Wait(index)
+	}
+	if err == nil {
+		err = logErr
+	}
+	for _, d := range served {
+		d.res.aofSeq = seq
+		if d.res.err == nil {
+			d.res.err = logErr
+		}
+		d.w.ch <- d.res
+	}
+
+	if seq == 0 {
+		return err
+	}
+	if syncErr := s.aof.Durable(seq); syncErr != nil && err == nil {
+		return syncErr
+	}
+	return err
 }
 
 func (s *Store) checkType(key string, expectedType domain.DataType) (domain.Value, error) {
-	val, exists := s.data[key]
+	val, exists := s.lookup(key)
 	if !exists {
-		return nil, fmt.Errorf("key not found")
+		return nil, ErrKeyNotFound
 	}
 	if val.Type() != expectedType {
-		return nil, fmt.Errorf("wrong type: expected %s, got %s", expectedType, val.Type())
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrWrongType, expectedType, val.Type())
 	}
 	return val, nil
 }
 
 // -- String Operations --
 func (s *Store) Set(key, value string) error {
+	return s.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL stores a string value; a positive ttl attaches an expiry,
+// otherwise any existing expiry on the key is cleared.
+func (s *Store) SetWithTTL(key, value string, ttl time.Duration) error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	return s.commit(s.setWithTTL(key, value, ttl))
+}
+
+func (s *Store) setWithTTL(key, value string, ttl time.Duration) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if err := s.ensureMemory(); err != nil {
+		return err
+	}
 
-	stringValue := &DataTypeValue.StringValue{Data: value}
+	stringValue := &DataTypeValue.StringValue{Data: []byte(value)}
 	s.data[key] = stringValue
+	delete(s.expires, key)
+	s.trackKey(key)
+	s.notify("set", key, domain.String)
 
-	if s.enableAof {
-		serialized := stringValue.Serialize()
-		if err := s.aof.Write("SET", key, "string", string(serialized)); err != nil {
+	if err := s.writeStringAOF(key, string(stringValue.Serialize())); err != nil {
+		return err
+	}
+	if ttl > 0 {
+		if err := s.setExpiry(key, time.Now().Add(ttl)); err != nil {
 			return err
 		}
+		s.notify("expire", key, domain.String)
 	}
-	logger.Debug("Set operation", "key", key, "Value", value)
+	logger.Debug("Set operation", "key", key, "Value", value, "ttl", ttl)
 	return nil
 }
 
 func (s *Store) Get(key string) (string, bool) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.get(key)
+}
 
-	value, exists := s.data[key]
+func (s *Store) get(key string) (string, bool) {
+	value, exists := s.lookup(key)
 	if !exists {
 		return "", false
 	}
@@ -77,48 +291,69 @@ func (s *Store) Get(key string) (string, bool) {
 		return "", false
 	}
 	logger.Debug("Get operation", "key", key, "exists", exists)
-	return stringValue.Data, exists
+	return stringValue.String(), exists
 }
 
 // -- Set Operations --
 
-func (s *Store) SAdd(key string, members ...string) error {
+// SAdd adds members to the set at key and returns how many were not already present
+func (s *Store) SAdd(key string, members ...string) (int, error) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	added, err := s.sAdd(key, members...)
+	return added, s.commit(err)
+}
+
+func (s *Store) sAdd(key string, members ...string) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return 0, err
+	}
 
 	val, exists := s.data[key]
 	var setVal *DataTypeValue.SetValue
 
 	if !exists {
-		setVal = &DataTypeValue.SetValue{Data: make(map[string]struct{})}
+		setVal = DataTypeValue.NewSetValue()
 		s.data[key] = setVal
 	} else {
 		var ok bool
 		setVal, ok = val.(*DataTypeValue.SetValue)
 		if !ok {
-			return fmt.Errorf("wrong type: expected set")
+			return 0, fmt.Errorf("%w: expected set", ErrWrongType)
 		}
 	}
 
+	added := 0
 	for _, member := range members {
-		setVal.Data[member] = struct{}{}
+		if setVal.Add(member) {
+			added++
+		}
 	}
+	s.trackKey(key)
+	s.notify("sadd", key, domain.Set)
 
-	if s.enableAof {
+	if s.oplog {
 		for _, member := range members {
-			if err := s.aof.Write("SADD", key, "set", member); err != nil {
-				return err
+			if err := s.writeAOF("SADD", key, "set", member); err != nil {
+				return added, err
 			}
 		}
 	}
 	logger.Debug("SADD operation", "key", key, "valueType", "set", "members", members)
-	return nil
+	return added, nil
 }
 
 func (s *Store) SMembers(key string) ([]string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.sMembers(key)
+}
 
+func (s *Store) sMembers(key string) ([]string, error) {
 	val, err := s.checkType(key, domain.Set)
 	if err != nil {
 		return nil, err
@@ -136,7 +371,16 @@ func (s *Store) SMembers(key string) ([]string, error) {
 
 func (s *Store) SPop(key string, members ...string) (int, error) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	removed, err := s.sPop(key, members...)
+	return removed, s.commit(err)
+}
+
+func (s *Store) sPop(key string, members ...string) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
 
 	val, err := s.checkType(key, domain.Set)
 	if err != nil {
@@ -147,15 +391,30 @@ func (s *Store) SPop(key string, members ...string) (int, error) {
 	removed := 0
 
 	for _, member := range members {
-		if _, exists := setVal.Data[member]; exists {
-			delete(setVal.Data, member)
+		if setVal.Remove(member) {
 			removed++
 		}
 	}
+	if removed == 0 {
+		return 0, nil
+	}
+	s.notify("srem", key, domain.Set)
+	emptied := len(setVal.Data) == 0
+	if emptied {
+		s.notify("del", key, domain.Set)
+		s.removeKey(key)
+	} else {
+		s.trackKey(key)
+	}
 
-	if s.enableAof && removed > 0 {
+	if s.oplog {
 		for _, member := range members {
-			if err := s.aof.Write("SPOP", key, "set", member); err != nil {
+			if err := s.writeAOF("SPOP", key, "set", member); err != nil {
+				return removed, err
+			}
+		}
+		if emptied {
+			if err := s.writeAOF("DELETE", key, "", ""); err != nil {
 				return removed, err
 			}
 		}
@@ -165,9 +424,23 @@ func (s *Store) SPop(key string, members ...string) (int, error) {
 
 // -- List Operations --
 
-func (s *Store) LPush(key string, values ...string) error {
+// LPush adds values to the head of the list at key, creating it when missing, and
+// returns the length of the list right after the push, before waiting pops are served
+func (s *Store) LPush(key string, values ...string) (int, error) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	n, err := s.lPush(key, values...)
+	return n, s.commit(err)
+}
+
+func (s *Store) lPush(key string, values ...string) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return 0, err
+	}
 
 	val, exists := s.data[key]
 	var listVal *DataTypeValue.ListValue
@@ -179,25 +452,43 @@ func (s *Store) LPush(key string, values ...string) error {
 		var ok bool
 		listVal, ok = val.(*DataTypeValue.ListValue)
 		if !ok {
-			return fmt.Errorf("wrong type: expected list")
+			return 0, fmt.Errorf("%w: expected list", ErrWrongType)
 		}
 	}
 	listVal.Data = append(values, listVal.Data...)
-
-	if s.enableAof {
-		// LPUSH AOF command should write each value pushed
-		for _, v := range values {
-			if err := s.aof.Write("LPUSH", key, "list", v); err != nil {
-				return err
+	s.trackKey(key)
+	s.notify("lpush", key, domain.List)
+	s.signalReady(key)
+
+	if s.oplog {
+		// each record is prepended on replay, so the last value goes first
+		// for the block to end up in the order it has now
+		for i := len(values) - 1; i >= 0; i-- {
+			if err := s.writeAOF("LPUSH", key, "list", values[i]); err != nil {
+				return len(listVal.Data), err
 			}
 		}
 	}
-	return nil
+	return len(listVal.Data), nil
 }
 
-func (s *Store) RPush(key string, values ...string) error {
+// RPush adds values to the tail of the list at key, creating it when missing, and
+// returns the length of the list right after the push, before waiting pops are served
+func (s *Store) RPush(key string, values ...string) (int, error) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	n, err := s.rPush(key, values...)
+	return n, s.commit(err)
+}
+
+func (s *Store) rPush(key string, values ...string) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return 0, err
+	}
 
 	val, exists := s.data[key]
 	var listVal *DataTypeValue.ListValue
@@ -210,25 +501,33 @@ func (s *Store) RPush(key string, values ...string) error {
 		listVal, ok = val.(*DataTypeValue.ListValue)
 
 		if !ok {
-			return fmt.Errorf("wrong type: expected list")
+			return 0, fmt.Errorf("%w: expected list", ErrWrongType)
 		}
 	}
 	listVal.Data = append(listVal.Data, values...)
+	s.trackKey(key)
+	s.notify("rpush", key, domain.List)
+	s.signalReady(key)
 
-	if s.enableAof {
+	if s.oplog {
 		for _, v := range values {
-			if err := s.aof.Write("RPUSH", key, "list", v); err != nil {
-				return err
+			if err := s.writeAOF("RPUSH", key, "list", v); err != nil {
+				return len(listVal.Data), err
 			}
 		}
 	}
-	return nil
+	return len(listVal.Data), nil
 }
 
 func (s *Store) LRange(key string, start, stop int) ([]string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	items, err := s.lRange(key, start, stop)
+	// copy, the list can be edited in place once the lock is released
+	return slices.Clone(items), err
+}
 
+func (s *Store) lRange(key string, start, stop int) ([]string, error) {
 	val, err := s.checkType(key, domain.List)
 	if err != nil {
 		return nil, err
@@ -256,11 +555,75 @@ func (s *Store) LRange(key string, start, stop int) ([]string, error) {
 	return listVal.Data[start : stop+1], nil
 }
 
+// lPop and rPop remove an element from either end of the list at key, removing
+// the key once the list is empty. Used by the blocking pops.
+func (s *Store) lPop(key string) (string, error) {
+	return s.listPop(key, true)
+}
+
+func (s *Store) rPop(key string) (string, error) {
+	return s.listPop(key, false)
+}
+
+func (s *Store) listPop(key string, left bool) (string, error) {
+	if s.readOnly {
+		return "", ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+
+	val, err := s.checkType(key, domain.List)
+	if err != nil {
+		return "", err
+	}
+	listVal := val.(*DataTypeValue.ListValue)
+	if len(listVal.Data) == 0 {
+		return "", fmt.Errorf("list is %w", ErrEmpty)
+	}
+
+	op := "LPOP"
+	var value string
+	if left {
+		value = listVal.Data[0]
+		listVal.Data = listVal.Data[1:]
+	} else {
+		op = "RPOP"
+		value = listVal.Data[len(listVal.Data)-1]
+		listVal.Data = listVal.Data[:len(listVal.Data)-1]
+	}
+	s.notify(strings.ToLower(op), key, domain.List)
+	if len(listVal.Data) == 0 {
+		s.notify("del", key, domain.List)
+		s.removeKey(key)
+	} else {
+		s.trackKey(key)
+	}
+
+	if s.oplog {
+		// like DEQUEUE, only the operation is recorded, not the popped value
+		if err := s.writeAOF(op, key, "list", ""); err != nil {
+			return value, err
+		}
+	}
+	return value, nil
+}
+
 //--Queue Operations--
 
 func (s *Store) Enqueue(key, value string) error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	return s.commit(s.enqueue(key, value))
+}
+
+func (s *Store) enqueue(key, value string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return err
+	}
 
 	val, exists := s.data[key]
 	var queueVal *DataTypeValue.QueueValue
@@ -272,14 +635,17 @@ func (s *Store) Enqueue(key, value string) error {
 		var ok bool
 		queueVal, ok = val.(*DataTypeValue.QueueValue)
 		if !ok {
-			return fmt.Errorf("wrong type: expected queue")
+			return fmt.Errorf("%w: expected queue", ErrWrongType)
 		}
 	}
 
-	queueVal.Data = append(queueVal.Data, value)
+	queueVal.Append(value)
+	s.trackKey(key)
+	s.notify("enqueue", key, domain.Queue)
+	s.signalReady(key)
 
-	if s.enableAof {
-		if err := s.aof.Write("ENQUEUE", key, "queue", value); err != nil {
+	if s.oplog {
+		if err := s.writeAOF("ENQUEUE", key, "queue", value); err != nil {
 			return err
 		}
 	}
@@ -288,7 +654,17 @@ func (s *Store) Enqueue(key, value string) error {
 
 func (s *Store) Dequeue(key string) (string, error) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	value, err := s.dequeue(key)
+	return value, s.commit(err)
+}
+
+func (s *Store) dequeue(key string) (string, error) {
+	if s.readOnly {
+		return "", ErrReadOnly
+	}
+	s.runQueueTimers(time.Now())
+	s.expireIfNeeded(key)
+	s.own(key)
 
 	val, err := s.checkType(key, domain.Queue)
 	if err != nil {
@@ -296,16 +672,16 @@ func (s *Store) Dequeue(key string) (string, error) {
 	}
 
 	queueVal := val.(*DataTypeValue.QueueValue)
-	if len(queueVal.Data) == 0 {
-		return "", fmt.Errorf("queue is empty")
+	value, _, ok := queueVal.Shift()
+	if !ok {
+		return "", fmt.Errorf("queue is %w", ErrEmpty)
 	}
+	s.trackKey(key)
+	s.notify("dequeue", key, domain.Queue)
 
-	value := queueVal.Data[0]
-	queueVal.Data = queueVal.Data[1:]
-
-	if s.enableAof {
+	if s.oplog {
 		// DEQUEUE AOF command should only record the operation, not the dequeued value
-		if err := s.aof.Write("DEQUEUE", key, "queue", ""); err != nil {
+		if err := s.writeAOF("DEQUEUE", key, "queue", ""); err != nil {
 			return value, err
 		}
 	}
@@ -317,7 +693,18 @@ func (s *Store) Dequeue(key string) (string, error) {
 
 func (s *Store) Push(key, value string) error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	return s.commit(s.push(key, value))
+}
+
+func (s *Store) push(key, value string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return err
+	}
 
 	val, exists := s.data[key]
 	var stackVal *DataTypeValue.StackValue
@@ -329,13 +716,16 @@ func (s *Store) Push(key, value string) error {
 		var ok bool
 		stackVal, ok = val.(*DataTypeValue.StackValue)
 		if !ok {
-			return fmt.Errorf("wrong type: expected stack")
+			return fmt.Errorf("%w: expected stack", ErrWrongType)
 		}
 	}
 
 	stackVal.Data = append(stackVal.Data, value)
-	if s.enableAof {
-		if err := s.aof.Write("PUSH", key, "stack", value); err != nil {
+	s.trackKey(key)
+	s.notify("push", key, domain.Stack)
+	s.signalReady(key)
+	if s.oplog {
+		if err := s.writeAOF("PUSH", key, "stack", value); err != nil {
 			return err
 		}
 	}
@@ -344,7 +734,16 @@ func (s *Store) Push(key, value string) error {
 
 func (s *Store) Pop(key string) (string, error) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	value, err := s.pop(key)
+	return value, s.commit(err)
+}
+
+func (s *Store) pop(key string) (string, error) {
+	if s.readOnly {
+		return "", ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
 
 	val, err := s.checkType(key, domain.Stack)
 	if err != nil {
@@ -353,16 +752,18 @@ func (s *Store) Pop(key string) (string, error) {
 
 	stackVal := val.(*DataTypeValue.StackValue)
 	if len(stackVal.Data) == 0 {
-		return "", fmt.Errorf("stack is empty")
+		return "", fmt.Errorf("stack is %w", ErrEmpty)
 	}
 
 	lastIdx := len(stackVal.Data) - 1
 	value := stackVal.Data[lastIdx]
 	stackVal.Data = stackVal.Data[:lastIdx]
+	s.trackKey(key)
+	s.notify("pop", key, domain.Stack)
 
-	if s.enableAof {
+	if s.oplog {
 		// POP AOF command should only record the operation, not the popped value
-		if err := s.aof.Write("POP", key, "stack", ""); err != nil {
+		if err := s.writeAOF("POP", key, "stack", ""); err != nil {
 			return value, err
 		}
 	}
@@ -377,56 +778,75 @@ type HSetPayload struct {
 	Value string `json:"v"`
 }
 
-func (s *Store) HSet(key, field, value string) error {
+// HSet sets field in the hashmap at key and reports whether the field is new
+func (s *Store) HSet(key, field, value string) (bool, error) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	created, err := s.hSet(key, field, value)
+	return created, s.commit(err)
+}
+
+func (s *Store) hSet(key, field, value string) (bool, error) {
+	if s.readOnly {
+		return false, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
+	s.own(key)
+	if err := s.ensureMemory(); err != nil {
+		return false, err
+	}
 
 	val, exists := s.data[key]
 	var hashVal *DataTypeValue.HashmapValue
 
 	if !exists {
-		hashVal = &DataTypeValue.HashmapValue{Data: make(map[string]string)}
+		hashVal = DataTypeValue.NewHashmapValue()
 		s.data[key] = hashVal
 	} else {
 		var ok bool
 		hashVal, ok = val.(*DataTypeValue.HashmapValue)
 		if !ok {
-			return fmt.Errorf("wrong type: expected hashmap")
+			return false, fmt.Errorf("%w: expected hashmap", ErrWrongType)
 		}
 	}
 
-	hashVal.Data[field] = value
+	_, existed := hashVal.Data[field]
+	hashVal.Set(field, value)
+	s.trackKey(key)
+	s.notify("hset", key, domain.Hashmap)
 
-	if s.enableAof {
+	if s.oplog {
 		payload := HSetPayload{
 			Field: field,
 			Value: value,
 		}
 		data, err := json.Marshal(payload)
 		if err != nil {
-			return err
+			return !existed, err
 		}
 
-		if err := s.aof.Write("HSET", key, "hashmap", string(data)); err != nil {
-			return err
+		if err := s.writeAOF("HSET", key, "hashmap", string(data)); err != nil {
+			return !existed, err
 		}
 	}
-	return nil
+	return !existed, nil
 }
 
 func (s *Store) HGet(key, field string) (string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.hGet(key, field)
+}
 
+func (s *Store) hGet(key, field string) (string, error) {
 	val, err := s.checkType(key, domain.Hashmap)
 	if err != nil {
 		return "", err
 	}
 
 	hashVal := val.(*DataTypeValue.HashmapValue)
-	value, exists := hashVal.Data[field]
+	value, exists := hashVal.Lookup(field, time.Now())
 	if !exists {
-		return "", fmt.Errorf("field not found")
+		return "", ErrFieldNotFound
 	}
 	return value, nil
 }
@@ -434,31 +854,37 @@ func (s *Store) HGet(key, field string) (string, error) {
 func (s *Store) HGetAll(key string) (map[string]string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.hGetAll(key)
+}
 
+func (s *Store) hGetAll(key string) (map[string]string, error) {
 	val, err := s.checkType(key, domain.Hashmap)
 	if err != nil {
 		return nil, err
 	}
 
-	hashVal := val.(*DataTypeValue.HashmapValue)
-	result := make(map[string]string, len(hashVal.Data))
-	for k, v := range hashVal.Data {
-		result[k] = v
-	}
-	return result, nil
+	return val.(*DataTypeValue.HashmapValue).Live(time.Now()), nil
 }
 
 func (s *Store) Delete(key string) (bool, error) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	ok, err := s.deleteKey(key)
+	return ok, s.commit(err)
+}
 
+func (s *Store) deleteKey(key string) (bool, error) {
+	if s.readOnly {
+		return false, ErrReadOnly
+	}
+	s.expireIfNeeded(key)
 	_, exists := s.data[key]
 	if exists {
-		delete(s.data, key)
+		s.notify("del", key, s.keyType(key))
+		s.removeKey(key)
 		logger.Info("Deleted key", "key", key)
 
-		if s.enableAof {
-			if err := s.aof.Write("DELETE", key, "", ""); err != nil {
+		if s.oplog {
+			if err := s.writeAOF("DELETE", key, "", ""); err != nil {
 				return false, err
 			}
 		}
@@ -469,12 +895,31 @@ func (s *Store) Delete(key string) (bool, error) {
 	return exists, nil
 }
 
+// Type returns the data type stored at key
+func (s *Store) Type(key string) (domain.DataType, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.typeOf(key)
+}
+
+func (s *Store) typeOf(key string) (domain.DataType, bool) {
+	val, exists := s.lookup(key)
+	if !exists {
+		return "", false
+	}
+	return val.Type(), true
+}
+
 func (s *Store) GetAll() map[string]domain.Value {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	now := time.Now()
 	result := make(map[string]domain.Value, len(s.data))
 	for k, v := range s.data {
+		if s.isExpired(k, now) {
+			continue
+		}
 		result[k] = v
 	}
 	logger.Debug("GetAll operation", "count", len(result))
@@ -485,8 +930,12 @@ func (s *Store) GetAllKeys() []string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	now := time.Now()
 	keys := make([]string, 0, len(s.data))
 	for k := range s.data {
+		if s.isExpired(k, now) {
+			continue
+		}
 		keys = append(keys, k)
 	}
 	logger.Debug("GetAllKeys operation", "count", len(keys))
@@ -497,8 +946,12 @@ func (s *Store) GetAllValues() []domain.Value {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	now := time.Now()
 	values := make([]domain.Value, 0, len(s.data))
-	for _, v := range s.data {
+	for k, v := range s.data {
+		if s.isExpired(k, now) {
+			continue
+		}
 		values = append(values, v)
 	}
 	logger.Debug("GetAllValues operation", "count", len(values))
@@ -510,106 +963,374 @@ func (s *Store) LoadFromAOF(filepath string) error {
 		logger.Warn("AOF is disabled")
 		return nil
 	}
-	tempAOF := &aof.AOF{}
 	logger.Info("Loading data from AOF...")
 
-	operations, err := tempAOF.Read(filepath)
+	hdr, operations, err := s.aof.Read(filepath)
 	if err != nil {
 		return err
 	}
 
+	// the snapshot holds the state up to the last rewrite, the AOF only what came after
+	var data map[string]domain.Value
+	var expires map[string]time.Time
+	if path := hdr.SnapshotPath(filepath); path != "" {
+		data, expires, err = rdb.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to load snapshot %s: %w", path, err)
+		}
+		logger.Info("Snapshot loaded", "path", path, "keys", len(data))
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if data != nil {
+		s.restore(data, expires)
+	}
+
 	for _, op := range operations {
-		switch op.Type {
+		s.applyOp(op)
+	}
+
+	s.rebuildMemory()
+	s.rebuildQueueTimers()
+	s.rebuildFieldTimers()
+
+	// keys whose deadline passed while the server was down are dropped now,
+	// and the deletion is logged so a later re-creation of the key replays cleanly
+	now := time.Now()
+	for key := range s.expires {
+		if s.isExpired(key, now) {
+			s.removeExpired(key)
+		}
+	}
+	s.runFieldTimers(now)
+	logger.Info("AOF loaded successfully")
+	return nil
+}
 
-		case "SET":
-			s.data[op.Key] = &DataTypeValue.StringValue{Data: op.Value}
-		case "SADD":
-			if _, exists := s.data[op.Key]; !exists {
-				s.data[op.Key] = &DataTypeValue.SetValue{Data: make(map[string]struct{})}
+// applyOp replays one logged operation onto the data maps. It does no memory
+// accounting, logging or notification. Requires the write lock.
+func (s *Store) applyOp(op aof.Operation) {
+	switch op.Type {
+	case "SET", "INCR", "RESTORE", "DELETE":
+		// these replace the value rather than change it
+	default:
+		s.own(op.Key)
+	}
+
+	switch op.Type {
+
+	case "SET":
+		value := op.Value
+		if op.ValueType == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(op.Value)
+			if err != nil {
+				logger.Error("Failed to decode binary AOF value", "key", op.Key, "error", err)
+				return
+			}
+			value = string(decoded)
+		}
+		s.data[op.Key] = &DataTypeValue.StringValue{Data: []byte(value)}
+		delete(s.expires, op.Key)
+	case "SETRANGE":
+		var payload StringRangePayload
+		if err := json.Unmarshal([]byte(op.Value), &payload); err == nil {
+			if str := s.replayString(op.Key); str != nil {
+				str.SetRange(payload.Offset, string(payload.Value))
+			}
+		}
+	case "SETBIT":
+		var payload SetBitPayload
+		if err := json.Unmarshal([]byte(op.Value), &payload); err == nil {
+			if str := s.replayString(op.Key); str != nil {
+				str.SetBit(payload.Offset, payload.Bit)
+			}
+		}
+	case "INCR":
+		// counters log their result and, unlike SET, keep the key's deadline
+		s.data[op.Key] = &DataTypeValue.StringValue{Data: []byte(op.Value)}
+	case "LSET", "LINSERT":
+		if listVal, ok := s.data[op.Key].(*DataTypeValue.ListValue); ok {
+			var payload ListIndexPayload
+			if err := json.Unmarshal([]byte(op.Value), &payload); err == nil {
+				if op.Type == "LSET" && payload.Index < len(listVal.Data) {
+					listVal.Data[payload.Index] = payload.Value
+				} else if op.Type == "LINSERT" && payload.Index <= len(listVal.Data) {
+					listVal.InsertAt(payload.Index, payload.Value)
+				}
+			}
+		}
+	case "LREM", "LTRIM":
+		if listVal, ok := s.data[op.Key].(*DataTypeValue.ListValue); ok {
+			if op.Type == "LREM" {
+				var payload LRemPayload
+				if err := json.Unmarshal([]byte(op.Value), &payload); err == nil {
+					listVal.Remove(payload.Value, payload.Count)
+				}
+			} else {
+				var payload LTrimPayload
+				if err := json.Unmarshal([]byte(op.Value), &payload); err == nil && payload.Start <= payload.End && payload.End <= len(listVal.Data) {
+					listVal.Data = append([]string(nil), listVal.Data[payload.Start:payload.End]...)
+				}
+			}
+			if len(listVal.Data) == 0 {
+				delete(s.data, op.Key)
+				delete(s.expires, op.Key)
 			}
-			if SetValue, ok := s.data[op.Key].(*DataTypeValue.SetValue); ok {
-				SetValue.Data[op.Value] = struct{}{}
+		}
+	case "SADD":
+		if _, exists := s.data[op.Key]; !exists {
+			s.data[op.Key] = DataTypeValue.NewSetValue()
+		}
+		if SetValue, ok := s.data[op.Key].(*DataTypeValue.SetValue); ok {
+			SetValue.Add(op.Value)
+		}
+	case "SSTORE":
+		var members []string
+		if err := json.Unmarshal([]byte(op.Value), &members); err == nil {
+			delete(s.expires, op.Key)
+			if len(members) == 0 {
+				delete(s.data, op.Key)
+				break
+			}
+			setVal := DataTypeValue.NewSetValue()
+			for _, member := range members {
+				setVal.Add(member)
+			}
+			s.data[op.Key] = setVal
+		}
+	case "SPOP":
+		if val, exists := s.data[op.Key]; exists {
+			if SetValue, ok := val.(*DataTypeValue.SetValue); ok {
+				SetValue.Remove(op.Value)
+				// files written before emptied sets were deleted have no DELETE after the last SPOP
+				if len(SetValue.Data) == 0 {
+					delete(s.data, op.Key)
+					delete(s.expires, op.Key)
+				}
 			}
-		case "SPOP":
-			if val, exists := s.data[op.Key]; exists {
-				if SetValue, ok := val.(*DataTypeValue.SetValue); ok {
-					delete(SetValue.Data, op.Value)
+		}
+
+	case "LPUSH":
+		if _, exists := s.data[op.Key]; !exists {
+			s.data[op.Key] = &DataTypeValue.ListValue{Data: make([]string, 0)}
+		}
+		if ListValue, ok := s.data[op.Key].(*DataTypeValue.ListValue); ok {
+			ListValue.Data = append([]string{op.Value}, ListValue.Data...)
+		}
+	case "RPUSH":
+		if _, exists := s.data[op.Key]; !exists {
+			s.data[op.Key] = &DataTypeValue.ListValue{Data: make([]string, 0)}
+		}
+		if ListValue, ok := s.data[op.Key].(*DataTypeValue.ListValue); ok {
+			ListValue.Data = append(ListValue.Data, op.Value)
+		}
+
+	case "LPOP", "RPOP":
+		if val, exists := s.data[op.Key]; exists {
+			if listVal, ok := val.(*DataTypeValue.ListValue); ok && len(listVal.Data) > 0 {
+				if op.Type == "LPOP" {
+					listVal.Data = listVal.Data[1:]
+				} else {
+					listVal.Data = listVal.Data[:len(listVal.Data)-1]
+				}
+				if len(listVal.Data) == 0 {
+					delete(s.data, op.Key)
+					delete(s.expires, op.Key)
 				}
 			}
+		}
+
+	case "ENQUEUE":
+		if _, exists := s.data[op.Key]; !exists {
+			s.data[op.Key] = &DataTypeValue.QueueValue{Data: make([]string, 0)}
+		}
+		if queueValue, ok := s.data[op.Key].(*DataTypeValue.QueueValue); ok {
+			queueValue.Append(op.Value)
+		}
 
-		case "LPUSH":
-			if _, exists := s.data[op.Key]; !exists {
-				s.data[op.Key] = &DataTypeValue.ListValue{Data: make([]string, 0)}
+	case "REQUEUE":
+		if _, exists := s.data[op.Key]; !exists {
+			s.data[op.Key] = &DataTypeValue.QueueValue{Data: make([]string, 0)}
+		}
+		if queueValue, ok := s.data[op.Key].(*DataTypeValue.QueueValue); ok {
+			queueValue.Unshift(op.Value, 0)
+		}
+
+	case "DEQUEUE":
+		if val, exists := s.data[op.Key]; exists {
+			if queueValue, ok := val.(*DataTypeValue.QueueValue); ok {
+				queueValue.Shift()
 			}
-			if ListValue, ok := s.data[op.Key].(*DataTypeValue.ListValue); ok {
-				ListValue.Data = append([]string{op.Value}, ListValue.Data...)
+		}
+
+	case "LEASE":
+		s.replayLease(op.Key, op.Value)
+
+	case "ACK":
+		if queueValue, ok := s.data[op.Key].(*DataTypeValue.QueueValue); ok {
+			delete(queueValue.Leases, op.Value)
+		}
+
+	case "NACK":
+		s.replayRelease(op.Key, op.Value)
+
+	case "SCHEDULE":
+		s.replaySchedule(op.Key, op.Value)
+
+	case "PROMOTE":
+		s.replayPromote(op.Key, op.Value)
+
+	case "PUSH":
+		if _, exists := s.data[op.Key]; !exists {
+			s.data[op.Key] = &DataTypeValue.StackValue{Data: make([]string, 0)}
+		}
+
+		if val, ok := s.data[op.Key].(*DataTypeValue.StackValue); ok {
+			val.Data = append(val.Data, op.Value)
+		}
+
+	case "POP":
+		if val, exists := s.data[op.Key]; exists {
+			if val, ok := val.(*DataTypeValue.StackValue); ok && len(val.Data) > 0 {
+				val.Data = val.Data[:len(val.Data)-1]
 			}
-		case "RPUSH":
-			if _, exists := s.data[op.Key]; !exists {
-				s.data[op.Key] = &DataTypeValue.ListValue{Data: make([]string, 0)}
+		}
+
+	case "HSET":
+		if _, exists := s.data[op.Key]; !exists {
+			s.data[op.Key] = DataTypeValue.NewHashmapValue()
+		}
+		if hashVal, ok := s.data[op.Key].(*DataTypeValue.HashmapValue); ok {
+			var payload HSetPayload
+			if err := json.Unmarshal([]byte(op.Value), &payload); err == nil {
+				hashVal.Set(payload.Field, payload.Value)
+			} else {
+				parts := strings.SplitN(op.Value, ":", 2)
+				if len(parts) == 2 {
+					hashVal.Set(parts[0], parts[1])
+				}
 			}
-			if ListValue, ok := s.data[op.Key].(*DataTypeValue.ListValue); ok {
-				ListValue.Data = append(ListValue.Data, op.Value)
+		}
+
+	case "HMSET":
+		if _, exists := s.data[op.Key]; !exists {
+			s.data[op.Key] = DataTypeValue.NewHashmapValue()
+		}
+		if hashVal, ok := s.data[op.Key].(*DataTypeValue.HashmapValue); ok {
+			var fields map[string]string
+			if err := json.Unmarshal([]byte(op.Value), &fields); err == nil {
+				for field, value := range fields {
+					hashVal.Set(field, value)
+				}
 			}
+		}
 
-		case "ENQUEUE":
-			if _, exists := s.data[op.Key]; !exists {
-				s.data[op.Key] = &DataTypeValue.QueueValue{Data: make([]string, 0)}
+	case "HDEL":
+		if hashVal, ok := s.data[op.Key].(*DataTypeValue.HashmapValue); ok {
+			var fields []string
+			if err := json.Unmarshal([]byte(op.Value), &fields); err == nil {
+				for _, field := range fields {
+					hashVal.Delete(field)
+				}
 			}
-			if queueValue, ok := s.data[op.Key].(*DataTypeValue.QueueValue); ok {
-				queueValue.Data = append(queueValue.Data, op.Value)
+			if len(hashVal.Data) == 0 {
+				delete(s.data, op.Key)
+				delete(s.expires, op.Key)
 			}
+		}
 
-		case "DEQUEUE":
-			if val, exists := s.data[op.Key]; exists {
-				if queueValue, ok := val.(*DataTypeValue.QueueValue); ok {
-					if len(queueValue.Data) > 0 {
-						queueValue.Data = queueValue.Data[1:]
+	case "HEXPIREAT":
+		if hashVal, ok := s.data[op.Key].(*DataTypeValue.HashmapValue); ok {
+			var payload HExpirePayload
+			if err := json.Unmarshal([]byte(op.Value), &payload); err == nil {
+				deadline := time.UnixMilli(payload.At)
+				for _, field := range payload.Fields {
+					if _, exists := hashVal.Data[field]; exists {
+						hashVal.ExpireAt(field, deadline)
 					}
 				}
+				heap.Push(&s.fieldTimers, fieldTimer{at: deadline, key: op.Key})
 			}
+		}
 
-		case "PUSH":
-			if _, exists := s.data[op.Key]; !exists {
-				s.data[op.Key] = &DataTypeValue.StackValue{Data: make([]string, 0)}
+	case "HPERSIST":
+		if hashVal, ok := s.data[op.Key].(*DataTypeValue.HashmapValue); ok {
+			var fields []string
+			if err := json.Unmarshal([]byte(op.Value), &fields); err == nil {
+				for _, field := range fields {
+					hashVal.Persist(field)
+				}
 			}
+		}
 
-			if val, ok := s.data[op.Key].(*DataTypeValue.StackValue); ok {
-				val.Data = append(val.Data, op.Value)
+	case "ZADD":
+		if _, exists := s.data[op.Key]; !exists {
+			s.data[op.Key] = DataTypeValue.NewSortedSetValue()
+		}
+		if zsetVal, ok := s.data[op.Key].(*DataTypeValue.SortedSetValue); ok {
+			var payload ZAddPayload
+			if err := json.Unmarshal([]byte(op.Value), &payload); err == nil {
+				zsetVal.Add(payload.Member, payload.Score)
 			}
+		}
 
-		case "POP":
-			if val, exists := s.data[op.Key]; exists {
-				if val, ok := val.(*DataTypeValue.StackValue); ok && len(val.Data) > 0 {
-					val.Data = val.Data[:len(val.Data)-1]
+	case "ZREM":
+		if val, exists := s.data[op.Key]; exists {
+			if zsetVal, ok := val.(*DataTypeValue.SortedSetValue); ok {
+				zsetVal.Remove(op.Value)
+				if zsetVal.Len() == 0 {
+					delete(s.data, op.Key)
+					delete(s.expires, op.Key)
 				}
 			}
+		}
 
-		case "HSET":
-			if _, exists := s.data[op.Key]; !exists {
-				s.data[op.Key] = &DataTypeValue.HashmapValue{Data: make(map[string]string)}
+	case "PQPUSH":
+		if _, exists := s.data[op.Key]; !exists {
+			s.data[op.Key] = DataTypeValue.NewPriorityQueueValue()
+		}
+		if pqVal, ok := s.data[op.Key].(*DataTypeValue.PriorityQueueValue); ok {
+			var payload PQPushPayload
+			if err := json.Unmarshal([]byte(op.Value), &payload); err == nil {
+				pqVal.Push(payload.Value, payload.Priority)
 			}
-			if hashVal, ok := s.data[op.Key].(*DataTypeValue.HashmapValue); ok {
-				var payload HSetPayload
-				if err := json.Unmarshal([]byte(op.Value), &payload); err == nil {
-					hashVal.Data[payload.Field] = payload.Value
-				} else {
-					parts := strings.SplitN(op.Value, ":", 2)
-					if len(parts) == 2 {
-						hashVal.Data[parts[0]] = parts[1]
-					}
-				}
+		}
+
+	case "PQPOP":
+		if pqVal, ok := s.data[op.Key].(*DataTypeValue.PriorityQueueValue); ok {
+			pqVal.Pop()
+			if pqVal.Len() == 0 {
+				delete(s.data, op.Key)
+				delete(s.expires, op.Key)
+			}
+		}
+
+	case "RESTORE":
+		if payload, err := base64.StdEncoding.DecodeString(op.Value); err == nil {
+			if val, err := rdb.RestoreValue(payload); err == nil {
+				s.data[op.Key] = val
+				delete(s.expires, op.Key)
+				s.scheduleQueueTimers(op.Key)
+				s.scheduleFieldTimers(op.Key)
 			}
+		}
 
-		case "DELETE":
-			delete(s.data, op.Key)
+	case "EXPIREAT":
+		if ms, err := strconv.ParseInt(op.Value, 10, 64); err == nil {
+			if _, exists := s.data[op.Key]; exists {
+				s.expires[op.Key] = time.UnixMilli(ms)
+			}
 		}
+
+	case "PERSIST":
+		delete(s.expires, op.Key)
+
+	case "DELETE":
+		delete(s.data, op.Key)
+		delete(s.expires, op.Key)
 	}
-	logger.Info("AOF loaded successfully")
-	return nil
 }
 
 // Snapshot triggers AOF snapshot generation
@@ -619,3 +1340,57 @@ func (s *Store) Snapshot() error {
 	}
 	return s.aof.Snapshot(s)
 }
+
+// BackgroundRewrite starts an AOF snapshot without waiting for it to finish
+func (s *Store) BackgroundRewrite() error {
+	if !s.enableAof {
+		return fmt.Errorf("AOF is not enabled")
+	}
+	return s.aof.BackgroundRewrite(s)
+}
+
+// Fork returns every live key and its deadline, the point-in-time view an AOF
+// rewrite is built from. Only the maps are copied: the values are shared with
+// the store until its next write to them, which works on a clone, see own. The
+// read lock keeps writers out for the copy, a pause linear in the number of
+// keys but not in their size, so mark runs before any write made after the
+// copy reaches the AOF. The caller must not modify the values.
+func (s *Store) Fork(mark func()) (map[string]domain.Value, map[string]time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	data := make(map[string]domain.Value, len(s.data))
+	expires := make(map[string]time.Time, len(s.expires))
+	for k, v := range s.data {
+		if s.isExpired(k, now) {
+			continue
+		}
+		data[k] = v
+		if deadline, ok := s.expires[k]; ok {
+			expires[k] = deadline
+		}
+	}
+	s.forks.Add(1)
+	mark()
+	logger.Debug("Fork operation", "count", len(data))
+	return data, expires
+}
+
+// own gives key a value of its own before it is changed in place, cloning the
+// one it holds if a Fork taken since the key last owned it may still read it.
+// Writers call it right after expireIfNeeded. Requires the write lock.
+func (s *Store) own(key string) {
+	meta, tracked := s.meta[key]
+	if !tracked {
+		return
+	}
+	forks := s.forks.Load()
+	if meta.owned == forks {
+		return
+	}
+	if val, exists := s.data[key]; exists {
+		s.data[key] = val.Clone()
+	}
+	meta.owned = forks
+}
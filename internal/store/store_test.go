@@ -0,0 +1,114 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/mrpurushotam/mini_db/internal/aof"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+// newAOFStore returns a store logging to an AOF in a temporary directory, and the path of that file
+func newAOFStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.aof")
+	return openAOFStore(t, path), path
+}
+
+// openAOFStore returns a store loaded from the AOF at path, as after a restart
+func openAOFStore(t *testing.T, path string) *Store {
+	t.Helper()
+	file, err := aof.NewAOF(path, aof.FsyncAlways)
+	if err != nil {
+		t.Fatalf("NewAOF: %v", err)
+	}
+	t.Cleanup(func() { file.Close() })
+	s := NewStore()
+	s.EnableAOF(file)
+	if err := s.LoadFromAOF(path); err != nil {
+		t.Fatalf("LoadFromAOF: %v", err)
+	}
+	return s
+}
+
+// Fork shares values with the store; writes made after it go to clones
+func TestForkIsUnchangedByLaterWrites(t *testing.T) {
+	s := NewStore()
+	if _, err := s.RPush("list", "a", "b"); err != nil {
+		t.Fatalf("RPush: %v", err)
+	}
+	if _, err := s.HSet("hash", "f", "v"); err != nil {
+		t.Fatalf("HSet: %v", err)
+	}
+
+	first, _ := s.Fork(func() {})
+	if _, err := s.RPush("list", "c"); err != nil {
+		t.Fatalf("RPush: %v", err)
+	}
+	second, _ := s.Fork(func() {})
+	if _, err := s.RPush("list", "d"); err != nil {
+		t.Fatalf("RPush: %v", err)
+	}
+	if _, err := s.HSet("hash", "f", "changed"); err != nil {
+		t.Fatalf("HSet: %v", err)
+	}
+
+	if got := first["list"].(*DataTypeValue.ListValue).Data; len(got) != 2 {
+		t.Fatalf("first fork list = %v, want [a b]", got)
+	}
+	if got := second["list"].(*DataTypeValue.ListValue).Data; len(got) != 3 {
+		t.Fatalf("second fork list = %v, want [a b c]", got)
+	}
+	if got := first["hash"].(*DataTypeValue.HashmapValue).Data["f"]; got != "v" {
+		t.Fatalf("first fork hash field = %q, want v", got)
+	}
+	if got, _ := s.LRange("list", 0, -1); len(got) != 4 {
+		t.Fatalf("store list = %v, want [a b c d]", got)
+	}
+}
+
+// SETBIT changes the string in place, which must not reach a fork holding it
+func TestForkKeepsStringWhenBitsAreSet(t *testing.T) {
+	s := NewStore()
+	if _, err := s.SetBit("bits", 0, 1); err != nil {
+		t.Fatalf("SetBit: %v", err)
+	}
+	fork, _ := s.Fork(func() {})
+	for _, offset := range []int64{1, 7, 20} {
+		if _, err := s.SetBit("bits", offset, 1); err != nil {
+			t.Fatalf("SetBit: %v", err)
+		}
+	}
+
+	if got := fork["bits"].(*DataTypeValue.StringValue).String(); got != "\x80" {
+		t.Fatalf("fork string = %q, want \\x80", got)
+	}
+	if got, _ := s.Get("bits"); got != "\xc1\x00\x08" {
+		t.Fatalf("store string = %q, want \\xc1\\x00\\x08", got)
+	}
+}
+
+// A dequeued item put back with Unpop is the next one out, also after a restart
+func TestUnpopReturnsQueueItemToTheHead(t *testing.T) {
+	s, path := newAOFStore(t)
+	for _, v := range []string{"a", "b"} {
+		if err := s.Enqueue("q", v); err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+	}
+	v, err := s.Dequeue("q")
+	if err != nil {
+		t.Fatalf("Dequeue: %v", err)
+	}
+	if err := s.Unpop(PopQueue, "q", v); err != nil {
+		t.Fatalf("Unpop: %v", err)
+	}
+
+	// reopened before s dequeues again, which would be logged too
+	reopened := openAOFStore(t, path)
+	for _, store := range []*Store{s, reopened} {
+		if got, err := store.Dequeue("q"); err != nil || got != "a" {
+			t.Fatalf("Dequeue = %q, %v; want a", got, err)
+		}
+	}
+}
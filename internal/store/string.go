@@ -0,0 +1,462 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+	DataTypeValue "github.com/mrpurushotam/mini_db/internal/value"
+)
+
+const (
+	// largest string SETRANGE and APPEND may build, the default proto-max-bulk-len of redis
+	maxStringSize = 512 << 20
+	// largest bit offset SETBIT accepts, so bitmaps stay under maxStringSize
+	maxBitOffset = maxStringSize*8 - 1
+)
+
+var (
+	ErrStringTooLong = errors.New("string exceeds maximum allowed size (512MB)")
+	ErrBitOffset     = errors.New("bit offset is not an integer or out of range")
+	ErrBitValue      = errors.New("bit is not an integer or out of range")
+	ErrOffset        = errors.New("offset is out of range")
+	ErrBitOpNot      = errors.New("BITOP NOT must be called with a single source key")
+)
+
+// StringRangePayload is the AOF value of a SETRANGE record. APPEND is logged as
+// a SETRANGE at the old length, so replaying it twice gives the same string.
+// Value is a byte slice so JSON carries it as base64 and binary values survive.
+type StringRangePayload struct {
+	Offset int    `json:"o"`
+	Value  []byte `json:"v"`
+}
+
+// SetBitPayload is the AOF value of a SETBIT record
+type SetBitPayload struct {
+	Offset int64 `json:"o"`
+	Bit    int   `json:"b"`
+}
+
+// BitOp selects the bitwise operation of BITOP
+type BitOp int
+
+const (
+	BitAnd BitOp = iota
+	BitOr
+	BitXor
+	BitNot
+)
+
+// ParseBitOp reads the operation name of BITOP, case insensitively
+func ParseBitOp(name string) (BitOp, bool) {
+	switch strings.ToLower(name) {
+	case "and":
+		return BitAnd, true
+	case "or":
+		return BitOr, true
+	case "xor":
+		return BitXor, true
+	case "not":
+		return BitNot, true
+	}
+	return 0, false
+}
+
+func (op BitOp) String() string {
+	switch op {
+	case BitAnd:
+		return "and"
+	case BitOr:
+		return "or"
+	case BitXor:
+		return "xor"
+	default:
+		return "not"
+	}
+}
+
+// ===== STRING RANGES =====
+
+// Append adds value to the end of the string at key, creating it when missing,
+// and returns the new length. An existing expiry is kept.
+func (s *Store) Append(key, value string) (int, error) {
+	s.mu.Lock()
+	n, err := s.appendString(key, value)
+	return n, s.commit(err)
+}
+
+func (s *Store) appendString(key, value string) (int, error) {
+	str, err := s.counterString(key)
+	if err != nil {
+		return 0, err
+	}
+	offset := 0
+	if str != nil {
+		offset = len(str.Data)
+	}
+	return s.setRange(key, str, offset, value, "append")
+}
+
+// SetRange overwrites the string at key from offset on, padding it with zero
+// bytes as needed, and returns the new length. A missing key is created unless
+// value is empty; an existing expiry is kept.
+func (s *Store) SetRange(key string, offset int, value string) (int, error) {
+	s.mu.Lock()
+	n, err := s.setRangeString(key, offset, value)
+	return n, s.commit(err)
+}
+
+func (s *Store) setRangeString(key string, offset int, value string) (int, error) {
+	if offset < 0 {
+		return 0, ErrOffset
+	}
+	str, err := s.counterString(key)
+	if err != nil {
+		return 0, err
+	}
+	return s.setRange(key, str, offset, value, "setrange")
+}
+
+// setRange writes value at offset into str, or a new string at key when str is
+// nil, and logs it as a SETRANGE record. Requires the write lock.
+func (s *Store) setRange(key string, str *DataTypeValue.StringValue, offset int, value, event string) (int, error) {
+	if len(value) == 0 {
+		if str == nil {
+			return 0, nil
+		}
+		return len(str.Data), nil
+	}
+	if offset+len(value) > maxStringSize {
+		return 0, ErrStringTooLong
+	}
+	if str == nil {
+		str = &DataTypeValue.StringValue{}
+		s.data[key] = str
+	}
+	str.SetRange(offset, value)
+	s.trackKey(key)
+	s.notify(event, key, domain.String)
+
+	return len(str.Data), s.writeStringPayload("SETRANGE", key, StringRangePayload{Offset: offset, Value: []byte(value)})
+}
+
+// StrLen returns the length in bytes of the string at key; a missing key has length 0
+func (s *Store) StrLen(key string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.strLen(key)
+}
+
+func (s *Store) strLen(key string) (int, error) {
+	val, err := s.checkType(key, domain.String)
+	if errors.Is(err, ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(val.(*DataTypeValue.StringValue).Data), nil
+}
+
+// GetRange returns the bytes of the string at key between start and end
+// inclusive; negative indexes count from the end and a missing key is empty
+func (s *Store) GetRange(key string, start, end int64) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getRange(key, start, end)
+}
+
+func (s *Store) getRange(key string, start, end int64) (string, error) {
+	val, err := s.checkType(key, domain.String)
+	if errors.Is(err, ErrKeyNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return val.(*DataTypeValue.StringValue).GetRange(start, end), nil
+}
+
+// ===== BITMAPS =====
+
+// BitRange selects part of a string for BitCount and BitPos. Start and End are
+// inclusive, count from the end when negative and index bytes, or bits when
+// Bits is set. HasEnd tells BitPos whether End was given.
+type BitRange struct {
+	Start, End int64
+	Bits       bool
+	HasEnd     bool
+}
+
+// WholeString is the BitRange covering the whole value
+var WholeString = BitRange{Start: 0, End: -1}
+
+// ParseBitRange reads the optional [start [end [BYTE|BIT]]] arguments of
+// BITCOUNT and BITPOS. BITCOUNT sets requireEnd, as it takes no lone start.
+func ParseBitRange(args []string, requireEnd bool) (BitRange, error) {
+	r := WholeString
+	if len(args) == 0 {
+		return r, nil
+	}
+	if len(args) > 3 || (requireEnd && len(args) == 1) {
+		return r, ErrSyntax
+	}
+	var err error
+	if r.Start, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+		return r, ErrNotInteger
+	}
+	if len(args) == 1 {
+		return r, nil
+	}
+	if r.End, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+		return r, ErrNotInteger
+	}
+	r.HasEnd = true
+	if len(args) == 3 {
+		switch strings.ToLower(args[2]) {
+		case "byte":
+		case "bit":
+			r.Bits = true
+		default:
+			return r, ErrSyntax
+		}
+	}
+	return r, nil
+}
+
+// SetBit sets or clears the bit at offset in the string at key, growing or
+// creating it as needed, and returns the bit's previous value. Bit 0 is the
+// most significant bit of the first byte.
+func (s *Store) SetBit(key string, offset int64, bit int) (int, error) {
+	s.mu.Lock()
+	old, err := s.setBit(key, offset, bit)
+	return old, s.commit(err)
+}
+
+func (s *Store) setBit(key string, offset int64, bit int) (int, error) {
+	if offset < 0 || offset > maxBitOffset {
+		return 0, ErrBitOffset
+	}
+	if bit != 0 && bit != 1 {
+		return 0, ErrBitValue
+	}
+	str, err := s.counterString(key)
+	if err != nil {
+		return 0, err
+	}
+	if str == nil {
+		str = &DataTypeValue.StringValue{}
+		s.data[key] = str
+	}
+	old := str.SetBit(offset, bit)
+	s.trackKey(key)
+	s.notify("setbit", key, domain.String)
+
+	return old, s.writeStringPayload("SETBIT", key, SetBitPayload{Offset: offset, Bit: bit})
+}
+
+func (s *Store) GetBit(key string, offset int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getBit(key, offset)
+}
+
+func (s *Store) getBit(key string, offset int64) (int, error) {
+	if offset < 0 || offset > maxBitOffset {
+		return 0, ErrBitOffset
+	}
+	val, err := s.checkType(key, domain.String)
+	if errors.Is(err, ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return val.(*DataTypeValue.StringValue).GetBit(offset), nil
+}
+
+// BitCount counts the set bits of the string at key within r; a missing key has none
+func (s *Store) BitCount(key string, r BitRange) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bitCount(key, r)
+}
+
+func (s *Store) bitCount(key string, r BitRange) (int64, error) {
+	val, err := s.checkType(key, domain.String)
+	if errors.Is(err, ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return val.(*DataTypeValue.StringValue).BitCount(r.Start, r.End, r.Bits), nil
+}
+
+// BitPos returns the position of the first bit equal to bit in the string at
+// key within r, or -1. A missing key is an empty string, whose first clear bit is 0.
+func (s *Store) BitPos(key string, bit int, r BitRange) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bitPos(key, bit, r)
+}
+
+func (s *Store) bitPos(key string, bit int, r BitRange) (int64, error) {
+	if bit != 0 && bit != 1 {
+		return 0, ErrBitValue
+	}
+	val, err := s.checkType(key, domain.String)
+	if errors.Is(err, ErrKeyNotFound) {
+		if bit == 0 {
+			return 0, nil
+		}
+		return -1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return val.(*DataTypeValue.StringValue).BitPos(bit, r.Start, r.End, r.Bits, r.HasEnd), nil
+}
+
+// BitOpStore combines the strings at keys bitwise and stores the result at
+// destination, replacing it and its expiry, and returns its length. Missing
+// keys count as empty strings and shorter strings are padded with zero bytes.
+// NOT takes a single key. An empty result deletes destination.
+func (s *Store) BitOpStore(op BitOp, destination string, keys ...string) (int, error) {
+	s.mu.Lock()
+	n, err := s.bitOpStore(op, destination, keys)
+	return n, s.commit(err)
+}
+
+func (s *Store) bitOpStore(op BitOp, destination string, keys []string) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	if len(keys) == 0 {
+		return 0, ErrSyntax
+	}
+	if op == BitNot && len(keys) != 1 {
+		return 0, ErrBitOpNot
+	}
+	for _, key := range keys {
+		s.expireIfNeeded(key)
+	}
+	s.expireIfNeeded(destination)
+	if err := s.ensureMemory(); err != nil {
+		return 0, err
+	}
+
+	sources := make([]string, len(keys))
+	for i, key := range keys {
+		val, err := s.checkType(key, domain.String)
+		if errors.Is(err, ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		sources[i] = val.(*DataTypeValue.StringValue).String()
+	}
+	result := combineBits(op, sources)
+
+	if len(result) == 0 {
+		if _, exists := s.data[destination]; exists {
+			s.notify("del", destination, s.keyType(destination))
+			s.removeKey(destination)
+			if s.oplog {
+				if err := s.writeAOF("DELETE", destination, "", ""); err != nil {
+					return 0, err
+				}
+			}
+		}
+		return 0, nil
+	}
+	s.data[destination] = &DataTypeValue.StringValue{Data: []byte(result)}
+	delete(s.expires, destination)
+	s.trackKey(destination)
+	s.notify("bitop", destination, domain.String)
+
+	if err := s.writeStringAOF(destination, result); err != nil {
+		return len(result), err
+	}
+	logger.Debug("BITOP operation", "op", op, "destination", destination, "length", len(result))
+	return len(result), nil
+}
+
+// combineBits applies op byte by byte, padding shorter sources with zero bytes
+func combineBits(op BitOp, sources []string) string {
+	n := 0
+	for _, src := range sources {
+		n = max(n, len(src))
+	}
+	result := make([]byte, n)
+	if op == BitNot {
+		for i := range result {
+			result[i] = ^sources[0][i]
+		}
+		return string(result)
+	}
+	for i := range result {
+		var b byte
+		for j, src := range sources {
+			var c byte
+			if i < len(src) {
+				c = src[i]
+			}
+			switch {
+			case j == 0:
+				b = c
+			case op == BitAnd:
+				b &= c
+			case op == BitOr:
+				b |= c
+			default:
+				b ^= c
+			}
+		}
+		result[i] = b
+	}
+	return string(result)
+}
+
+// writeStringAOF logs value as a SET record. Values that are not valid UTF-8
+// would not survive the JSON encoding of the AOF, so they are logged base64
+// encoded with a "base64" value type.
+func (s *Store) writeStringAOF(key, value string) error {
+	if !s.oplog {
+		return nil
+	}
+	if utf8.ValidString(value) {
+		return s.writeAOF("SET", key, "string", value)
+	}
+	return s.writeAOF("SET", key, "base64", base64.StdEncoding.EncodeToString([]byte(value)))
+}
+
+func (s *Store) writeStringPayload(op, key string, payload any) error {
+	if !s.oplog {
+		return nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return s.writeAOF(op, key, "string", string(data))
+}
+
+// replayString returns the string at key for a SETRANGE or SETBIT record,
+// creating it when missing, or nil when key holds another type
+func (s *Store) replayString(key string) *DataTypeValue.StringValue {
+	val, exists := s.data[key]
+	if !exists {
+		str := &DataTypeValue.StringValue{}
+		s.data[key] = str
+		return str
+	}
+	str, _ := val.(*DataTypeValue.StringValue)
+	return str
+}
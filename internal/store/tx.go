@@ -0,0 +1,85 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mrpurushotam/mini_db/internal/aof"
+	"github.com/mrpurushotam/mini_db/internal/logger"
+)
+
+var ErrTxAborted = errors.New("transaction aborted: watched key changed")
+
+// maxTombstones bounds the removals remembered per key for WATCH, see markRemoved
+const maxTombstones = 10000
+
+// tombstone is a key removal, kept so WATCH on the missing key sees it
+type tombstone struct {
+	key string
+	seq uint64
+}
+
+// Version returns the modification version of key for WATCH. A missing key has
+// the version of its latest removal, so a watched missing key that is created
+// and deleted again changes it, while removing other keys does not.
+func (s *Store) Version(key string) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version(key)
+}
+
+func (s *Store) version(key string) uint64 {
+	if _, exists := s.data[key]; exists && !s.isExpired(key, time.Now()) {
+		if meta, ok := s.meta[key]; ok {
+			return meta.version
+		}
+	}
+	if seq, ok := s.removed[key]; ok {
+		return seq
+	}
+	return s.removedSeq
+}
+
+func (s *Store) bumpVersion(key string) {
+	if meta, ok := s.meta[key]; ok {
+		s.writeSeq++
+		meta.version = s.writeSeq
+	}
+}
+
+// Exec runs cmds under a single acquisition of the store lock. If any watched key's
+// version differs from the given one nothing is executed and ErrTxAborted is returned.
+// The AOF records produced by the commands are written as one MULTI/EXEC group.
+func (s *Store) Exec(watched map[string]uint64, cmds []Command) ([]Result, error) {
+	for _, cmd := range cmds {
+		if err := ValidateCommand(cmd); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+
+	for key, version := range watched {
+		if s.version(key) != version {
+			logger.Debug("Transaction aborted", "key", key)
+			s.mu.Unlock()
+			return nil, ErrTxAborted
+		}
+	}
+
+	s.txOps = make([]aof.Operation, 0)
+	results := make([]Result, len(cmds))
+	for i, cmd := range cmds {
+		value, err := commands[strings.ToLower(cmd.Name)].run(s, cmd.Args)
+		results[i] = Result{Value: value, Err: err}
+	}
+	ops := s.txOps
+	s.txOps = nil
+
+	if err := s.writeAOFBatch(ops); err != nil {
+		return results, s.commit(err)
+	}
+	logger.Debug("Transaction executed", "commands", len(cmds), "aofOperations", len(ops))
+	return results, s.commit(nil)
+}
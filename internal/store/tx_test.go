@@ -0,0 +1,86 @@
+package store
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestExecAbortsWhenWatchedMissingKeyIsCreatedAndDeleted(t *testing.T) {
+	s := NewStore()
+	watched := map[string]uint64{"k": s.Version("k")}
+
+	if err := s.Set("k", "v"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := s.Delete("k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	_, err := s.Exec(watched, []Command{{Name: "SET", Args: []string{"k", "mine"}}})
+	if !errors.Is(err, ErrTxAborted) {
+		t.Fatalf("Exec = %v, want ErrTxAborted", err)
+	}
+}
+
+func TestExecRunsWhenWatchedKeysAreUntouched(t *testing.T) {
+	s := NewStore()
+	if err := s.Set("a", "1"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	watched := map[string]uint64{"a": s.Version("a"), "missing": s.Version("missing")}
+
+	if err := s.Set("other", "x"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	results, err := s.Exec(watched, []Command{{Name: "INCR", Args: []string{"a"}}})
+	if err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+	if results[0].Err != nil || results[0].Value != int64(2) {
+		t.Fatalf("INCR result = %+v, want 2", results[0])
+	}
+}
+
+// Removing another key leaves the version of a watched missing key alone
+func TestExecRunsWhenAnotherKeyIsDeleted(t *testing.T) {
+	s := NewStore()
+	if err := s.Set("other", "x"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	watched := map[string]uint64{"missing": s.Version("missing")}
+
+	if _, err := s.Delete("other"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Exec(watched, []Command{{Name: "SET", Args: []string{"missing", "v"}}}); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+}
+
+// A removal whose tombstone was dropped to bound memory still aborts the watcher
+func TestExecAbortsWhenWatchedKeysTombstoneIsDropped(t *testing.T) {
+	s := NewStore()
+	watched := map[string]uint64{"k": s.Version("k")}
+
+	if err := s.Set("k", "v"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := s.Delete("k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	for i := range maxTombstones {
+		key := "filler" + strconv.Itoa(i)
+		if err := s.Set(key, "x"); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if _, err := s.Delete(key); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	}
+
+	_, err := s.Exec(watched, []Command{{Name: "SET", Args: []string{"k", "mine"}}})
+	if !errors.Is(err, ErrTxAborted) {
+		t.Fatalf("Exec = %v, want ErrTxAborted", err)
+	}
+}
@@ -2,23 +2,176 @@ package value
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/mrpurushotam/mini_db/internal/domain"
 )
 
-// Where value is hashmap type
+// Where value is hashmap type. Data is read freely but only changed through
+// Set and Delete, which keep the fields' scan index in step.
 type HashmapValue struct {
 	Data map[string]string
+	// Expires holds the deadlines of fields with a time to live. It stays nil
+	// until one is set, so plain hashmaps pay nothing for it.
+	Expires map[string]time.Time
+	index   *ScanIndex
+}
+
+func NewHashmapValue() *HashmapValue {
+	return &HashmapValue{Data: make(map[string]string), index: NewScanIndex()}
 }
 
 func (h *HashmapValue) Type() domain.DataType {
 	return domain.Hashmap
 }
 
+// Serialize encodes the fields that have not expired
 func (h *HashmapValue) Serialize() []byte {
-	data, _ := json.Marshal(h.Data)
+	data, _ := json.Marshal(h.Live(time.Now()))
 	return data
 }
 func (h *HashmapValue) Deserialize(data []byte) error {
-	return json.Unmarshal(data, &h.Data)
+	var fields map[string]string
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	h.Data = make(map[string]string, len(fields))
+	h.Expires = nil
+	h.index = NewScanIndex()
+	for field, value := range fields {
+		h.Set(field, value)
+	}
+	return nil
+}
+
+// Set stores value under field, dropping any time to live the field had
+func (h *HashmapValue) Set(field, value string) {
+	if _, exists := h.Data[field]; !exists {
+		h.index.Add(NameHash(field), field)
+	}
+	h.Data[field] = value
+	h.Persist(field)
+}
+
+// Delete removes field and its deadline and reports whether it was there
+func (h *HashmapValue) Delete(field string) bool {
+	if _, exists := h.Data[field]; !exists {
+		return false
+	}
+	delete(h.Data, field)
+	h.index.Remove(NameHash(field), field)
+	h.Persist(field)
+	return true
+}
+
+// Scan visits count fields in the order of their hash from cursor on, see
+// ScanIndex.Page. Expired fields count towards count but are not passed to fn.
+func (h *HashmapValue) Scan(cursor uint64, count int, now time.Time, fn func(field string)) uint64 {
+	return h.index.Page(cursor, count, func(field string) {
+		if !h.Expired(field, now) {
+			fn(field)
+		}
+	})
+}
+
+// ExpireAt sets the deadline of an existing field
+func (h *HashmapValue) ExpireAt(field string, deadline time.Time) {
+	if h.Expires == nil {
+		h.Expires = make(map[string]time.Time)
+	}
+	h.Expires[field] = deadline
+}
+
+// Persist removes the deadline of field and reports whether it had one
+func (h *HashmapValue) Persist(field string) bool {
+	if _, ok := h.Expires[field]; !ok {
+		return false
+	}
+	delete(h.Expires, field)
+	if len(h.Expires) == 0 {
+		h.Expires = nil
+	}
+	return true
+}
+
+// Expired reports whether field has a deadline that has passed at now
+func (h *HashmapValue) Expired(field string, now time.Time) bool {
+	deadline, ok := h.Expires[field]
+	return ok && !now.Before(deadline)
+}
+
+// Lookup returns the value of field unless it is missing or expired
+func (h *HashmapValue) Lookup(field string, now time.Time) (string, bool) {
+	value, exists := h.Data[field]
+	if !exists || h.Expired(field, now) {
+		return "", false
+	}
+	return value, true
+}
+
+// Live returns a copy of the fields that have not expired at now
+func (h *HashmapValue) Live(now time.Time) map[string]string {
+	live := make(map[string]string, len(h.Data))
+	for field, value := range h.Data {
+		if !h.Expired(field, now) {
+			live[field] = value
+		}
+	}
+	return live
+}
+
+// Len counts the fields that have not expired at now
+func (h *HashmapValue) Len(now time.Time) int {
+	n := len(h.Data)
+	for field := range h.Expires {
+		if h.Expired(field, now) {
+			n--
+		}
+	}
+	return n
+}
+
+// ExpiredFields lists the fields whose deadline has passed at now
+func (h *HashmapValue) ExpiredFields(now time.Time) []string {
+	var fields []string
+	for field := range h.Expires {
+		if h.Expired(field, now) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func (h *HashmapValue) Size() int64 {
+	n := len(h.Data)
+	if n == 0 {
+		return valueOverhead
+	}
+	sampled := 0
+	var total int64
+	for field, val := range h.Data {
+		if sampled == sizeSampleLimit {
+			break
+		}
+		total += int64(len(field)+len(val)) + 2*stringOverhead + entryOverhead + scanNodeOverhead
+		sampled++
+	}
+	// deadlines share their field string with Data
+	expires := int64(len(h.Expires)) * (24 + entryOverhead)
+	return valueOverhead + total*int64(n)/int64(sampled) + expires
+}
+
+func (h *HashmapValue) Clone() domain.Value {
+	data := make(map[string]string, len(h.Data))
+	for field, value := range h.Data {
+		data[field] = value
+	}
+	clone := &HashmapValue{Data: data, index: h.index.Clone()}
+	if h.Expires != nil {
+		clone.Expires = make(map[string]time.Time, len(h.Expires))
+		for field, deadline := range h.Expires {
+			clone.Expires[field] = deadline
+		}
+	}
+	return clone
 }
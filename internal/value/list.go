@@ -22,3 +22,90 @@ func (l *ListValue) Serialize() []byte {
 func (l *ListValue) Deserialize(data []byte) error {
 	return json.Unmarshal(data, &l.Data)
 }
+
+func (l *ListValue) Size() int64 {
+	return sliceSize(l.Data)
+}
+
+func (l *ListValue) Clone() domain.Value {
+	return &ListValue{Data: append([]string(nil), l.Data...)}
+}
+
+// Bounds resolves the inclusive range start..stop, where negative indexes count
+// from the tail, to the slice bounds [from, to) clamped to the list
+func (l *ListValue) Bounds(start, stop int) (int, int) {
+	length := len(l.Data)
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	start = max(start, 0)
+	stop = min(stop, length-1)
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop + 1
+}
+
+// Position resolves index, negative ones counting from the tail, to a position from the head
+func (l *ListValue) Position(index int) (int, bool) {
+	if index < 0 {
+		index += len(l.Data)
+	}
+	return index, index >= 0 && index < len(l.Data)
+}
+
+// Find returns the position of the first occurrence of value, or -1
+func (l *ListValue) Find(value string) int {
+	for i, item := range l.Data {
+		if item == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// InsertAt puts value at position i, shifting the elements from i on towards the tail
+func (l *ListValue) InsertAt(i int, value string) {
+	l.Data = append(l.Data, "")
+	copy(l.Data[i+1:], l.Data[i:])
+	l.Data[i] = value
+}
+
+// Remove deletes the first count occurrences of value, the last -count ones for
+// a negative count or all of them for 0, and returns how many were deleted
+func (l *ListValue) Remove(value string, count int) int {
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	removed := 0
+	if count >= 0 {
+		kept := l.Data[:0]
+		for _, item := range l.Data {
+			if item == value && (limit == 0 || removed < limit) {
+				removed++
+				continue
+			}
+			kept = append(kept, item)
+		}
+		clear(l.Data[len(kept):])
+		l.Data = kept
+		return removed
+	}
+	// from the tail: compact towards the end of the slice
+	w := len(l.Data)
+	for i := len(l.Data) - 1; i >= 0; i-- {
+		if l.Data[i] == value && removed < limit {
+			removed++
+			continue
+		}
+		w--
+		l.Data[w] = l.Data[i]
+	}
+	clear(l.Data[:w])
+	l.Data = l.Data[w:]
+	return removed
+}
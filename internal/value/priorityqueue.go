@@ -0,0 +1,118 @@
+package value
+
+import (
+	"container/heap"
+	"encoding/json"
+	"sort"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+)
+
+type PQItem struct {
+	Value    string `json:"value"`
+	Priority int64  `json:"priority"`
+	// seq orders items of equal priority first in, first out
+	seq uint64
+}
+
+// Where value is a priority queue: a binary heap on (priority desc, seq asc),
+// so the highest priority pops first and ties pop in insertion order.
+type PriorityQueueValue struct {
+	items   pqHeap
+	nextSeq uint64
+}
+
+func NewPriorityQueueValue() *PriorityQueueValue {
+	return &PriorityQueueValue{}
+}
+
+func (p *PriorityQueueValue) Type() domain.DataType {
+	return domain.PriorityQueue
+}
+
+func (p *PriorityQueueValue) Serialize() []byte {
+	data, _ := json.Marshal(p.Items())
+	return data
+}
+
+// Deserialize replaces the contents with items listed in pop order
+func (p *PriorityQueueValue) Deserialize(data []byte) error {
+	var items []PQItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	p.items, p.nextSeq = nil, 0
+	for _, item := range items {
+		p.Push(item.Value, item.Priority)
+	}
+	return nil
+}
+
+func (p *PriorityQueueValue) Size() int64 {
+	n := len(p.items)
+	if n == 0 {
+		return valueOverhead
+	}
+	sampled := min(n, sizeSampleLimit)
+	var total int64
+	for _, item := range p.items[:sampled] {
+		total += int64(len(item.Value)) + stringOverhead + 16 + entryOverhead
+	}
+	return valueOverhead + total*int64(n)/int64(sampled)
+}
+
+func (p *PriorityQueueValue) Clone() domain.Value {
+	return &PriorityQueueValue{items: append(pqHeap(nil), p.items...), nextSeq: p.nextSeq}
+}
+
+func (p *PriorityQueueValue) Len() int {
+	return len(p.items)
+}
+
+func (p *PriorityQueueValue) Push(value string, priority int64) {
+	heap.Push(&p.items, PQItem{Value: value, Priority: priority, seq: p.nextSeq})
+	p.nextSeq++
+}
+
+// Pop removes and returns the item with the highest priority
+func (p *PriorityQueueValue) Pop() (PQItem, bool) {
+	if len(p.items) == 0 {
+		return PQItem{}, false
+	}
+	return heap.Pop(&p.items).(PQItem), true
+}
+
+// Peek returns the item Pop would return without removing it
+func (p *PriorityQueueValue) Peek() (PQItem, bool) {
+	if len(p.items) == 0 {
+		return PQItem{}, false
+	}
+	return p.items[0], true
+}
+
+// Items returns all items in pop order
+func (p *PriorityQueueValue) Items() []PQItem {
+	items := append([]PQItem(nil), p.items...)
+	sort.Slice(items, func(i, j int) bool { return items[i].before(items[j]) })
+	return items
+}
+
+func (a PQItem) before(b PQItem) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return a.seq < b.seq
+}
+
+type pqHeap []PQItem
+
+func (h pqHeap) Len() int           { return len(h) }
+func (h pqHeap) Less(i, j int) bool { return h[i].before(h[j]) }
+func (h pqHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *pqHeap) Push(x any)        { *h = append(*h, x.(PQItem)) }
+func (h *pqHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
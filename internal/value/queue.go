@@ -1,7 +1,10 @@
 package value
 
 import (
+	"container/heap"
 	"encoding/json"
+	"sort"
+	"time"
 
 	"github.com/mrpurushotam/mini_db/internal/domain"
 )
@@ -9,6 +12,35 @@ import (
 // Where value is queue type
 type QueueValue struct {
 	Data []string
+	// Attempts[i] counts the earlier deliveries of Data[i]. It stays nil until a
+	// leased item is put back, so plain queues pay nothing for it.
+	Attempts []int
+	// Leases are the items handed out by reliable dequeues and not acked yet, by lease ID
+	Leases map[string]*QueueLease
+	// scheduled holds delayed items until they are due, earliest first
+	scheduled scheduledHeap
+	nextSeq   uint64
+}
+
+// ScheduledItem is an item enqueued for later, it joins the tail of the queue once At has passed
+type ScheduledItem struct {
+	Value string
+	At    time.Time
+	// seq keeps items due at the same time in the order they were scheduled
+	seq uint64
+}
+
+// QueueLease is an item handed out by a reliable dequeue. An ACK deletes it; a
+// NACK or the deadline passing puts it back at the head of the queue, or moves
+// it to DeadLetter once it was delivered MaxAttempts times.
+type QueueLease struct {
+	ID    string
+	Value string
+	// Attempts counts the deliveries of the item, this one included
+	Attempts    int
+	Deadline    time.Time
+	MaxAttempts int
+	DeadLetter  string
 }
 
 func (q *QueueValue) Type() domain.DataType {
@@ -22,3 +54,120 @@ func (q *QueueValue) Serialize() []byte {
 func (q *QueueValue) Deserialize(data []byte) error {
 	return json.Unmarshal(data, &q.Data)
 }
+
+// Append adds an item at the tail
+func (q *QueueValue) Append(item string) {
+	q.Data = append(q.Data, item)
+	if q.Attempts != nil {
+		q.Attempts = append(q.Attempts, 0)
+	}
+}
+
+// Shift removes the head item and returns it with its earlier deliveries
+func (q *QueueValue) Shift() (string, int, bool) {
+	if len(q.Data) == 0 {
+		return "", 0, false
+	}
+	item := q.Data[0]
+	q.Data = q.Data[1:]
+	attempts := 0
+	if q.Attempts != nil {
+		attempts = q.Attempts[0]
+		q.Attempts = q.Attempts[1:]
+	}
+	return item, attempts, true
+}
+
+// Unshift puts an item back at the head, remembering how often it was delivered
+func (q *QueueValue) Unshift(item string, attempts int) {
+	if q.Attempts == nil && attempts > 0 {
+		q.Attempts = make([]int, len(q.Data))
+	}
+	q.Data = append([]string{item}, q.Data...)
+	if q.Attempts != nil {
+		q.Attempts = append([]int{attempts}, q.Attempts...)
+	}
+}
+
+// Schedule holds item back until at
+func (q *QueueValue) Schedule(item string, at time.Time) {
+	heap.Push(&q.scheduled, ScheduledItem{Value: item, At: at, seq: q.nextSeq})
+	q.nextSeq++
+}
+
+// PromoteDue appends the scheduled items due by now to the queue, in the order
+// they are due, and returns how many there were
+func (q *QueueValue) PromoteDue(now time.Time) int {
+	n := 0
+	for len(q.scheduled) > 0 && !q.scheduled[0].At.After(now) {
+		item := heap.Pop(&q.scheduled).(ScheduledItem)
+		q.Append(item.Value)
+		n++
+	}
+	return n
+}
+
+// NextDue reports when the earliest scheduled item is due
+func (q *QueueValue) NextDue() (time.Time, bool) {
+	if len(q.scheduled) == 0 {
+		return time.Time{}, false
+	}
+	return q.scheduled[0].At, true
+}
+
+// Scheduled returns the scheduled items in the order they will be promoted
+func (q *QueueValue) Scheduled() []ScheduledItem {
+	items := append([]ScheduledItem(nil), q.scheduled...)
+	sort.Slice(items, func(i, j int) bool { return items[i].before(items[j]) })
+	return items
+}
+
+func (q *QueueValue) Size() int64 {
+	size := sliceSize(q.Data) + int64(8*len(q.Attempts))
+	for _, item := range q.scheduled {
+		size += int64(len(item.Value)) + stringOverhead + entryOverhead + 16
+	}
+	for id, lease := range q.Leases {
+		size += int64(len(id)+len(lease.Value)+len(lease.DeadLetter)) + 3*stringOverhead + entryOverhead + valueOverhead
+	}
+	return size
+}
+
+func (q *QueueValue) Clone() domain.Value {
+	clone := &QueueValue{Data: append([]string(nil), q.Data...)}
+	if q.Attempts != nil {
+		clone.Attempts = append([]int(nil), q.Attempts...)
+	}
+	if q.scheduled != nil {
+		clone.scheduled = append(scheduledHeap(nil), q.scheduled...)
+		clone.nextSeq = q.nextSeq
+	}
+	if q.Leases != nil {
+		clone.Leases = make(map[string]*QueueLease, len(q.Leases))
+		for id, lease := range q.Leases {
+			copied := *lease
+			clone.Leases[id] = &copied
+		}
+	}
+	return clone
+}
+
+func (a ScheduledItem) before(b ScheduledItem) bool {
+	if !a.At.Equal(b.At) {
+		return a.At.Before(b.At)
+	}
+	return a.seq < b.seq
+}
+
+type scheduledHeap []ScheduledItem
+
+func (h scheduledHeap) Len() int           { return len(h) }
+func (h scheduledHeap) Less(i, j int) bool { return h[i].before(h[j]) }
+func (h scheduledHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *scheduledHeap) Push(x any)        { *h = append(*h, x.(ScheduledItem)) }
+func (h *scheduledHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,156 @@
+package value
+
+// ScanIndex orders names by a 64-bit position so SCAN style cursors can resume
+// where the previous page ended without looking at the names before it. Sets and
+// hashmaps index their members by NameHash; the store indexes its keys by hash
+// slot first. Like sorted sets it is a skiplist, ordered by position and then name.
+type ScanIndex struct {
+	header *scanNode
+	level  int
+	length int
+}
+
+type scanNode struct {
+	pos     uint64
+	name    string
+	forward []*scanNode
+}
+
+func NewScanIndex() *ScanIndex {
+	return &ScanIndex{
+		header: &scanNode{forward: make([]*scanNode, skiplistMaxLevel)},
+		level:  1,
+	}
+}
+
+// NameHash is 64-bit FNV-1a, the position of set members and hashmap fields
+func NameHash(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+func (ix *ScanIndex) Len() int {
+	return ix.length
+}
+
+func scanBefore(pos uint64, name string, node *scanNode) bool {
+	return node.pos < pos || (node.pos == pos && node.name < name)
+}
+
+// Add indexes name at pos; the caller makes sure it is not indexed yet
+func (ix *ScanIndex) Add(pos uint64, name string) {
+	var update [skiplistMaxLevel]*scanNode
+	x := ix.header
+	for i := ix.level - 1; i >= 0; i-- {
+		for x.forward[i] != nil && scanBefore(pos, name, x.forward[i]) {
+			x = x.forward[i]
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > ix.level {
+		for i := ix.level; i < level; i++ {
+			update[i] = ix.header
+		}
+		ix.level = level
+	}
+	x = &scanNode{pos: pos, name: name, forward: make([]*scanNode, level)}
+	for i := 0; i < level; i++ {
+		x.forward[i] = update[i].forward[i]
+		update[i].forward[i] = x
+	}
+	ix.length++
+}
+
+// Remove drops name at pos and reports whether it was indexed
+func (ix *ScanIndex) Remove(pos uint64, name string) bool {
+	var update [skiplistMaxLevel]*scanNode
+	x := ix.header
+	for i := ix.level - 1; i >= 0; i-- {
+		for x.forward[i] != nil && scanBefore(pos, name, x.forward[i]) {
+			x = x.forward[i]
+		}
+		update[i] = x
+	}
+
+	x = x.forward[0]
+	if x == nil || x.pos != pos || x.name != name {
+		return false
+	}
+	for i := 0; i < ix.level && update[i].forward[i] == x; i++ {
+		update[i].forward[i] = x.forward[i]
+	}
+	for ix.level > 1 && ix.header.forward[ix.level-1] == nil {
+		ix.level--
+	}
+	ix.length--
+	return true
+}
+
+// Ascend calls fn for the names from position from on, in order, until fn returns false
+func (ix *ScanIndex) Ascend(from uint64, fn func(pos uint64, name string) bool) {
+	x := ix.header
+	for i := ix.level - 1; i >= 0; i-- {
+		for x.forward[i] != nil && x.forward[i].pos < from {
+			x = x.forward[i]
+		}
+	}
+	for x = x.forward[0]; x != nil; x = x.forward[0] {
+		if !fn(x.pos, x.name) {
+			return
+		}
+	}
+}
+
+// Page calls fn for count names from position cursor on, and for any further
+// names sharing the position of the last one, since the next page starts after
+// it. It returns the cursor of the next page, 0 once the end is reached. Names
+// that stay indexed for the whole iteration are visited exactly once, however
+// the index changes between pages.
+func (ix *ScanIndex) Page(cursor uint64, count int, fn func(name string)) uint64 {
+	visited := 0
+	var last uint64
+	more := false
+	ix.Ascend(cursor, func(pos uint64, name string) bool {
+		if visited >= count && pos != last {
+			more = true
+			return false
+		}
+		fn(name)
+		visited++
+		last = pos
+		return true
+	})
+	if !more {
+		return 0
+	}
+	// more is only set after a name at a position past last, so last+1 cannot wrap
+	return last + 1
+}
+
+// Clone copies the index in linear time, appending the names in order
+func (ix *ScanIndex) Clone() *ScanIndex {
+	clone := NewScanIndex()
+	var tail [skiplistMaxLevel]*scanNode
+	for i := range tail {
+		tail[i] = clone.header
+	}
+	for x := ix.header.forward[0]; x != nil; x = x.forward[0] {
+		level := randomLevel()
+		if level > clone.level {
+			clone.level = level
+		}
+		node := &scanNode{pos: x.pos, name: x.name, forward: make([]*scanNode, level)}
+		for i := 0; i < level; i++ {
+			tail[i].forward[i] = node
+			tail[i] = node
+		}
+	}
+	clone.length = ix.length
+	return clone
+}
@@ -6,9 +6,15 @@ import (
 	"github.com/mrpurushotam/mini_db/internal/domain"
 )
 
-// Where value is Set type
+// Where value is Set type. Data is read freely but only changed through Add
+// and Remove, which keep the members' scan index in step.
 type SetValue struct {
-	Data map[string]struct{}
+	Data  map[string]struct{}
+	index *ScanIndex
+}
+
+func NewSetValue() *SetValue {
+	return &SetValue{Data: make(map[string]struct{}), index: NewScanIndex()}
 }
 
 func (s *SetValue) Type() domain.DataType {
@@ -29,9 +35,60 @@ func (s *SetValue) Deserialize(data []byte) error {
 	if err := json.Unmarshal(data, &members); err != nil {
 		return err
 	}
-	s.Data = make(map[string]struct{})
+	s.Data = make(map[string]struct{}, len(members))
+	s.index = NewScanIndex()
 	for _, member := range members {
-		s.Data[member] = struct{}{}
+		s.Add(member)
 	}
 	return nil
 }
+
+// Add inserts member and reports whether it was new
+func (s *SetValue) Add(member string) bool {
+	if _, exists := s.Data[member]; exists {
+		return false
+	}
+	s.Data[member] = struct{}{}
+	s.index.Add(NameHash(member), member)
+	return true
+}
+
+// Remove deletes member and reports whether it was there
+func (s *SetValue) Remove(member string) bool {
+	if _, exists := s.Data[member]; !exists {
+		return false
+	}
+	delete(s.Data, member)
+	s.index.Remove(NameHash(member), member)
+	return true
+}
+
+// Scan visits count members in the order of their hash from cursor on, see ScanIndex.Page
+func (s *SetValue) Scan(cursor uint64, count int, fn func(member string)) uint64 {
+	return s.index.Page(cursor, count, fn)
+}
+
+func (s *SetValue) Size() int64 {
+	n := len(s.Data)
+	if n == 0 {
+		return valueOverhead
+	}
+	sampled := 0
+	var total int64
+	for member := range s.Data {
+		if sampled == sizeSampleLimit {
+			break
+		}
+		total += int64(len(member)) + stringOverhead + entryOverhead + scanNodeOverhead
+		sampled++
+	}
+	return valueOverhead + total*int64(n)/int64(sampled)
+}
+
+func (s *SetValue) Clone() domain.Value {
+	data := make(map[string]struct{}, len(s.Data))
+	for member := range s.Data {
+		data[member] = struct{}{}
+	}
+	return &SetValue{Data: data, index: s.index.Clone()}
+}
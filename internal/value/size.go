@@ -0,0 +1,28 @@
+package value
+
+// Size estimates are approximate: collections larger than sizeSampleLimit
+// are measured on a sample and extrapolated, like redis' MEMORY USAGE.
+const (
+	sizeSampleLimit = 64
+	stringOverhead  = 16 // string header
+	entryOverhead   = 16 // slice slot / map bucket share per element
+	valueOverhead   = 24 // struct + interface header
+	// ScanIndex node: position, name header, forward slice with on average 1.33 levels
+	scanNodeOverhead = 8 + stringOverhead + 24 + 11
+)
+
+func sliceSize(items []string) int64 {
+	n := len(items)
+	if n == 0 {
+		return valueOverhead
+	}
+	sampled := n
+	if sampled > sizeSampleLimit {
+		sampled = sizeSampleLimit
+	}
+	var total int64
+	for _, item := range items[:sampled] {
+		total += int64(len(item)) + stringOverhead + entryOverhead
+	}
+	return valueOverhead + total*int64(n)/int64(sampled)
+}
@@ -0,0 +1,439 @@
+package value
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/mrpurushotam/mini_db/internal/domain"
+)
+
+const (
+	skiplistMaxLevel = 32
+	skiplistP        = 0.25
+)
+
+type ZMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// ScoreBound is one end of a score range; Exclusive corresponds to redis' "(" prefix
+type ScoreBound struct {
+	Value     float64
+	Exclusive bool
+}
+
+// ParseScoreBound parses "1.5", "(1.5", "-inf" and "+inf"
+func ParseScoreBound(s string) (ScoreBound, error) {
+	var b ScoreBound
+	if strings.HasPrefix(s, "(") {
+		b.Exclusive = true
+		s = s[1:]
+	}
+	switch strings.ToLower(s) {
+	case "-inf":
+		b.Value = math.Inf(-1)
+		return b, nil
+	case "+inf", "inf":
+		b.Value = math.Inf(1)
+		return b, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) {
+		return b, fmt.Errorf("min or max is not a float")
+	}
+	b.Value = v
+	return b, nil
+}
+
+func (b ScoreBound) aboveMin(score float64) bool {
+	if b.Exclusive {
+		return score > b.Value
+	}
+	return score >= b.Value
+}
+
+func (b ScoreBound) belowMax(score float64) bool {
+	if b.Exclusive {
+		return score < b.Value
+	}
+	return score <= b.Value
+}
+
+// Where value is a sorted set: a skiplist ordered by (score, member) for
+// range queries plus a member -> score map for O(1) lookups, as in redis.
+type SortedSetValue struct {
+	dict map[string]float64
+	zsl  *skiplist
+}
+
+func NewSortedSetValue() *SortedSetValue {
+	return &SortedSetValue{
+		dict: make(map[string]float64),
+		zsl:  newSkiplist(),
+	}
+}
+
+func (z *SortedSetValue) Type() domain.DataType {
+	return domain.SortedSet
+}
+
+func (z *SortedSetValue) Serialize() []byte {
+	data, _ := json.Marshal(z.Members())
+	return data
+}
+
+func (z *SortedSetValue) Deserialize(data []byte) error {
+	var members []ZMember
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	z.dict = make(map[string]float64, len(members))
+	z.zsl = newSkiplist()
+	for _, m := range members {
+		z.Add(m.Member, m.Score)
+	}
+	return nil
+}
+
+func (z *SortedSetValue) Size() int64 {
+	n := len(z.dict)
+	if n == 0 {
+		return valueOverhead
+	}
+	sampled := 0
+	var total int64
+	for member := range z.dict {
+		if sampled == sizeSampleLimit {
+			break
+		}
+		// map entry + skiplist node with on average 1.33 levels
+		total += 2*(int64(len(member))+stringOverhead) + 8 + entryOverhead + 48
+		sampled++
+	}
+	return valueOverhead + total*int64(n)/int64(sampled)
+}
+
+// Clone rebuilds the skiplist from the members in order
+func (z *SortedSetValue) Clone() domain.Value {
+	clone := NewSortedSetValue()
+	for x := z.zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+		clone.dict[x.member] = x.score
+		clone.zsl.insert(x.score, x.member)
+	}
+	return clone
+}
+
+func (z *SortedSetValue) Len() int {
+	return len(z.dict)
+}
+
+// Add sets the score of member and reports whether it was newly added
+func (z *SortedSetValue) Add(member string, score float64) bool {
+	old, exists := z.dict[member]
+	if exists {
+		if old == score {
+			return false
+		}
+		z.zsl.delete(old, member)
+	}
+	z.dict[member] = score
+	z.zsl.insert(score, member)
+	return !exists
+}
+
+func (z *SortedSetValue) Remove(member string) bool {
+	score, exists := z.dict[member]
+	if !exists {
+		return false
+	}
+	delete(z.dict, member)
+	z.zsl.delete(score, member)
+	return true
+}
+
+func (z *SortedSetValue) Score(member string) (float64, bool) {
+	score, ok := z.dict[member]
+	return score, ok
+}
+
+// Rank returns the 0-based position of member, counted from the highest score when reverse is set
+func (z *SortedSetValue) Rank(member string, reverse bool) (int, bool) {
+	score, ok := z.dict[member]
+	if !ok {
+		return 0, false
+	}
+	rank := z.zsl.rank(score, member) - 1
+	if reverse {
+		rank = len(z.dict) - 1 - rank
+	}
+	return rank, true
+}
+
+// Members returns all members in ascending order
+func (z *SortedSetValue) Members() []ZMember {
+	return z.RangeByRank(0, -1, false)
+}
+
+// RangeByRank returns members between the 0-based start and stop ranks (inclusive).
+// Negative indexes count from the end, as in ZRANGE.
+func (z *SortedSetValue) RangeByRank(start, stop int, reverse bool) []ZMember {
+	length := len(z.dict)
+	if start < 0 {
+		start = length + start
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	if start > stop || start >= length {
+		return []ZMember{}
+	}
+
+	result := make([]ZMember, 0, stop-start+1)
+	var node *skiplistNode
+	if reverse {
+		node = z.zsl.byRank(length - start)
+	} else {
+		node = z.zsl.byRank(start + 1)
+	}
+	for i := start; i <= stop && node != nil; i++ {
+		result = append(result, ZMember{Member: node.member, Score: node.score})
+		if reverse {
+			node = node.backward
+		} else {
+			node = node.level[0].forward
+		}
+	}
+	return result
+}
+
+// RangeByScore returns members with min <= score <= max, skipping offset matches
+// and returning at most limit of them (limit < 0 means no limit). With reverse
+// set the walk goes from max down to min.
+func (z *SortedSetValue) RangeByScore(min, max ScoreBound, offset, limit int, reverse bool) []ZMember {
+	result := make([]ZMember, 0)
+	if offset < 0 || limit == 0 {
+		return result
+	}
+
+	var node *skiplistNode
+	if reverse {
+		node = z.zsl.lastInRange(min, max)
+	} else {
+		node = z.zsl.firstInRange(min, max)
+	}
+	for ; node != nil && offset > 0; offset-- {
+		node = z.step(node, reverse)
+	}
+	for node != nil && (limit < 0 || len(result) < limit) {
+		if reverse && !min.aboveMin(node.score) || !reverse && !max.belowMax(node.score) {
+			break
+		}
+		result = append(result, ZMember{Member: node.member, Score: node.score})
+		node = z.step(node, reverse)
+	}
+	return result
+}
+
+func (z *SortedSetValue) step(node *skiplistNode, reverse bool) *skiplistNode {
+	if reverse {
+		return node.backward
+	}
+	return node.level[0].forward
+}
+
+// -- skiplist --
+
+type skiplistLevel struct {
+	forward *skiplistNode
+	span    int
+}
+
+type skiplistNode struct {
+	member   string
+	score    float64
+	backward *skiplistNode
+	level    []skiplistLevel
+}
+
+type skiplist struct {
+	header *skiplistNode
+	tail   *skiplistNode
+	length int
+	level  int
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		header: &skiplistNode{level: make([]skiplistLevel, skiplistMaxLevel)},
+		level:  1,
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistP {
+		level++
+	}
+	return level
+}
+
+// nodeBefore orders nodes by score, then lexicographically by member
+func nodeBefore(score float64, member string, node *skiplistNode) bool {
+	return node.score < score || (node.score == score && node.member < member)
+}
+
+func (zsl *skiplist) insert(score float64, member string) {
+	var update [skiplistMaxLevel]*skiplistNode
+	var rank [skiplistMaxLevel]int
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		if i < zsl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && nodeBefore(score, member, x.level[i].forward) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = zsl.header
+			update[i].level[i].span = zsl.length
+		}
+		zsl.level = level
+	}
+
+	x = &skiplistNode{member: member, score: score, level: make([]skiplistLevel, level)}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < zsl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != zsl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		zsl.tail = x
+	}
+	zsl.length++
+}
+
+func (zsl *skiplist) delete(score float64, member string) bool {
+	var update [skiplistMaxLevel]*skiplistNode
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && nodeBefore(score, member, x.level[i].forward) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.level[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+
+	for i := 0; i < zsl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		zsl.tail = x.backward
+	}
+	for zsl.level > 1 && zsl.header.level[zsl.level-1].forward == nil {
+		zsl.level--
+	}
+	zsl.length--
+	return true
+}
+
+// rank returns the 1-based rank of the element, or 0 if missing
+func (zsl *skiplist) rank(score float64, member string) int {
+	rank := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil &&
+			(nodeBefore(score, member, x.level[i].forward) ||
+				(x.level[i].forward.score == score && x.level[i].forward.member == member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != zsl.header && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank returns the node at the 1-based rank
+func (zsl *skiplist) byRank(rank int) *skiplistNode {
+	traversed := 0
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+func (zsl *skiplist) firstInRange(min, max ScoreBound) *skiplistNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !min.aboveMin(x.level[i].forward.score) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if x == nil || !max.belowMax(x.score) {
+		return nil
+	}
+	return x
+}
+
+func (zsl *skiplist) lastInRange(min, max ScoreBound) *skiplistNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && max.belowMax(x.level[i].forward.score) {
+			x = x.level[i].forward
+		}
+	}
+	if x == zsl.header || !min.aboveMin(x.score) {
+		return nil
+	}
+	return x
+}
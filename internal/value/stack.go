@@ -24,3 +24,11 @@ func (s *StackValue) Deserialize(data []byte) error {
 	return json.Unmarshal(data, &s.Data)
 
 }
+
+func (s *StackValue) Size() int64 {
+	return sliceSize(s.Data)
+}
+
+func (s *StackValue) Clone() domain.Value {
+	return &StackValue{Data: append([]string(nil), s.Data...)}
+}
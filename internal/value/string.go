@@ -1,12 +1,17 @@
 package value
 
 import (
+	"bytes"
+	"math/bits"
+
 	"github.com/mrpurushotam/mini_db/internal/domain"
 )
 
-// Where value is string type
+// Where value is string type. Data holds arbitrary bytes, not necessarily UTF-8,
+// and is changed in place by SetRange and SetBit, so it must not be shared
+// between values; Clone copies it.
 type StringValue struct {
-	Data string
+	Data []byte
 }
 
 func (s *StringValue) Type() domain.DataType {
@@ -14,10 +19,146 @@ func (s *StringValue) Type() domain.DataType {
 }
 
 func (s *StringValue) Serialize() []byte {
-	return []byte(s.Data)
+	return bytes.Clone(s.Data)
 }
 
 func (s *StringValue) Deserialize(data []byte) error {
-	s.Data = string(data)
+	s.Data = bytes.Clone(data)
 	return nil
 }
+
+func (s *StringValue) Size() int64 {
+	return valueOverhead + stringOverhead + int64(len(s.Data))
+}
+
+func (s *StringValue) Clone() domain.Value {
+	return &StringValue{Data: bytes.Clone(s.Data)}
+}
+
+// String returns a copy of the bytes as a string
+func (s *StringValue) String() string {
+	return string(s.Data)
+}
+
+// Span converts the inclusive start and end of GETRANGE, BITCOUNT and BITPOS,
+// negative ones counting back from n, into the bounds [from, to) within n.
+// from == to when the range is empty.
+func Span(start, end, n int64) (from, to int64) {
+	if start < 0 {
+		start += n
+	}
+	if end < 0 {
+		end += n
+	}
+	start = max(start, 0)
+	end = min(end, n-1)
+	if start > end {
+		return 0, 0
+	}
+	return start, end + 1
+}
+
+// GetRange returns the bytes between start and end inclusive, see Span
+func (s *StringValue) GetRange(start, end int64) string {
+	from, to := Span(start, end, int64(len(s.Data)))
+	return string(s.Data[from:to])
+}
+
+// SetRange overwrites the bytes from offset on with value, padding with zero
+// bytes when offset is past the end. The bytes are written in place, and the
+// string only grows, with append's amortized copying, when it gets longer.
+func (s *StringValue) SetRange(offset int, value string) {
+	if len(value) == 0 {
+		return
+	}
+	s.grow(offset + len(value))
+	copy(s.Data[offset:], value)
+}
+
+// grow extends the string with zero bytes to at least n bytes
+func (s *StringValue) grow(n int) {
+	if n > len(s.Data) {
+		s.Data = append(s.Data, make([]byte, n-len(s.Data))...)
+	}
+}
+
+// GetBit returns the bit at offset, bit 0 being the most significant bit of the first byte
+func (s *StringValue) GetBit(offset int64) int {
+	i := offset / 8
+	if i >= int64(len(s.Data)) {
+		return 0
+	}
+	return int(s.Data[i]>>(7-offset%8)) & 1
+}
+
+// SetBit sets or clears the bit at offset, growing the string with zero bytes
+// as needed, and returns the bit's previous value. Clearing a bit past the end
+// still grows the string, as in redis.
+func (s *StringValue) SetBit(offset int64, bit int) int {
+	old := s.GetBit(offset)
+	i := int(offset / 8)
+	s.grow(i + 1)
+	mask := byte(1) << (7 - offset%8)
+	if bit == 1 {
+		s.Data[i] |= mask
+	} else {
+		s.Data[i] &^= mask
+	}
+	return old
+}
+
+// BitCount counts the set bits between start and end inclusive, which index
+// bytes, or bits when inBits is set
+func (s *StringValue) BitCount(start, end int64, inBits bool) int64 {
+	from, to := s.bitSpan(start, end, inBits)
+	var count int64
+	for pos := from; pos < to; {
+		if pos%8 == 0 && to-pos >= 8 {
+			count += int64(bits.OnesCount8(s.Data[pos/8]))
+			pos += 8
+			continue
+		}
+		count += int64(s.GetBit(pos))
+		pos++
+	}
+	return count
+}
+
+// BitPos returns the position of the first bit equal to bit between start and
+// end, see BitCount, or -1. Looking for a clear bit without an end treats the
+// string as followed by zero bytes, so the first bit past it is returned.
+func (s *StringValue) BitPos(bit int, start, end int64, inBits, hasEnd bool) int64 {
+	from, to := s.bitSpan(start, end, inBits)
+	if from == to {
+		return -1
+	}
+	// bytes that are all 1s when looking for a 0, or all 0s when looking for a 1, are skipped whole
+	skip := byte(0)
+	if bit == 0 {
+		skip = 0xff
+	}
+	for pos := from; pos < to; {
+		if pos%8 == 0 && to-pos >= 8 && s.Data[pos/8] == skip {
+			pos += 8
+			continue
+		}
+		if s.GetBit(pos) == bit {
+			return pos
+		}
+		pos++
+	}
+	if bit == 0 && !hasEnd {
+		return to
+	}
+	return -1
+}
+
+// bitSpan returns the bit bounds [from, to) of a byte or bit range, see Span
+func (s *StringValue) bitSpan(start, end int64, inBits bool) (int64, int64) {
+	n := int64(len(s.Data))
+	if inBits {
+		return Span(start, end, n*8)
+	}
+	from, to := Span(start, end, n)
+	return from * 8, to * 8
+}